@@ -16,6 +16,7 @@ package client
 
 import (
 	"context"
+	"time"
 
 	"github.com/cockroachdb/cockroach/pkg/roachpb"
 	"github.com/pkg/errors"
@@ -54,10 +55,20 @@ type Batch struct {
 	// Set when AddRawRequest is used, in which case using the "other"
 	// operations renders the batch unusable.
 	raw bool
-	// Once received, the response from a successful batch.
+	// Once received, the response from a successful batch, or the partial
+	// response from a failed one.
 	response *roachpb.BatchResponse
 	// Once received, any error encountered sending the batch.
 	pErr *roachpb.Error
+	// If non-zero, the time after which the batch fails. See SetTimeout.
+	timeout time.Duration
+	// Set if the batch failed because its timeout fired.
+	timedOut bool
+	// If set, the key at which DB.Run records the outcome of the batch. See
+	// SetIdempotencyKey.
+	idempotencyKey roachpb.Key
+	// Set if DB.Run ignores the DB's routing policy. See RequireLeaseholder.
+	requireLeaseholder bool
 
 	// We use pre-allocated buffers to avoid dynamic allocations for small batches.
 	resultsBuf    [8]Result
@@ -66,8 +77,20 @@ type Batch struct {
 	rowsStaticIdx int
 }
 
+// BatchFromRequests returns a batch which sends shallow copies of the provided
+// requests with the provided header, ready to be run by DB.Run. It is meant
+// for translating requests obtained from another layer into a client batch.
+// As with AddRawRequest, the results of the requests are only available
+// through RawResponse.
+func BatchFromRequests(reqs []roachpb.Request, h roachpb.Header) *Batch {
+	b := &Batch{Header: h}
+	b.AddRawRequests(reqs...)
+	return b
+}
+
 // RawResponse returns the BatchResponse which was the result of a successful
-// execution of the batch, and nil otherwise.
+// execution of the batch. After a failed execution, it returns the partial
+// response received before the failure, if any, and nil otherwise.
 func (b *Batch) RawResponse() *roachpb.BatchResponse {
 	return b.response
 }
@@ -152,8 +175,13 @@ func (b *Batch) fillResults(ctx context.Context) {
 			// In that case, we don't want to mutate this result's error
 			// further.
 			if result.Err == nil {
-				// The outcome of each result is that of the batch as a whole.
+				// The outcome of each result is that of the batch as a whole,
+				// except for the requests whose responses were received before
+				// the batch timed out.
 				result.Err = b.pErr.GoError()
+				if result.Err != nil && b.timedOut && b.hasResponse(offset+k) {
+					result.Err = nil
+				}
 				if result.Err == nil {
 					// For a successful request, load the reply to populate in
 					// this pass.
@@ -288,6 +316,60 @@ func (b *Batch) fillResults(ctx context.Context) {
 	}
 }
 
+// hasResponse returns whether a response was received for the i-th request.
+func (b *Batch) hasResponse(i int) bool {
+	return b.response != nil && i < len(b.response.Responses) &&
+		b.response.Responses[i].GetInner() != nil
+}
+
+// SetTimeout sets the time after which running the batch fails with a
+// contextutil.TimeoutError. The results of the operations whose responses
+// were received before the timeout fired are filled in as usual while the
+// other results carry the timeout error, see PartialResults.
+func (b *Batch) SetTimeout(timeout time.Duration) {
+	b.timeout = timeout
+}
+
+// SetIdempotencyKey makes DB.Run apply the batch at most once per key, so
+// that a batch containing non-idempotent operations, such as Inc, can safely
+// be re-sent after an AmbiguousResultError. The key must be unique to the
+// logical operation performed by the batch, and is the key at which the
+// outcome of the batch is recorded: DB.Run runs the batch in a transaction
+// which also writes its responses to the key, unless the key already holds
+// the responses of a previous run, in which case the batch isn't run again
+// and its results are filled in from the recorded responses.
+//
+// Runs are deduplicated within IdempotencyWindow of the run which recorded
+// its outcome; the key can be reused for another operation after that. The
+// records are not removed, so the key should be in a span which the caller
+// cleans up. The key is ignored by Txn.Run, since transactions are retried
+// as a whole.
+func (b *Batch) SetIdempotencyKey(key []byte) {
+	b.idempotencyKey = roachpb.Key(key)
+}
+
+// RequireLeaseholder makes DB.Run send the reads of the batch to the
+// leaseholder at the read consistency of its Header, regardless of the
+// DBContext's DefaultRoutingPolicy. It allows a caller to perform a consistent
+// non-transactional read from a DB which routes reads to the nearest replica.
+// It has no effect on batches run by Txn.Run, whose reads always go to the
+// leaseholder.
+func (b *Batch) RequireLeaseholder() {
+	b.requireLeaseholder = true
+}
+
+// PartialResults returns the results of a batch that was run, along with
+// whether the batch completed successfully. If its timeout fired, the results
+// of the operations which completed carry no error while the others carry the
+// timeout error. This allows for idempotency bookkeeping after a timeout. Note
+// that the Sender in use determines which responses are received when a batch
+// times out; a Sender returning no responses along with an error leaves every
+// result with the error. After any other failure, every result carries the
+// batch's error.
+func (b *Batch) PartialResults() ([]Result, bool) {
+	return b.Results, b.pErr == nil
+}
+
 // resultErr walks through the result slice and returns the first error found,
 // if one exists.
 func (b *Batch) resultErr() error {
@@ -343,6 +425,15 @@ func (b *Batch) AddRawRequest(reqs ...roachpb.Request) {
 	}
 }
 
+// AddRawRequests is like AddRawRequest, but it adds shallow copies of the
+// requests, so that the batch doesn't share them with the caller. This allows
+// adding the requests of another batch.
+func (b *Batch) AddRawRequests(reqs ...roachpb.Request) {
+	for _, req := range reqs {
+		b.AddRawRequest(req.ShallowCopy())
+	}
+}
+
 // Get retrieves the value for a key. A new result will be appended to the
 // batch which will contain a single row.
 //
@@ -379,6 +470,31 @@ func (b *Batch) put(key, value interface{}, inline bool) {
 	b.initResult(1, 1, notRaw, nil)
 }
 
+// putBytes is only exported on DB. It adds a put for each of the entries,
+// allocating the requests and result rows in bulk and bypassing the
+// interface{} marshaling performed by Put.
+func (b *Batch) putBytes(entries []BytesEntry) {
+	if cap(b.Results)-len(b.Results) < len(entries) {
+		results := make([]Result, len(b.Results), len(b.Results)+len(entries))
+		copy(results, b.Results)
+		b.Results = results
+	}
+	if cap(b.rowsBuf)-len(b.rowsBuf) < len(entries) {
+		b.rowsBuf = make([]KeyValue, 0, len(entries))
+	}
+	reqs := make([]roachpb.PutRequest, len(entries))
+	n := len(b.reqs)
+	b.growReqs(len(entries))
+	for i := range entries {
+		req := &reqs[i]
+		req.Key = entries[i].Key
+		req.Value.SetBytes(entries[i].Value)
+		req.Value.InitChecksum(req.Key)
+		b.reqs[n+i].MustSetInner(req)
+		b.initResult(1, 1, notRaw, nil)
+	}
+}
+
 // Put sets the value for a key.
 //
 // A new result will be appended to the batch which will contain a single row
@@ -669,6 +785,30 @@ func (b *Batch) adminRelocateRange(key interface{}, targets []roachpb.Replicatio
 	b.initResult(1, 0, notRaw, nil)
 }
 
+// adminScatter is only exported on DB. It is here for symmetry with the
+// other operations.
+func (b *Batch) adminScatter(s, e interface{}) {
+	begin, err := marshalKey(s)
+	if err != nil {
+		b.initResult(0, 0, notRaw, err)
+		return
+	}
+	end, err := marshalKey(e)
+	if err != nil {
+		b.initResult(0, 0, notRaw, err)
+		return
+	}
+	req := &roachpb.AdminScatterRequest{
+		RequestHeader: roachpb.RequestHeader{
+			Key:    begin,
+			EndKey: end,
+		},
+		RandomizeLeases: true,
+	}
+	b.appendReqs(req)
+	b.initResult(1, 0, notRaw, nil)
+}
+
 // writeBatch is only exported on DB.
 func (b *Batch) writeBatch(s, e interface{}, data []byte) {
 	begin, err := marshalKey(s)
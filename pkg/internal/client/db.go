@@ -17,26 +17,91 @@ package client
 import (
 	"bytes"
 	"context"
+	"encoding/binary"
 	"fmt"
+	"hash/fnv"
+	"sort"
+	"time"
 
+	"github.com/axiomhq/hyperloglog"
 	"github.com/cockroachdb/cockroach/pkg/base"
+	"github.com/cockroachdb/cockroach/pkg/keys"
 	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/storage/engine/enginepb"
+	"github.com/cockroachdb/cockroach/pkg/util/bitarray"
+	"github.com/cockroachdb/cockroach/pkg/util/contextutil"
+	"github.com/cockroachdb/cockroach/pkg/util/ctxgroup"
 	"github.com/cockroachdb/cockroach/pkg/util/hlc"
 	"github.com/cockroachdb/cockroach/pkg/util/log"
 	"github.com/cockroachdb/cockroach/pkg/util/protoutil"
 	"github.com/cockroachdb/cockroach/pkg/util/retry"
 	"github.com/cockroachdb/cockroach/pkg/util/stop"
+	"github.com/cockroachdb/cockroach/pkg/util/syncutil"
+	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
 	"github.com/cockroachdb/cockroach/pkg/util/tracing"
 	"github.com/pkg/errors"
 )
 
 // KeyValue represents a single key/value pair. This is similar to
 // roachpb.KeyValue except that the value may be nil.
+//
+// The KeyValues returned by the DB and Txn methods are not owned by the
+// caller: the rows returned by Get, Scan and ReverseScan point into the
+// response received from the sender, and the rows returned by Put, CPut and
+// InitPut point into the request that was sent. Depending on the sender these
+// buffers may be reused, so callers that retain a KeyValue beyond the lifetime
+// of the batch that produced it should Clone it first. The rows returned by
+// Inc alias the request key but hold a freshly allocated Value.
 type KeyValue struct {
 	Key   roachpb.Key
 	Value *roachpb.Value // Timestamp will always be zero
 }
 
+// Clone returns a deep copy of the KeyValue which shares no memory with the
+// receiver.
+func (kv KeyValue) Clone() KeyValue {
+	res := KeyValue{Key: append(roachpb.Key(nil), kv.Key...)}
+	if kv.Value != nil {
+		v := *kv.Value
+		v.RawBytes = append([]byte(nil), kv.Value.RawBytes...)
+		res.Value = &v
+	}
+	return res
+}
+
+// Size returns the footprint of the KeyValue in bytes, which is the length of
+// its key plus the length of its value's RawBytes, as accounted for by the
+// server when limiting the size of responses.
+func (kv KeyValue) Size() int64 {
+	size := int64(len(kv.Key))
+	if kv.Value != nil {
+		size += int64(len(kv.Value.RawBytes))
+	}
+	return size
+}
+
+// KeyValueFromRoach returns a KeyValue for the provided roachpb.KeyValue. The
+// key is copied, while the value's RawBytes are shared with kv. The value's
+// timestamp is zeroed, as documented on KeyValue.
+func KeyValueFromRoach(kv roachpb.KeyValue) KeyValue {
+	v := kv.Value
+	v.Timestamp = hlc.Timestamp{}
+	return KeyValue{
+		Key:   append(roachpb.Key(nil), kv.Key...),
+		Value: &v,
+	}
+}
+
+// ToRoach returns the roachpb.KeyValue corresponding to the KeyValue, sharing
+// its key and value bytes. The boolean is false if the value is nil, as
+// roachpb.KeyValue cannot represent a missing value.
+func (kv KeyValue) ToRoach() (roachpb.KeyValue, bool) {
+	if kv.Value == nil {
+		return roachpb.KeyValue{Key: kv.Key}, false
+	}
+	return roachpb.KeyValue{Key: kv.Key, Value: *kv.Value}, true
+}
+
 func (kv *KeyValue) String() string {
 	return kv.Key.String() + "=" + kv.PrettyValue()
 }
@@ -76,6 +141,12 @@ func (kv *KeyValue) PrettyValue() string {
 			return fmt.Sprintf("%v", err)
 		}
 		return v.String()
+	case roachpb.ValueType_BITARRAY:
+		v, err := kv.Value.GetBitArray()
+		if err != nil {
+			return fmt.Sprintf("%v", err)
+		}
+		return fmt.Sprintf("B'%s'", v)
 	}
 	return fmt.Sprintf("%x", kv.Value.RawBytes)
 }
@@ -106,6 +177,19 @@ func (kv *KeyValue) ValueInt() int64 {
 	return i
 }
 
+// ValueBitArray returns the value decoded as a bit array. This method will
+// panic if the value cannot be decoded as a bit array.
+func (kv *KeyValue) ValueBitArray() bitarray.BitArray {
+	if kv.Value == nil {
+		return bitarray.BitArray{}
+	}
+	ba, err := kv.Value.GetBitArray()
+	if err != nil {
+		panic(err)
+	}
+	return ba
+}
+
 // ValueProto parses the byte slice value into msg.
 func (kv *KeyValue) ValueProto(msg protoutil.Message) error {
 	if kv.Value == nil {
@@ -163,6 +247,47 @@ func (r Result) String() string {
 	return buf.String()
 }
 
+// Diff returns a human-readable description of the differences between the
+// result's rows and the expected rows, or an empty string if there are none.
+// Rows are matched by key, so their order is insignificant.
+func (r Result) Diff(expected []KeyValue) string {
+	var buf bytes.Buffer
+	if len(r.Rows) != len(expected) {
+		fmt.Fprintf(&buf, "expected %d rows, got %d\n", len(expected), len(r.Rows))
+	}
+	actual := make(map[string]*KeyValue, len(r.Rows))
+	for i := range r.Rows {
+		actual[string(r.Rows[i].Key)] = &r.Rows[i]
+	}
+	for i := range expected {
+		exp := &expected[i]
+		act, ok := actual[string(exp.Key)]
+		if !ok {
+			fmt.Fprintf(&buf, "missing: %s\n", exp)
+			continue
+		}
+		delete(actual, string(exp.Key))
+		if !valuesEqual(exp.Value, act.Value) {
+			fmt.Fprintf(&buf, "mismatch: %s: expected %s, got %s\n",
+				exp.Key, exp.PrettyValue(), act.PrettyValue())
+		}
+	}
+	// Report the extra rows in the order in which they were returned.
+	for i := range r.Rows {
+		if act, ok := actual[string(r.Rows[i].Key)]; ok {
+			fmt.Fprintf(&buf, "extra: %s\n", act)
+		}
+	}
+	return buf.String()
+}
+
+func valuesEqual(a, b *roachpb.Value) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.EqualData(*b)
+}
+
 // DBContext contains configuration parameters for DB.
 type DBContext struct {
 	// UserPriority is the default user priority to set on API calls. If
@@ -174,8 +299,87 @@ type DBContext struct {
 	NodeID *base.NodeIDContainer
 	// Stopper is used for async tasks.
 	Stopper *stop.Stopper
+	// DefaultRoutingPolicy is the routing policy applied to non-transactional
+	// read-only batches which don't specify a read consistency of their own.
+	DefaultRoutingPolicy RoutingPolicy
+	// ApplicationWaiter is used by WaitForApplication. It is injected by the
+	// server since the client cannot talk to individual replicas.
+	ApplicationWaiter ApplicationWaiter
+	// DefaultConcurrencyLimiter, if set, limits the number of batches run
+	// concurrently through DB.Run. Batches run within transactions are not
+	// limited.
+	DefaultConcurrencyLimiter Limiter
+	// MaxBatchRequests and MaxBatchBytes, if non-zero, limit the number of
+	// requests and the encoded size of the requests in the non-transactional
+	// batches sent through the DB. See SizeLimitSender.
+	MaxBatchRequests int
+	MaxBatchBytes    int64
+	// KeyRewriter, if set, rewrites the keys of all requests sent through the
+	// DB, including those sent by its transactions, and the keys returned in
+	// their responses. See KeyRewriter.
+	KeyRewriter KeyRewriter
+	// TrackIOStats, if set, enables the tracking of the number and size of
+	// the requests sent through the DB and of their responses, broken down by
+	// method. See DB.IOStats.
+	TrackIOStats bool
+	// FaultInjection, if set, injects faults into the non-transactional
+	// batches sent through the DB. See FaultInjectionSender. To prevent it from
+	// being enabled accidentally, NewDBWithContext panics if it is set in a
+	// binary not built with the faultinjection build tag.
+	FaultInjection *FaultConfig
+	// SlowRequestThreshold, if non-zero, causes the batches sent through the
+	// DB, including those sent by its transactions, whose send takes longer
+	// than the threshold to be logged along with their requests and key span.
+	SlowRequestThreshold time.Duration
+}
+
+// KeyRewriter translates between the key space used by the callers of a DB and
+// the key space of the KV layer, for example to prefix all keys with a tenant
+// ID. Encode must preserve the ordering of keys so that spans remain spans
+// (including spans ending at a PrefixEnd), and Decode must invert Encode.
+type KeyRewriter interface {
+	// Encode maps a caller key to the key sent to the KV layer.
+	Encode(roachpb.Key) roachpb.Key
+	// Decode maps a key returned by the KV layer back to the caller's key.
+	Decode(roachpb.Key) roachpb.Key
+}
+
+// Limiter limits the number of concurrent operations. It is implemented by
+// limit.ConcurrentRequestLimiter.
+type Limiter interface {
+	// Begin blocks until a slot is available or the context is canceled.
+	Begin(ctx context.Context) error
+	// Finish releases a slot acquired by Begin.
+	Finish()
 }
 
+// ApplicationWaiter blocks until the provided replica of the range has
+// applied the command at leaseIndex, or the context is done.
+type ApplicationWaiter func(
+	ctx context.Context, repl roachpb.ReplicaDescriptor, rangeID roachpb.RangeID, leaseIndex uint64,
+) error
+
+// RoutingPolicy determines which replica of a range serves a read.
+type RoutingPolicy int
+
+const (
+	// RoutingPolicyLeaseholder routes reads to the leaseholder, which
+	// guarantees that they observe the latest committed values.
+	RoutingPolicyLeaseholder RoutingPolicy = iota
+	// RoutingPolicyNearestReplica routes reads to the closest replica. This is
+	// achieved by performing them as INCONSISTENT reads, which means that they
+	// may return stale values (bounded only by how far the replica lags behind
+	// the leaseholder) and that they ignore intents of in-progress
+	// transactions. Only batches consisting solely of Get, Scan and ReverseScan
+	// requests are affected; writes and transactional reads, including locking
+	// reads, always go to the leaseholder. Callers needing a consistent read
+	// from a DB using this policy should use Batch.RequireLeaseholder or read
+	// within a transaction. The DB methods which derive a result from the
+	// reads they perform, such as ScanChecksum, always read from the
+	// leaseholder.
+	RoutingPolicyNearestReplica
+)
+
 // DefaultDBContext returns (a copy of) the default options for
 // NewDBWithContext.
 func DefaultDBContext() DBContext {
@@ -206,7 +410,8 @@ func (s *CrossRangeTxnWrapperSender) Send(
 	ctx context.Context, ba roachpb.BatchRequest,
 ) (*roachpb.BatchResponse, *roachpb.Error) {
 	if ba.Txn != nil {
-		log.Fatalf(ctx, "CrossRangeTxnWrapperSender can't handle transactional requests")
+		return nil, roachpb.NewErrorf(
+			"CrossRangeTxnWrapperSender can't handle transactional requests (txn: %s)", ba.Txn)
 	}
 
 	br, pErr := s.wrapped.Send(ctx, ba)
@@ -219,8 +424,7 @@ func (s *CrossRangeTxnWrapperSender) Send(
 		b := txn.NewBatch()
 		b.Header = ba.Header
 		for _, arg := range ba.Requests {
-			req := arg.GetInner().ShallowCopy()
-			b.AddRawRequest(req)
+			b.AddRawRequests(arg.GetInner())
 		}
 		err := txn.CommitInBatch(ctx, b)
 		br = b.RawResponse()
@@ -248,6 +452,62 @@ type DB struct {
 	ctx     DBContext
 	// crs is the sender used for non-transactional requests.
 	crs CrossRangeTxnWrapperSender
+
+	// ioStats holds the statistics reported by IOStats, keyed by method name.
+	// It is only maintained if DBContext.TrackIOStats is set.
+	ioStats struct {
+		syncutil.Mutex
+		m map[string]IOStat
+	}
+}
+
+// IOStat holds the cumulative number of requests of a given method sent
+// through a DB, and the encoded size of those requests and of the responses
+// received for them.
+type IOStat struct {
+	Requests      int64
+	RequestBytes  int64
+	ResponseBytes int64
+}
+
+// IOStats returns the number and size of the requests sent through the DB,
+// including those sent by its transactions, and of their responses, keyed by
+// method name (e.g. "Get" or "Scan"). The sizes are those of the encoded
+// requests and responses, excluding the headers of their batches. IOStats
+// returns nil if no requests were tracked, which is always the case unless
+// DBContext.TrackIOStats is set.
+func (db *DB) IOStats() map[string]IOStat {
+	db.ioStats.Lock()
+	defer db.ioStats.Unlock()
+	if db.ioStats.m == nil {
+		return nil
+	}
+	stats := make(map[string]IOStat, len(db.ioStats.m))
+	for method, stat := range db.ioStats.m {
+		stats[method] = stat
+	}
+	return stats
+}
+
+// recordIOStats adds the requests of ba, and the responses to them in br, to
+// the statistics reported by IOStats. br may be nil or hold only some of the
+// responses if the batch failed.
+func (db *DB) recordIOStats(ba *roachpb.BatchRequest, br *roachpb.BatchResponse) {
+	db.ioStats.Lock()
+	defer db.ioStats.Unlock()
+	if db.ioStats.m == nil {
+		db.ioStats.m = make(map[string]IOStat)
+	}
+	for i := range ba.Requests {
+		method := ba.Requests[i].GetInner().Method().String()
+		stat := db.ioStats.m[method]
+		stat.Requests++
+		stat.RequestBytes += int64(ba.Requests[i].Size())
+		if br != nil && i < len(br.Responses) && br.Responses[i].GetInner() != nil {
+			stat.ResponseBytes += int64(br.Responses[i].Size())
+		}
+		db.ioStats.m[method] = stat
+	}
 }
 
 // NonTransactionalSender returns a Sender that can be used for sending
@@ -282,13 +542,23 @@ func NewDBWithContext(
 	if actx.Tracer == nil {
 		panic("no tracer set in AmbientCtx")
 	}
+	wrapped := factory.NonTransactionalSender()
+	if ctx.MaxBatchRequests != 0 || ctx.MaxBatchBytes != 0 {
+		wrapped = SizeLimitSender(wrapped, ctx.MaxBatchRequests, ctx.MaxBatchBytes)
+	}
+	if ctx.FaultInjection != nil {
+		if !faultInjectionEnabled {
+			panic("fault injection requires the faultinjection build tag")
+		}
+		wrapped = FaultInjectionSender(wrapped, *ctx.FaultInjection)
+	}
 	db := &DB{
 		AmbientContext: actx,
 		factory:        factory,
 		clock:          clock,
 		ctx:            ctx,
 		crs: CrossRangeTxnWrapperSender{
-			wrapped: factory.NonTransactionalSender(),
+			wrapped: wrapped,
 		},
 	}
 	db.crs.db = db
@@ -308,6 +578,34 @@ func (db *DB) Get(ctx context.Context, key interface{}) (KeyValue, error) {
 	return getOneRow(db.Run(ctx, b), b)
 }
 
+// GetVersioned retrieves the version of a key visible at the provided
+// timestamp, i.e. the latest value written at or below at. The returned value
+// is nil if the key didn't exist at that time.
+//
+// Unlike a read within TxnAsOf, this is a single non-transactional
+// INCONSISTENT read: it doesn't wait for intents, which are ignored, and
+// doesn't guarantee that later writes below at won't become visible. It is
+// meant for debugging tools inspecting the history of a key. An error is
+// returned if at is below the GC threshold of the key's range.
+//
+// key can be either a byte slice or a string.
+func (db *DB) GetVersioned(
+	ctx context.Context, key interface{}, at hlc.Timestamp,
+) (KeyValue, error) {
+	if at == (hlc.Timestamp{}) {
+		return KeyValue{}, errors.New("cannot read a version at an empty timestamp")
+	}
+	b := &Batch{}
+	b.Header.Timestamp = at
+	b.Header.ReadConsistency = roachpb.INCONSISTENT
+	b.Get(key)
+	kv, err := getOneRow(db.Run(ctx, b), b)
+	if gcErr, ok := errors.Cause(err).(*roachpb.BatchTimestampBeforeGCError); ok {
+		return KeyValue{}, errors.Wrapf(gcErr, "cannot read as of %s", at)
+	}
+	return kv, err
+}
+
 // GetProto retrieves the value for a key and decodes the result as a proto
 // message. If the key doesn't exist, the proto will simply be reset.
 //
@@ -320,8 +618,40 @@ func (db *DB) GetProto(ctx context.Context, key interface{}, msg protoutil.Messa
 	return r.ValueProto(msg)
 }
 
+// MultiGetProto retrieves the values for keys in a single batch and decodes
+// each of them into the message at the same index of msgs. Messages whose key
+// does not exist are reset.
+func (db *DB) MultiGetProto(
+	ctx context.Context, keys []roachpb.Key, msgs []protoutil.Message,
+) error {
+	if len(keys) != len(msgs) {
+		return errors.Errorf("%d keys but %d messages", len(keys), len(msgs))
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	b := &Batch{}
+	for _, key := range keys {
+		b.Get(key)
+	}
+	if err := db.Run(ctx, b); err != nil {
+		return err
+	}
+	for i := range b.Results {
+		if err := b.Results[i].Rows[0].ValueProto(msgs[i]); err != nil {
+			return errors.Wrapf(err, "decoding %s", keys[i])
+		}
+	}
+	return nil
+}
+
 // Put sets the value for a key.
 //
+// Put is not pipelined: it returns once the write has been committed to the
+// Raft log by a quorum of the range's replicas and applied by the leaseholder,
+// so that subsequent reads served by the leaseholder see it. Followers may
+// apply the write later; WaitForApplication can be used to wait for them.
+//
 // key can be either a byte slice or a string. value can be any key type, a
 // protoutil.Message or any Go primitive type (bool, int, etc).
 func (db *DB) Put(ctx context.Context, key, value interface{}) error {
@@ -330,6 +660,14 @@ func (db *DB) Put(ctx context.Context, key, value interface{}) error {
 	return getOneErr(db.Run(ctx, b), b)
 }
 
+// PutBitArray sets the value for a key to the provided bit array, encoded
+// with the bit array value tag.
+//
+// key can be either a byte slice or a string.
+func (db *DB) PutBitArray(ctx context.Context, key interface{}, ba bitarray.BitArray) error {
+	return db.Put(ctx, key, ba)
+}
+
 // PutInline sets the value for a key, but does not maintain
 // multi-version values. The most recent value is always overwritten.
 // Inline values cannot be mutated transactionally and should be used
@@ -343,6 +681,26 @@ func (db *DB) PutInline(ctx context.Context, key, value interface{}) error {
 	return getOneErr(db.Run(ctx, b), b)
 }
 
+// BytesEntry is a key and a byte slice value for use with PutBytesBatch.
+type BytesEntry struct {
+	Key   roachpb.Key
+	Value []byte
+}
+
+// PutBytesBatch sets the value of each entry's key to its byte slice value,
+// sending all of the puts in a single batch. It is equivalent to calling Put
+// for each entry, but avoids the per-call overhead. As with any
+// non-transactional batch, the puts are only atomic if they end up being
+// wrapped in a transaction because they span ranges.
+func (db *DB) PutBytesBatch(ctx context.Context, entries []BytesEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	b := &Batch{}
+	b.putBytes(entries)
+	return db.Run(ctx, b)
+}
+
 // CPut conditionally sets the value for a key if the existing value is equal
 // to expValue. To conditionally set a value only if there is no existing entry
 // pass nil for expValue. Note that this must be an interface{}(nil), not a
@@ -383,6 +741,57 @@ func (db *DB) Inc(ctx context.Context, key interface{}, value int64) (KeyValue,
 	return getOneRow(db.Run(ctx, b), b)
 }
 
+// Increment is a key/delta pair for use with DB.BulkInc.
+type Increment struct {
+	Key   interface{}
+	Delta int64
+}
+
+// BulkInc increments the integer values at the provided keys, packing all of
+// the increments into a single batch. The resulting values are returned in
+// input order.
+//
+// Since batches are atomic, an increment that fails on its own (e.g. because
+// the key holds a non-integer value) would fail all of the others. Instead,
+// such an increment is removed from the batch and its error reported in the
+// returned per-key errors, and the remaining increments are retried. The
+// KeyValue of a failed increment has a nil Value. The final error is non-nil
+// only if the batch as a whole failed, in which case no increment has been
+// applied.
+func (db *DB) BulkInc(ctx context.Context, incs []Increment) ([]KeyValue, []error, error) {
+	kvs := make([]KeyValue, len(incs))
+	errs := make([]error, len(incs))
+	pending := make([]int, len(incs))
+	for i := range pending {
+		pending[i] = i
+	}
+	for len(pending) > 0 {
+		b := &Batch{}
+		for _, i := range pending {
+			b.Inc(incs[i].Key, incs[i].Delta)
+		}
+		err := db.Run(ctx, b)
+		if err == nil {
+			for j, i := range pending {
+				kvs[i] = b.Results[j].Rows[0]
+			}
+			break
+		}
+		// Attribute the error to a single increment if possible. The batch
+		// has no pErr if it failed before being sent.
+		pErr := b.pErr
+		if pErr == nil || pErr.Index == nil || int(pErr.Index.Index) >= len(pending) {
+			return nil, nil, err
+		}
+		j := int(pErr.Index.Index)
+		i := pending[j]
+		kvs[i] = KeyValue{Key: b.Results[j].Rows[0].Key}
+		errs[i] = err
+		pending = append(pending[:j], pending[j+1:]...)
+	}
+	return kvs, errs, nil
+}
+
 func (db *DB) scan(
 	ctx context.Context,
 	begin, end interface{},
@@ -426,138 +835,1204 @@ func (db *DB) ReverseScan(
 	return db.scan(ctx, begin, end, maxRows, true, roachpb.CONSISTENT)
 }
 
-// Del deletes one or more keys.
+// ScanSortedByValue retrieves up to maxRows rows between begin (inclusive) and
+// end (exclusive) and returns them ordered by the provided less function
+// instead of by key. Rows that compare equal retain their key order.
+//
+// The ordering is applied by the client: the bounded result set is fully
+// materialized in memory before being sorted, and the rows returned are the
+// first maxRows rows of the span in key order, not the first maxRows rows in
+// the requested order. A maxRows of zero places no bound on the scan and
+// should be avoided for large spans.
 //
 // key can be either a byte slice or a string.
-func (db *DB) Del(ctx context.Context, keys ...interface{}) error {
-	b := &Batch{}
-	b.Del(keys...)
-	return getOneErr(db.Run(ctx, b), b)
+func (db *DB) ScanSortedByValue(
+	ctx context.Context, begin, end interface{}, maxRows int64, less func(a, b KeyValue) bool,
+) ([]KeyValue, error) {
+	rows, err := db.Scan(ctx, begin, end, maxRows)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		return less(rows[i], rows[j])
+	})
+	return rows, nil
 }
 
-// DelRange deletes the rows between begin (inclusive) and end (exclusive).
+// ScanSingleRange retrieves the rows between begin (inclusive) and end
+// (exclusive) in ascending order, stopping at the end of the first range
+// overlapping the span so that only that range is contacted. The returned
+// span is the remainder of [begin, end) which was not scanned, or an empty
+// span if the scan has completed.
 //
-// TODO(pmattis): Perhaps the result should return which rows were deleted.
+// The returned []KeyValue will contain up to maxRows elements.
 //
 // key can be either a byte slice or a string.
-func (db *DB) DelRange(ctx context.Context, begin, end interface{}) error {
+func (db *DB) ScanSingleRange(
+	ctx context.Context, begin, end interface{}, maxRows int64,
+) ([]KeyValue, roachpb.Span, error) {
 	b := &Batch{}
-	b.DelRange(begin, end, false)
-	return getOneErr(db.Run(ctx, b), b)
+	b.Header.ScanOptions = &roachpb.ScanOptions{StopAtRangeBoundary: true}
+	if maxRows > 0 {
+		b.Header.MaxSpanRequestKeys = maxRows
+	}
+	b.Scan(begin, end)
+	r, err := getOneResult(db.Run(ctx, b), b)
+	return r.Rows, r.ResumeSpan, err
 }
 
-// AdminMerge merges the range containing key and the subsequent
-// range. After the merge operation is complete, the range containing
-// key will contain all of the key/value pairs of the subsequent range
-// and the subsequent range will no longer exist.
+// ScanChanOptions configures DB.ScanChan.
+type ScanChanOptions struct {
+	// PageSize is the maximum number of rows retrieved by each scan. If zero,
+	// defaultScanChanPageSize is used.
+	PageSize int64
+}
+
+const defaultScanChanPageSize = 1000
+
+// ScanChan retrieves the rows between begin (inclusive) and end (exclusive) in
+// ascending order, paging through the span in an async task and sending the
+// rows on the returned row channel. The row channel is closed once all rows
+// have been sent or an error is encountered. The error channel receives the
+// error, if any, and is closed afterwards, so it should be read once the row
+// channel has been drained.
+//
+// The task stops when the context is canceled or the DB's stopper quiesces, in
+// which case the corresponding error is reported. Callers that stop consuming
+// rows early must cancel the context to release the task.
 //
 // key can be either a byte slice or a string.
-func (db *DB) AdminMerge(ctx context.Context, key interface{}) error {
-	b := &Batch{}
-	b.adminMerge(key)
-	return getOneErr(db.Run(ctx, b), b)
+func (db *DB) ScanChan(
+	ctx context.Context, begin, end interface{}, opts ScanChanOptions,
+) (<-chan KeyValue, <-chan error) {
+	pageSize := opts.PageSize
+	if pageSize <= 0 {
+		pageSize = defaultScanChanPageSize
+	}
+	rowCh := make(chan KeyValue, pageSize)
+	errCh := make(chan error, 1)
+	stopper := db.ctx.Stopper
+	if err := stopper.RunAsyncTask(ctx, "client-scan-chan", func(ctx context.Context) {
+		ctx, cancel := stopper.WithCancelOnQuiesce(ctx)
+		defer cancel()
+		defer close(errCh)
+		defer close(rowCh)
+		if err := db.scanToChan(ctx, begin, end, pageSize, rowCh); err != nil {
+			errCh <- err
+		}
+	}); err != nil {
+		close(rowCh)
+		errCh <- err
+		close(errCh)
+	}
+	return rowCh, errCh
 }
 
-// AdminSplit splits the range at splitkey.
+// scanToChan implements the paging loop of ScanChan.
+func (db *DB) scanToChan(
+	ctx context.Context, begin, end interface{}, pageSize int64, rowCh chan<- KeyValue,
+) error {
+	for {
+		rows, err := db.Scan(ctx, begin, end, pageSize)
+		if err != nil {
+			return err
+		}
+		for _, kv := range rows {
+			// Check for cancellation first, since select picks randomly among
+			// ready cases and a consumer draining the channel keeps the send
+			// ready.
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			select {
+			case rowCh <- kv:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		if int64(len(rows)) < pageSize {
+			return nil
+		}
+		begin = rows[len(rows)-1].Key.Next()
+	}
+}
+
+// prefetchRows is the number of rows read by Prefetch, and prefetchTimeout
+// bounds how long its read may take.
+const (
+	prefetchRows    = 1
+	prefetchTimeout = 5 * time.Second
+)
+
+// Prefetch asynchronously warms the range holding the start of the span
+// between begin (inclusive) and end (exclusive), so that a subsequent scan of
+// the span, e.g. through ScanChan, finds the range descriptor cached and the
+// lease acquired. It reads a few rows of the span in an async task on the
+// DB's stopper and returns immediately.
 //
-// spanKey is a key within the range that should be split, and splitKey is the
-// key at which that range should be split. splitKey is not used exactly as
-// provided--it is first mutated by keys.EnsureSafeSplitKey. Accounting for
-// this mutation sometimes requires constructing a key that falls in a
-// different range, hence the separation between spanKey and splitKey. See
-// #16008 for details, and #16344 for the tracking issue to clean this mess up
-// properly.
+// Prefetch is advisory: it never blocks the caller, and errors encountered
+// while warming the range, including those from the stopper quiescing, are
+// only logged. The read is not tied to ctx, which is only used for logging,
+// so it proceeds even if the caller's context is canceled.
 //
-// The keys can be either byte slices or a strings.
-func (db *DB) AdminSplit(ctx context.Context, spanKey, splitKey interface{}) error {
-	b := &Batch{}
-	b.adminSplit(spanKey, splitKey)
-	return getOneErr(db.Run(ctx, b), b)
+// key can be either a byte slice or a string.
+func (db *DB) Prefetch(ctx context.Context, begin, end interface{}) {
+	stopper := db.ctx.Stopper
+	taskCtx, cancel := stopper.WithCancelOnQuiesce(db.AnnotateCtx(context.Background()))
+	if err := stopper.RunAsyncTask(taskCtx, "client-prefetch", func(ctx context.Context) {
+		defer cancel()
+		scan := func(ctx context.Context) error {
+			_, err := db.Scan(ctx, begin, end, prefetchRows)
+			return err
+		}
+		if err := contextutil.RunWithTimeout(ctx, "prefetch", prefetchTimeout, scan); err != nil {
+			log.VEventf(ctx, 2, "prefetch failed: %v", err)
+		}
+	}); err != nil {
+		cancel()
+		log.VEventf(ctx, 2, "prefetch not started: %v", err)
+	}
 }
 
-// AdminTransferLease transfers the lease for the range containing key to the
-// specified target. The target replica for the lease transfer must be one of
-// the existing replicas of the range.
+// dropSeamDuplicate drops the first of the rows of a page if its key is the
+// last key of the previous page, so that a resume boundary which mistakenly
+// includes that key doesn't cause ReverseScanForEach to emit it twice.
+func dropSeamDuplicate(rows []KeyValue, lastKey roachpb.Key) []KeyValue {
+	if lastKey != nil && len(rows) > 0 && rows[0].Key.Equal(lastKey) {
+		return rows[1:]
+	}
+	return rows
+}
+
+// ErrStopIteration can be returned by the callback passed to
+// ReverseScanForEach to stop the iteration without causing an error.
+var ErrStopIteration = errors.New("stop iteration")
+
+const scanForEachPageSize = 1000
+
+// ReverseScanForEach retrieves the rows between begin (inclusive) and end
+// (exclusive) in descending order, one page at a time, and calls fn for each
+// of them. If fn returns ErrStopIteration, the iteration stops and nil is
+// returned; any other error stops the iteration and is returned. fn is called
+// at most once per key, even if a page is mistakenly resumed at a span which
+// includes the last key of the previous page.
 //
-// key can be either a byte slice or a string.
+// The rows passed to fn are only valid for the duration of the call.
 //
-// When this method returns, it's guaranteed that the old lease holder has
-// applied the new lease, but that's about it. It's not guaranteed that the new
-// lease holder has applied it (so it might not know immediately that it is the
-// new lease holder).
-func (db *DB) AdminTransferLease(
-	ctx context.Context, key interface{}, target roachpb.StoreID,
+// key can be either a byte slice or a string.
+func (db *DB) ReverseScanForEach(
+	ctx context.Context, begin, end interface{}, fn func(KeyValue) error,
 ) error {
-	b := &Batch{}
-	b.adminTransferLease(key, target)
-	return getOneErr(db.Run(ctx, b), b)
-}
-
-// AdminChangeReplicas adds or removes a set of replicas for a range.
-func (db *DB) AdminChangeReplicas(
-	ctx context.Context,
-	key interface{},
-	changeType roachpb.ReplicaChangeType,
-	targets []roachpb.ReplicationTarget,
-	expDesc roachpb.RangeDescriptor,
-) (*roachpb.RangeDescriptor, error) {
-	b := &Batch{}
-	b.adminChangeReplicas(key, changeType, targets, expDesc)
-	if err := getOneErr(db.Run(ctx, b), b); err != nil {
-		return nil, err
+	beginKey, err := marshalKey(begin)
+	if err != nil {
+		return err
 	}
-	responses := b.response.Responses
-	if len(responses) == 0 {
-		return nil, errors.Errorf("unexpected empty responses for AdminChangeReplicas")
+	endKey, err := marshalKey(end)
+	if err != nil {
+		return err
 	}
-	resp, ok := responses[0].GetInner().(*roachpb.AdminChangeReplicasResponse)
-	if !ok {
-		return nil, errors.Errorf("unexpected response of type %T for AdminChangeReplicas",
-			responses[0].GetInner())
+	span := roachpb.Span{Key: beginKey, EndKey: endKey}
+	var lastKey roachpb.Key
+	for {
+		b := &Batch{}
+		b.Header.MaxSpanRequestKeys = scanForEachPageSize
+		b.ReverseScan(span.Key, span.EndKey)
+		r, err := getOneResult(db.Run(ctx, b), b)
+		if err != nil {
+			return err
+		}
+		for _, kv := range dropSeamDuplicate(r.Rows, lastKey) {
+			if err := fn(kv); err != nil {
+				if err == ErrStopIteration {
+					return nil
+				}
+				return err
+			}
+		}
+		// The resume span of a reverse scan ends at the last key returned.
+		if r.ResumeSpan.Key == nil {
+			return nil
+		}
+		if len(r.Rows) > 0 {
+			lastKey = r.Rows[len(r.Rows)-1].Key
+		}
+		span = r.ResumeSpan
 	}
-	return resp.Desc, nil
 }
 
-// AdminRelocateRange relocates the replicas for a range onto the specified
-// list of stores.
-func (db *DB) AdminRelocateRange(
-	ctx context.Context, key interface{}, targets []roachpb.ReplicationTarget,
-) error {
-	b := &Batch{}
-	b.adminRelocateRange(key, targets)
-	return getOneErr(db.Run(ctx, b), b)
+// ScanFilter is a restricted predicate on the rows returned by ScanFiltered.
+// The zero value matches all rows.
+//
+// The KV layer has no support for evaluating predicates, so the filters are
+// limited to those which can be pushed down as a restriction of the spans
+// that are scanned: rows which don't match are never read.
+type ScanFilter struct {
+	// Prefixes, if non-empty, restricts the rows to those whose key has one of
+	// the provided prefixes.
+	Prefixes []roachpb.Key
 }
 
-// WriteBatch applies the operations encoded in a BatchRepr, which is the
-// serialized form of a RocksDB Batch. The command cannot span Ranges and must
-// be run on an empty keyrange.
-func (db *DB) WriteBatch(ctx context.Context, begin, end interface{}, data []byte) error {
-	b := &Batch{}
-	b.writeBatch(begin, end, data)
-	return getOneErr(db.Run(ctx, b), b)
+// spans returns the spans within [begin, end) which need to be scanned to
+// find the rows matching the filter, in ascending order.
+func (f ScanFilter) spans(begin, end roachpb.Key) []roachpb.Span {
+	if len(f.Prefixes) == 0 {
+		return []roachpb.Span{{Key: begin, EndKey: end}}
+	}
+	spans := make([]roachpb.Span, 0, len(f.Prefixes))
+	for _, prefix := range f.Prefixes {
+		sp := roachpb.Span{Key: prefix, EndKey: prefix.PrefixEnd()}
+		if sp.Key.Compare(begin) < 0 {
+			sp.Key = begin
+		}
+		if sp.EndKey.Compare(end) > 0 {
+			sp.EndKey = end
+		}
+		if sp.Key.Compare(sp.EndKey) < 0 {
+			spans = append(spans, sp)
+		}
+	}
+	spans, _ = roachpb.MergeSpans(spans)
+	return spans
 }
 
-// AddSSTable links a file into the RocksDB log-structured merge-tree. Existing
-// data in the range is cleared.
-func (db *DB) AddSSTable(ctx context.Context, begin, end interface{}, data []byte) error {
-	b := &Batch{}
-	b.addSSTable(begin, end, data)
-	return getOneErr(db.Run(ctx, b), b)
-}
+// scanFilteredPageSize is the maximum number of rows retrieved by each of the
+// batches issued by ScanFiltered.
+const scanFilteredPageSize = 1000
 
-// sendAndFill is a helper which sends the given batch and fills its results,
-// returning the appropriate error which is either from the first failing call,
-// or an "internal" error.
-func sendAndFill(ctx context.Context, send SenderFunc, b *Batch) error {
-	// Errors here will be attached to the results, so we will get them from
-	// the call to fillResults in the regular case in which an individual call
+// ScanFiltered retrieves the rows between begin (inclusive) and end
+// (exclusive) which match the filter, in ascending order. The spans selected
+// by the filter are scanned together, in pages of at most
+// scanFilteredPageSize rows.
+//
+// key can be either a byte slice or a string.
+func (db *DB) ScanFiltered(
+	ctx context.Context, begin, end interface{}, filter ScanFilter,
+) ([]KeyValue, error) {
+	beginKey, err := marshalKey(begin)
+	if err != nil {
+		return nil, err
+	}
+	endKey, err := marshalKey(end)
+	if err != nil {
+		return nil, err
+	}
+	var rows []KeyValue
+	for spans := filter.spans(beginKey, endKey); len(spans) > 0; {
+		b := &Batch{}
+		b.Header.MaxSpanRequestKeys = scanFilteredPageSize
+		for _, sp := range spans {
+			b.Scan(sp.Key, sp.EndKey)
+		}
+		if err := db.Run(ctx, b); err != nil {
+			return nil, err
+		}
+		spans = spans[:0]
+		for _, r := range b.Results {
+			rows = append(rows, r.Rows...)
+			if r.ResumeSpan.Key != nil {
+				spans = append(spans, r.ResumeSpan)
+			}
+		}
+	}
+	return rows, nil
+}
+
+// KeyDiff describes how the value of a key changed between two reads. Old is
+// nil if the key didn't exist at the first read, and New is nil if it no
+// longer exists at the second read.
+type KeyDiff struct {
+	Key roachpb.Key
+	Old *roachpb.Value
+	New *roachpb.Value
+}
+
+// ScanDiff returns, in ascending key order, the keys between begin (inclusive)
+// and end (exclusive) whose value changed since asOf, along with their values
+// as of asOf and their current values. Keys which were overwritten with an
+// identical value are not returned.
+//
+// The diff is computed by merging a scan as of asOf with a scan as of the
+// current time, each of which is paginated. An error is returned if asOf is
+// below the GC threshold of any of the ranges.
+//
+// key can be either a byte slice or a string.
+func (db *DB) ScanDiff(
+	ctx context.Context, begin, end interface{}, asOf hlc.Timestamp,
+) ([]KeyDiff, error) {
+	beginKey, err := marshalKey(begin)
+	if err != nil {
+		return nil, err
+	}
+	endKey, err := marshalKey(end)
+	if err != nil {
+		return nil, err
+	}
+	before, err := db.scanAsOf(ctx, beginKey, endKey, asOf)
+	if err != nil {
+		return nil, err
+	}
+	after, err := db.scanAsOf(ctx, beginKey, endKey, db.clock.Now())
+	if err != nil {
+		return nil, err
+	}
+	var diffs []KeyDiff
+	for len(before) > 0 || len(after) > 0 {
+		var c int
+		switch {
+		case len(before) == 0:
+			c = 1
+		case len(after) == 0:
+			c = -1
+		default:
+			c = before[0].Key.Compare(after[0].Key)
+		}
+		switch {
+		case c < 0:
+			diffs = append(diffs, KeyDiff{Key: before[0].Key, Old: before[0].Value})
+			before = before[1:]
+		case c > 0:
+			diffs = append(diffs, KeyDiff{Key: after[0].Key, New: after[0].Value})
+			after = after[1:]
+		default:
+			if !before[0].Value.EqualData(*after[0].Value) {
+				diffs = append(diffs, KeyDiff{
+					Key: after[0].Key, Old: before[0].Value, New: after[0].Value,
+				})
+			}
+			before, after = before[1:], after[1:]
+		}
+	}
+	return diffs, nil
+}
+
+// scanAsOfPageSize is the maximum number of rows retrieved by each of the
+// scans issued by scanAsOf.
+const scanAsOfPageSize = 1000
+
+// scanAsOf retrieves the rows between begin (inclusive) and end (exclusive) as
+// of the provided timestamp, one page at a time.
+func (db *DB) scanAsOf(
+	ctx context.Context, begin, end roachpb.Key, ts hlc.Timestamp,
+) ([]KeyValue, error) {
+	var rows []KeyValue
+	err := db.TxnAsOf(ctx, ts, func(ctx context.Context, txn *Txn) error {
+		rows = rows[:0]
+		span := roachpb.Span{Key: begin, EndKey: end}
+		for {
+			b := txn.NewBatch()
+			b.Header.MaxSpanRequestKeys = scanAsOfPageSize
+			b.Scan(span.Key, span.EndKey)
+			if err := txn.Run(ctx, b); err != nil {
+				return err
+			}
+			r := b.Results[0]
+			rows = append(rows, r.Rows...)
+			if r.ResumeSpan.Key == nil {
+				return nil
+			}
+			span = r.ResumeSpan
+		}
+	})
+	return rows, err
+}
+
+const scanChecksumPageSize = 1000
+
+// ScanChecksum returns a checksum of the rows between begin (inclusive) and
+// end (exclusive), e.g. to check that a span holds the same data in two
+// clusters without transferring the data to the caller. The span is scanned
+// one page at a time and the checksum is the XOR of the FNV-1a hashes of the
+// rows, so it doesn't depend on how the span is split into pages. Only the
+// keys and the tagged value bytes of the rows contribute to the checksum,
+// not their timestamps or value checksums.
+//
+// The pages are read non-transactionally, so the checksum of a span being
+// written to concurrently doesn't necessarily reflect its state at any single
+// point in time.
+//
+// key can be either a byte slice or a string.
+func (db *DB) ScanChecksum(ctx context.Context, begin, end interface{}) (uint64, error) {
+	beginKey, err := marshalKey(begin)
+	if err != nil {
+		return 0, err
+	}
+	endKey, err := marshalKey(end)
+	if err != nil {
+		return 0, err
+	}
+	var sum uint64
+	var scratch []byte
+	span := roachpb.Span{Key: beginKey, EndKey: endKey}
+	for {
+		b := &Batch{}
+		b.Header.MaxSpanRequestKeys = scanChecksumPageSize
+		b.RequireLeaseholder()
+		b.Scan(span.Key, span.EndKey)
+		r, err := getOneResult(db.Run(ctx, b), b)
+		if err != nil {
+			return 0, err
+		}
+		for _, kv := range r.Rows {
+			sum ^= rowChecksum(kv, &scratch)
+		}
+		if r.ResumeSpan.Key == nil {
+			return sum, nil
+		}
+		span = r.ResumeSpan
+	}
+}
+
+// rowChecksum returns the FNV-1a hash of the key and the tagged value bytes
+// of a row. The key is prefixed with its length so that the boundary between
+// key and value is unambiguous. scratch is reused across calls to avoid
+// allocations.
+func rowChecksum(kv KeyValue, scratch *[]byte) uint64 {
+	h := fnv.New64a()
+	var buf [binary.MaxVarintLen64]byte
+	_, _ = h.Write(buf[:binary.PutUvarint(buf[:], uint64(len(kv.Key)))])
+	_, _ = h.Write(kv.Key)
+	if kv.Value != nil {
+		// Hash a copy of the value without its checksum, which is optional.
+		v := roachpb.Value{RawBytes: append((*scratch)[:0], kv.Value.RawBytes...)}
+		v.ClearChecksum()
+		_, _ = h.Write(v.RawBytes)
+		*scratch = v.RawBytes
+	}
+	return h.Sum64()
+}
+
+// EstimateSpanSize returns an estimate of the number of live bytes and live
+// keys between begin (inclusive) and end (exclusive), for use in reporting
+// the progress of a long-running scan.
+//
+// The estimate is derived from the MVCC statistics of every range that
+// overlaps the span, one RangeStats request per range, so no data is read.
+// It is approximate: ranges which straddle begin or end are counted in their
+// entirety and the statistics may be slightly stale or contain estimates.
+//
+// key can be either a byte slice or a string.
+func (db *DB) EstimateSpanSize(
+	ctx context.Context, begin, end interface{},
+) (bytes int64, keys int64, err error) {
+	beginKey, err := marshalKey(begin)
+	if err != nil {
+		return 0, 0, err
+	}
+	endKey, err := marshalKey(end)
+	if err != nil {
+		return 0, 0, err
+	}
+	for key := beginKey; key.Compare(endKey) < 0; {
+		desc, stats, err := db.rangeStats(ctx, key)
+		if err != nil {
+			return 0, 0, err
+		}
+		bytes += stats.LiveBytes
+		keys += stats.LiveCount
+		key = desc.EndKey.AsRawKey()
+	}
+	return bytes, keys, nil
+}
+
+// rangeStats returns the descriptor and the MVCC statistics of the range
+// containing key.
+func (db *DB) rangeStats(
+	ctx context.Context, key roachpb.Key,
+) (roachpb.RangeDescriptor, enginepb.MVCCStats, error) {
+	res, pErr := SendWrappedWith(ctx, db.NonTransactionalSender(), roachpb.Header{
+		ReturnRangeInfo: true,
+	}, &roachpb.RangeStatsRequest{
+		RequestHeader: roachpb.RequestHeader{Key: key},
+	})
+	if pErr != nil {
+		return roachpb.RangeDescriptor{}, enginepb.MVCCStats{}, pErr.GoError()
+	}
+	rangeInfos := res.Header().RangeInfos
+	if len(rangeInfos) != 1 {
+		return roachpb.RangeDescriptor{}, enginepb.MVCCStats{}, errors.Errorf(
+			"range stats response had %d range infos but exactly one was expected", len(rangeInfos))
+	}
+	desc := rangeInfos[0].Desc
+	if key.Compare(desc.EndKey.AsRawKey()) >= 0 {
+		return roachpb.RangeDescriptor{}, enginepb.MVCCStats{}, errors.Errorf(
+			"range %s does not contain key %s", desc, key)
+	}
+	return desc, res.(*roachpb.RangeStatsResponse).MVCCStats, nil
+}
+
+// splitKeyScanPageSize is the maximum number of rows retrieved by each of the
+// scans issued by SuggestSplitKeys.
+const splitKeyScanPageSize = 1000
+
+// SuggestSplitKeys returns keys which divide the span between begin
+// (inclusive) and end (exclusive) into chunks of roughly targetChunkBytes live
+// bytes each, in ascending order, for example to presplit the span with
+// AdminSplit before a bulk load.
+//
+// The result is approximate. The size of the chunks is derived from the MVCC
+// statistics of the ranges overlapping the span, assuming that the live bytes
+// of each range are spread evenly over its live keys. The statistics of a
+// range which starts before begin are scaled down to its live keys within the
+// span, which are counted by reading them. Otherwise, only the ranges in which
+// a chunk boundary falls are read, in pages and only up to their last
+// boundary; the others are accounted for by their statistics alone. The start
+// keys of ranges are never returned since the span is already split there.
+//
+// key can be either a byte slice or a string.
+func (db *DB) SuggestSplitKeys(
+	ctx context.Context, begin, end interface{}, targetChunkBytes int64,
+) ([]roachpb.Key, error) {
+	if targetChunkBytes <= 0 {
+		return nil, errors.Errorf("invalid target chunk size %d", targetChunkBytes)
+	}
+	beginKey, err := marshalKey(begin)
+	if err != nil {
+		return nil, err
+	}
+	endKey, err := marshalKey(end)
+	if err != nil {
+		return nil, err
+	}
+	var splits []roachpb.Key
+	// chunkBytes is the number of live bytes seen since the last chunk
+	// boundary.
+	var chunkBytes int64
+	for key := beginKey; key.Compare(endKey) < 0; {
+		desc, stats, err := db.rangeStats(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		rangeEnd := desc.EndKey.AsRawKey()
+		spanEnd := rangeEnd
+		if endKey.Compare(spanEnd) < 0 {
+			spanEnd = endKey
+		}
+		liveBytes, liveCount := stats.LiveBytes, stats.LiveCount
+		if liveBytes > 0 && liveCount > 0 && desc.StartKey.AsRawKey().Compare(key) < 0 {
+			// Only part of the first range is in the span. Clamp its statistics
+			// to the live keys in the span.
+			n, err := db.countLiveKeys(ctx, key, spanEnd)
+			if err != nil {
+				return nil, err
+			}
+			if n < liveCount {
+				liveBytes, liveCount = liveBytes*n/liveCount, n
+			}
+		}
+		if liveBytes > 0 && liveCount > 0 {
+			// Find the positions of the chunk boundaries among the live keys of
+			// the range.
+			var indexes []int64
+			for off := targetChunkBytes - chunkBytes; off < liveBytes; off += targetChunkBytes {
+				indexes = append(indexes, off*liveCount/liveBytes)
+			}
+			chunkBytes = (chunkBytes + liveBytes) % targetChunkBytes
+			keys, err := db.keysAtIndexes(ctx, key, spanEnd, indexes)
+			if err != nil {
+				return nil, err
+			}
+			for _, k := range keys {
+				if len(splits) > 0 && k.Compare(splits[len(splits)-1]) <= 0 {
+					continue
+				}
+				splits = append(splits, k)
+			}
+		}
+		key = rangeEnd
+	}
+	return splits, nil
+}
+
+// keysAtIndexes returns the live keys found at the provided positions, which
+// must be in ascending order, among the live keys between begin (inclusive)
+// and end (exclusive). The keys are scanned in pages, up to the last of the
+// positions. Positions past the last key are ignored.
+func (db *DB) keysAtIndexes(
+	ctx context.Context, begin, end roachpb.Key, indexes []int64,
+) ([]roachpb.Key, error) {
+	var keys []roachpb.Key
+	span := roachpb.Span{Key: begin, EndKey: end}
+	var pos int64
+	for len(indexes) > 0 {
+		b := &Batch{}
+		b.Header.MaxSpanRequestKeys = indexes[len(indexes)-1] - pos + 1
+		if b.Header.MaxSpanRequestKeys > splitKeyScanPageSize {
+			b.Header.MaxSpanRequestKeys = splitKeyScanPageSize
+		}
+		b.RequireLeaseholder()
+		b.Scan(span.Key, span.EndKey)
+		if err := db.Run(ctx, b); err != nil {
+			return nil, err
+		}
+		r := b.Results[0]
+		for _, row := range r.Rows {
+			if len(indexes) > 0 && indexes[0] <= pos {
+				keys = append(keys, row.Key)
+				for len(indexes) > 0 && indexes[0] <= pos {
+					indexes = indexes[1:]
+				}
+			}
+			pos++
+		}
+		if r.ResumeSpan.Key == nil {
+			break
+		}
+		span = r.ResumeSpan
+	}
+	return keys, nil
+}
+
+// countLiveKeys returns the number of live keys between begin (inclusive) and
+// end (exclusive). The keys are scanned in pages.
+func (db *DB) countLiveKeys(ctx context.Context, begin, end roachpb.Key) (int64, error) {
+	var n int64
+	span := roachpb.Span{Key: begin, EndKey: end}
+	for {
+		b := &Batch{}
+		b.Header.MaxSpanRequestKeys = splitKeyScanPageSize
+		b.RequireLeaseholder()
+		b.Scan(span.Key, span.EndKey)
+		if err := db.Run(ctx, b); err != nil {
+			return 0, err
+		}
+		r := b.Results[0]
+		n += int64(len(r.Rows))
+		if r.ResumeSpan.Key == nil {
+			return n, nil
+		}
+		span = r.ResumeSpan
+	}
+}
+
+// distinctPrefixSampleRows bounds the number of rows read by
+// EstimateDistinctPrefixes, and distinctPrefixPageSize is the maximum number
+// of rows retrieved by each of its scans.
+const (
+	distinctPrefixSampleRows = 10000
+	distinctPrefixPageSize   = 1000
+)
+
+// EstimateDistinctPrefixes returns an estimate of the number of distinct
+// prefixes of prefixLen bytes among the keys between begin (inclusive) and
+// end (exclusive), e.g. of the distinct values of the leading column of an
+// index, for building histograms or partitioning plans without a full scan.
+// Keys shorter than prefixLen count as their own prefix.
+//
+// The estimate is approximate. The prefixes are counted with a HyperLogLog
+// sketch over a sample of the span, made of its first rows, whose number is
+// bounded to keep the cost of the estimate predictable. If the span holds
+// more rows than the sample, the count is extrapolated to the number of live
+// keys reported by EstimateSpanSize, assuming that the rows are spread evenly
+// over the prefixes.
+//
+// key can be either a byte slice or a string.
+func (db *DB) EstimateDistinctPrefixes(
+	ctx context.Context, begin, end interface{}, prefixLen int,
+) (int64, error) {
+	if prefixLen <= 0 {
+		return 0, errors.Errorf("invalid prefix length %d", prefixLen)
+	}
+	beginKey, err := marshalKey(begin)
+	if err != nil {
+		return 0, err
+	}
+	endKey, err := marshalKey(end)
+	if err != nil {
+		return 0, err
+	}
+	sketch := hyperloglog.New14()
+	var sampled int64
+	span := roachpb.Span{Key: beginKey, EndKey: endKey}
+	for sampled < distinctPrefixSampleRows {
+		b := &Batch{}
+		b.Header.MaxSpanRequestKeys = distinctPrefixSampleRows - sampled
+		if b.Header.MaxSpanRequestKeys > distinctPrefixPageSize {
+			b.Header.MaxSpanRequestKeys = distinctPrefixPageSize
+		}
+		b.RequireLeaseholder()
+		b.Scan(span.Key, span.EndKey)
+		r, err := getOneResult(db.Run(ctx, b), b)
+		if err != nil {
+			return 0, err
+		}
+		for _, kv := range r.Rows {
+			prefix := kv.Key
+			if len(prefix) > prefixLen {
+				prefix = prefix[:prefixLen]
+			}
+			sketch.Insert(prefix)
+			sampled++
+		}
+		if r.ResumeSpan.Key == nil {
+			// The whole span was sampled.
+			return int64(sketch.Estimate()), nil
+		}
+		span = r.ResumeSpan
+	}
+	distinct := int64(sketch.Estimate())
+	_, keys, err := db.EstimateSpanSize(ctx, beginKey, endKey)
+	if err != nil {
+		return 0, err
+	}
+	if keys > sampled {
+		distinct = distinct * keys / sampled
+	}
+	return distinct, nil
+}
+
+// Del deletes one or more keys.
+//
+// key can be either a byte slice or a string.
+func (db *DB) Del(ctx context.Context, keys ...interface{}) error {
+	b := &Batch{}
+	b.Del(keys...)
+	return getOneErr(db.Run(ctx, b), b)
+}
+
+// DelReturningExisted deletes one or more keys and returns, for each key in
+// the order given, whether it existed prior to its deletion.
+//
+// DeleteRequest cannot report whether the key existed, so each key is deleted
+// by a single-key DeleteRange which returns the keys it deleted. If a key is
+// repeated, only its first occurrence can be reported as having existed.
+//
+// key can be either a byte slice or a string.
+func (db *DB) DelReturningExisted(ctx context.Context, keys ...interface{}) ([]bool, error) {
+	b := &Batch{}
+	for _, key := range keys {
+		k, err := marshalKey(key)
+		if err != nil {
+			return nil, err
+		}
+		b.DelRange(k, k.Next(), true /* returnKeys */)
+	}
+	if err := db.Run(ctx, b); err != nil {
+		return nil, err
+	}
+	existed := make([]bool, len(keys))
+	for i, r := range b.Results {
+		existed[i] = len(r.Keys) > 0
+	}
+	return existed, nil
+}
+
+// Swap atomically exchanges the values of two keys within a transaction, so
+// that readers observe either both of the old values or both of the new ones.
+// If only one of the keys exists, its value moves to the other key and it is
+// deleted; if neither exists, Swap is a no-op.
+//
+// key can be either a byte slice or a string.
+func (db *DB) Swap(ctx context.Context, keyA, keyB interface{}) error {
+	return db.Txn(ctx, func(ctx context.Context, txn *Txn) error {
+		b := txn.NewBatch()
+		b.Get(keyA)
+		b.Get(keyB)
+		if err := txn.Run(ctx, b); err != nil {
+			return err
+		}
+		rowA, rowB := b.Results[0].Rows[0], b.Results[1].Rows[0]
+		if rowA.Key.Equal(rowB.Key) || (rowA.Value == nil && rowB.Value == nil) {
+			return nil
+		}
+		wb := txn.NewBatch()
+		putOrDel(wb, rowA.Key, rowB.Value)
+		putOrDel(wb, rowB.Key, rowA.Value)
+		return txn.CommitInBatch(ctx, wb)
+	})
+}
+
+// putOrDel adds to b a write of value to key, or the deletion of key if value
+// is nil. The value is copied without its checksum, which was computed for the
+// key the value was read from.
+func putOrDel(b *Batch, key roachpb.Key, value *roachpb.Value) {
+	if value == nil {
+		b.Del(key)
+		return
+	}
+	v := roachpb.Value{RawBytes: append([]byte(nil), value.RawBytes...)}
+	v.ClearChecksum()
+	b.Put(key, &v)
+}
+
+// DelRange deletes the rows between begin (inclusive) and end (exclusive).
+//
+// TODO(pmattis): Perhaps the result should return which rows were deleted.
+//
+// key can be either a byte slice or a string.
+func (db *DB) DelRange(ctx context.Context, begin, end interface{}) error {
+	b := &Batch{}
+	b.DelRange(begin, end, false)
+	return getOneErr(db.Run(ctx, b), b)
+}
+
+// DelRangeInBatches deletes the rows between begin (inclusive) and end
+// (exclusive) like DelRange, but in consecutive chunks of at most batchSize
+// keys, so that deleting a large span doesn't result in a single large Raft
+// command. It returns the number of keys deleted, which is also returned along
+// with the error if the deletion fails or the context is canceled between two
+// chunks. Since the chunks are deleted non-transactionally, a partial
+// deletion is not rolled back.
+//
+// key can be either a byte slice or a string.
+func (db *DB) DelRangeInBatches(
+	ctx context.Context, begin, end interface{}, batchSize int64,
+) (int64, error) {
+	if batchSize <= 0 {
+		return 0, errors.Errorf("batch size must be positive, got %d", batchSize)
+	}
+	beginKey, err := marshalKey(begin)
+	if err != nil {
+		return 0, err
+	}
+	endKey, err := marshalKey(end)
+	if err != nil {
+		return 0, err
+	}
+	var deleted int64
+	span := roachpb.Span{Key: beginKey, EndKey: endKey}
+	for {
+		if err := ctx.Err(); err != nil {
+			return deleted, err
+		}
+		b := &Batch{}
+		b.Header.MaxSpanRequestKeys = batchSize
+		b.DelRange(span.Key, span.EndKey, true /* returnKeys */)
+		r, err := getOneResult(db.Run(ctx, b), b)
+		if err != nil {
+			return deleted, err
+		}
+		deleted += int64(len(r.Keys))
+		if r.ResumeSpan.Key == nil {
+			return deleted, nil
+		}
+		span = r.ResumeSpan
+	}
+}
+
+// AdminMerge merges the range containing key and the subsequent
+// range. After the merge operation is complete, the range containing
+// key will contain all of the key/value pairs of the subsequent range
+// and the subsequent range will no longer exist.
+//
+// key can be either a byte slice or a string.
+func (db *DB) AdminMerge(ctx context.Context, key interface{}) error {
+	b := &Batch{}
+	b.adminMerge(key)
+	return getOneErr(db.Run(ctx, b), b)
+}
+
+// AdminSplit splits the range at splitkey.
+//
+// spanKey is a key within the range that should be split, and splitKey is the
+// key at which that range should be split. splitKey is not used exactly as
+// provided--it is first mutated by keys.EnsureSafeSplitKey. Accounting for
+// this mutation sometimes requires constructing a key that falls in a
+// different range, hence the separation between spanKey and splitKey. See
+// #16008 for details, and #16344 for the tracking issue to clean this mess up
+// properly.
+//
+// The keys can be either byte slices or a strings.
+func (db *DB) AdminSplit(ctx context.Context, spanKey, splitKey interface{}) error {
+	b := &Batch{}
+	b.adminSplit(spanKey, splitKey)
+	return getOneErr(db.Run(ctx, b), b)
+}
+
+// AdminTransferLease transfers the lease for the range containing key to the
+// specified target. The target replica for the lease transfer must be one of
+// the existing replicas of the range.
+//
+// key can be either a byte slice or a string.
+//
+// When this method returns, it's guaranteed that the old lease holder has
+// applied the new lease, but that's about it. It's not guaranteed that the new
+// lease holder has applied it (so it might not know immediately that it is the
+// new lease holder).
+func (db *DB) AdminTransferLease(
+	ctx context.Context, key interface{}, target roachpb.StoreID,
+) error {
+	b := &Batch{}
+	b.adminTransferLease(key, target)
+	return getOneErr(db.Run(ctx, b), b)
+}
+
+// AdminChangeReplicas adds or removes a set of replicas for a range.
+func (db *DB) AdminChangeReplicas(
+	ctx context.Context,
+	key interface{},
+	changeType roachpb.ReplicaChangeType,
+	targets []roachpb.ReplicationTarget,
+	expDesc roachpb.RangeDescriptor,
+) (*roachpb.RangeDescriptor, error) {
+	b := &Batch{}
+	b.adminChangeReplicas(key, changeType, targets, expDesc)
+	if err := getOneErr(db.Run(ctx, b), b); err != nil {
+		return nil, err
+	}
+	responses := b.response.Responses
+	if len(responses) == 0 {
+		return nil, errors.Errorf("unexpected empty responses for AdminChangeReplicas")
+	}
+	resp, ok := responses[0].GetInner().(*roachpb.AdminChangeReplicasResponse)
+	if !ok {
+		return nil, errors.Errorf("unexpected response of type %T for AdminChangeReplicas",
+			responses[0].GetInner())
+	}
+	return resp.Desc, nil
+}
+
+// AdminRelocateRange relocates the replicas for a range onto the specified
+// list of stores.
+func (db *DB) AdminRelocateRange(
+	ctx context.Context, key interface{}, targets []roachpb.ReplicationTarget,
+) error {
+	b := &Batch{}
+	b.adminRelocateRange(key, targets)
+	return getOneErr(db.Run(ctx, b), b)
+}
+
+// RelocateResult describes the outcome of AdminRelocateRangeWithResult.
+type RelocateResult struct {
+	// Before and After are the replicas of the range before and after the
+	// relocation. After is nil if the range couldn't be read after a failed
+	// relocation.
+	Before, After []roachpb.ReplicaDescriptor
+	// Converged is set if the replicas of the range ended up on exactly the
+	// requested stores.
+	Converged bool
+}
+
+// AdminRelocateRangeWithResult is like AdminRelocateRange, but it reports the
+// replicas of the range before and after the relocation and whether they ended
+// up on the requested stores. The replicas are read from the range's
+// descriptor, so concurrent changes to the range are reflected in the result.
+// If the relocation fails, the result reports how far it got along with the
+// error.
+//
+// key can be either a byte slice or a string.
+func (db *DB) AdminRelocateRangeWithResult(
+	ctx context.Context, key interface{}, targets []roachpb.ReplicationTarget,
+) (RelocateResult, error) {
+	if len(targets) == 0 {
+		return RelocateResult{}, errors.New("no relocation targets")
+	}
+	stores := make(map[roachpb.StoreID]struct{}, len(targets))
+	for _, target := range targets {
+		if _, ok := stores[target.StoreID]; ok {
+			return RelocateResult{}, errors.Errorf(
+				"duplicate relocation target store %d", target.StoreID)
+		}
+		stores[target.StoreID] = struct{}{}
+	}
+	k, err := marshalKey(key)
+	if err != nil {
+		return RelocateResult{}, err
+	}
+	before, _, err := db.rangeStats(ctx, k)
+	if err != nil {
+		return RelocateResult{}, err
+	}
+	res := RelocateResult{Before: before.Replicas}
+	relocateErr := db.AdminRelocateRange(ctx, k, targets)
+	after, _, err := db.rangeStats(ctx, k)
+	if err != nil {
+		if relocateErr != nil {
+			return res, relocateErr
+		}
+		return res, err
+	}
+	res.After = after.Replicas
+	res.Converged = len(after.Replicas) == len(targets)
+	for _, r := range after.Replicas {
+		if !containsTarget(targets, r) {
+			res.Converged = false
+		}
+	}
+	return res, relocateErr
+}
+
+// containsTarget returns whether the replica is on one of the targets.
+func containsTarget(targets []roachpb.ReplicationTarget, r roachpb.ReplicaDescriptor) bool {
+	for _, t := range targets {
+		if t.NodeID == r.NodeID && t.StoreID == r.StoreID {
+			return true
+		}
+	}
+	return false
+}
+
+// AdminScatter randomizes the placement of the replicas and leases of the
+// ranges overlapping the span [begin, end). The response lists the ranges that
+// were scattered; a span contained within a single range results in a
+// response listing just that range.
+//
+// key can be either a byte slice or a string.
+func (db *DB) AdminScatter(
+	ctx context.Context, begin, end interface{},
+) (roachpb.AdminScatterResponse, error) {
+	b := &Batch{}
+	b.adminScatter(begin, end)
+	if err := getOneErr(db.Run(ctx, b), b); err != nil {
+		return roachpb.AdminScatterResponse{}, err
+	}
+	responses := b.response.Responses
+	if len(responses) == 0 {
+		return roachpb.AdminScatterResponse{}, errors.Errorf("unexpected empty responses for AdminScatter")
+	}
+	resp, ok := responses[0].GetInner().(*roachpb.AdminScatterResponse)
+	if !ok {
+		return roachpb.AdminScatterResponse{}, errors.Errorf(
+			"unexpected response of type %T for AdminScatter", responses[0].GetInner())
+	}
+	return *resp, nil
+}
+
+// CheckConsistency runs a consistency check in the given mode over every range
+// overlapping the span between begin (inclusive) and end (exclusive), and
+// returns the per-range results. See CheckConsistencyFn for a variant which
+// does not buffer the results.
+//
+// key can be either a byte slice or a string.
+func (db *DB) CheckConsistency(
+	ctx context.Context, begin, end interface{}, mode roachpb.ChecksumMode,
+) ([]roachpb.CheckConsistencyResponse_Result, error) {
+	var results []roachpb.CheckConsistencyResponse_Result
+	err := db.CheckConsistencyFn(ctx, begin, end, mode,
+		func(res roachpb.CheckConsistencyResponse_Result) error {
+			results = append(results, res)
+			return nil
+		})
+	return results, err
+}
+
+// CheckConsistencyFn is like CheckConsistency, but it checks one range at a
+// time and calls fn with each range's result as soon as it is available, so
+// that spans covering many ranges can be checked without buffering all of the
+// results. An error returned by fn stops the check and is returned.
+//
+// key can be either a byte slice or a string.
+func (db *DB) CheckConsistencyFn(
+	ctx context.Context,
+	begin, end interface{},
+	mode roachpb.ChecksumMode,
+	fn func(roachpb.CheckConsistencyResponse_Result) error,
+) error {
+	beginKey, err := marshalKey(begin)
+	if err != nil {
+		return err
+	}
+	endKey, err := marshalKey(end)
+	if err != nil {
+		return err
+	}
+	for key := beginKey; key.Compare(endKey) < 0; {
+		// The check covers the whole range containing the request's span, so
+		// address a single key and use the returned descriptor to find the
+		// next range.
+		res, pErr := SendWrappedWith(ctx, db.NonTransactionalSender(), roachpb.Header{
+			ReturnRangeInfo: true,
+		}, &roachpb.CheckConsistencyRequest{
+			RequestHeader: roachpb.RequestHeader{Key: key, EndKey: key.Next()},
+			Mode:          mode,
+		})
+		if pErr != nil {
+			return pErr.GoError()
+		}
+		rangeInfos := res.Header().RangeInfos
+		if len(rangeInfos) != 1 {
+			return errors.Errorf(
+				"consistency check response had %d range infos but exactly one was expected",
+				len(rangeInfos))
+		}
+		desc := rangeInfos[0].Desc
+		if key.Compare(desc.EndKey.AsRawKey()) >= 0 {
+			return errors.Errorf("range %s does not contain key %s", desc, key)
+		}
+		for _, result := range res.(*roachpb.CheckConsistencyResponse).Result {
+			if err := fn(result); err != nil {
+				return err
+			}
+		}
+		key = desc.EndKey.AsRawKey()
+	}
+	return nil
+}
+
+// WaitForApplication blocks until all of the provided replicas of the range
+// have applied the command at leaseIndex, or the context is done. It can be
+// used after AdminTransferLease or AdminChangeReplicas to wait for the change
+// to be applied everywhere, with leaseIndex the LeaseAppliedIndex of the
+// range after the change.
+func (db *DB) WaitForApplication(
+	ctx context.Context,
+	rangeID roachpb.RangeID,
+	replicas []roachpb.ReplicaDescriptor,
+	leaseIndex uint64,
+) error {
+	if db.ctx.ApplicationWaiter == nil {
+		return errors.Errorf("WaitForApplication is not supported by this DB")
+	}
+	g := ctxgroup.WithContext(ctx)
+	for _, repl := range replicas {
+		repl := repl // copy for goroutine
+		g.GoCtx(func(ctx context.Context) error {
+			return errors.Wrapf(db.ctx.ApplicationWaiter(ctx, repl, rangeID, leaseIndex),
+				"waiting for application on %s", repl)
+		})
+	}
+	return g.Wait()
+}
+
+// WriteBatch applies the operations encoded in a BatchRepr, which is the
+// serialized form of a RocksDB Batch. The command cannot span Ranges and must
+// be run on an empty keyrange.
+func (db *DB) WriteBatch(ctx context.Context, begin, end interface{}, data []byte) error {
+	b := &Batch{}
+	b.writeBatch(begin, end, data)
+	return getOneErr(db.Run(ctx, b), b)
+}
+
+// AddSSTable links a file into the RocksDB log-structured merge-tree. Existing
+// data in the range is cleared.
+func (db *DB) AddSSTable(ctx context.Context, begin, end interface{}, data []byte) error {
+	b := &Batch{}
+	b.addSSTable(begin, end, data)
+	return getOneErr(db.Run(ctx, b), b)
+}
+
+// sendAndFill is a helper which sends the given batch and fills its results,
+// returning the appropriate error which is either from the first failing call,
+// or an "internal" error.
+func sendAndFill(ctx context.Context, send SenderFunc, b *Batch) error {
+	// Errors here will be attached to the results, so we will get them from
+	// the call to fillResults in the regular case in which an individual call
 	// fails. But send() also returns its own errors, so there's some dancing
 	// here to do because we want to run fillResults() so that the individual
 	// result gets initialized with an error from the corresponding call.
 	var ba roachpb.BatchRequest
 	ba.Requests = b.reqs
 	ba.Header = b.Header
-	b.response, b.pErr = send(ctx, ba)
+	// Validate the read consistency up front so that the caller gets the typed
+	// error rather than the *roachpb.Error sendUsingSender would return.
+	if err := checkReadConsistency(ba); err != nil {
+		b.pErr = roachpb.NewError(err)
+		b.fillResults(ctx)
+		return err
+	}
+	if b.timeout > 0 {
+		if err := contextutil.RunWithTimeout(ctx, "batch", b.timeout, func(ctx context.Context) error {
+			b.response, b.pErr = send(ctx, ba)
+			if b.pErr != nil {
+				return ctx.Err()
+			}
+			return nil
+		}); err != nil {
+			b.pErr = roachpb.NewError(err)
+			b.timedOut = true
+		}
+	} else {
+		b.response, b.pErr = send(ctx, ba)
+	}
 	b.fillResults(ctx)
 	if b.pErr == nil {
 		b.pErr = roachpb.NewError(b.resultErr())
@@ -571,18 +2046,81 @@ func sendAndFill(ctx context.Context, send SenderFunc, b *Batch) error {
 //
 // The operations within a batch are run in parallel and the order is
 // non-deterministic. It is an unspecified behavior to modify and retrieve the
-// same key within a batch.
+// same key within a batch; use TxnBatch to do so.
 //
 // Upon completion, Batch.Results will contain the results for each
 // operation. The order of the results matches the order the operations were
 // added to the batch.
 func (db *DB) Run(ctx context.Context, b *Batch) error {
+	return db.runWithLimiter(ctx, b, db.ctx.DefaultConcurrencyLimiter)
+}
+
+// runWithLimiter runs the batch once it has acquired a slot from limiter, if
+// any.
+func (db *DB) runWithLimiter(ctx context.Context, b *Batch, limiter Limiter) error {
 	if err := b.prepare(); err != nil {
 		return err
 	}
+	if limiter != nil {
+		if err := limiter.Begin(ctx); err != nil {
+			return err
+		}
+		defer limiter.Finish()
+	}
+	if b.idempotencyKey != nil {
+		return sendAndFill(ctx, db.sendIdempotent(b.idempotencyKey), b)
+	}
+	if b.requireLeaseholder {
+		return sendAndFill(ctx, db.sendToLeaseholder, b)
+	}
 	return sendAndFill(ctx, db.send, b)
 }
 
+// IdempotencyWindow is the time during which the runs of a batch with an
+// idempotency key are deduplicated. See Batch.SetIdempotencyKey.
+const IdempotencyWindow = 24 * time.Hour
+
+// sendIdempotent returns a SenderFunc which sends a batch at most once per
+// idempotency key. The batch is sent in a transaction which records its
+// responses at the key, or returns the responses recorded there by a
+// previous run, if any.
+func (db *DB) sendIdempotent(key roachpb.Key) SenderFunc {
+	return func(ctx context.Context, ba roachpb.BatchRequest) (*roachpb.BatchResponse, *roachpb.Error) {
+		var br *roachpb.BatchResponse
+		if err := db.Txn(ctx, func(ctx context.Context, txn *Txn) error {
+			br = nil
+			record, err := txn.Get(ctx, key)
+			if err != nil {
+				return err
+			}
+			if record.Value != nil &&
+				txn.OrigTimestamp().WallTime-record.Value.Timestamp.WallTime < int64(IdempotencyWindow) {
+				log.VEventf(ctx, 2, "batch already run, recorded at %s", key)
+				br = &roachpb.BatchResponse{}
+				return record.Value.GetProto(br)
+			}
+			var pErr *roachpb.Error
+			if br, pErr = txn.Send(ctx, ba); pErr != nil {
+				return pErr.GoError()
+			}
+			return txn.Put(ctx, key, &roachpb.BatchResponse{Responses: br.Responses})
+		}); err != nil {
+			return nil, roachpb.NewError(err)
+		}
+		return br, nil
+	}
+}
+
+// RunLimited is like Run, but it first acquires a slot from limiter, which is
+// released once the batch completes. This provides backpressure to callers
+// fanning out many batches. If the context is canceled while waiting for a
+// slot, the batch is not sent and the context's error is returned. The batch
+// only acquires a slot from limiter, not from the DBContext's
+// DefaultConcurrencyLimiter.
+func (db *DB) RunLimited(ctx context.Context, b *Batch, limiter Limiter) error {
+	return db.runWithLimiter(ctx, b, limiter)
+}
+
 // Txn executes retryable in the context of a distributed transaction. The
 // transaction is automatically aborted if retryable returns any error aside
 // from recoverable internal errors, and is automatically committed
@@ -609,14 +2147,158 @@ func (db *DB) Txn(ctx context.Context, retryable func(context.Context, *Txn) err
 	return err
 }
 
+// TxnDeadlineExceededError is returned by DB.TxnWithDeadline when the
+// transaction cannot commit before its deadline.
+type TxnDeadlineExceededError struct {
+	Deadline hlc.Timestamp
+}
+
+func (e *TxnDeadlineExceededError) Error() string {
+	return fmt.Sprintf("transaction deadline %s exceeded", e.Deadline)
+}
+
+// TxnWithDeadline is like Txn, but the transaction must commit by the provided
+// deadline. The deadline is set on every attempt of the transaction, so an
+// attempt whose timestamp gets pushed past it fails to commit and is retried
+// at a newer timestamp. Once an attempt would start at or after the deadline,
+// the transaction is aborted and a *TxnDeadlineExceededError is returned.
+func (db *DB) TxnWithDeadline(
+	ctx context.Context,
+	deadline hlc.Timestamp,
+	retryable func(context.Context, *Txn) error,
+) error {
+	return db.Txn(ctx, func(ctx context.Context, txn *Txn) error {
+		if !txn.OrigTimestamp().Less(deadline) {
+			return &TxnDeadlineExceededError{Deadline: deadline}
+		}
+		txn.UpdateDeadlineMaybe(ctx, deadline)
+		return retryable(ctx, txn)
+	})
+}
+
+// TxnAsOf is like Txn, but the transaction reads at the fixed timestamp asOf,
+// so that all of its reads see the same snapshot, as with AS OF SYSTEM TIME.
+// The transaction's timestamp is never advanced and the transaction is
+// read-only: batches containing writes are rejected without being sent. If
+// asOf is below the GC threshold of the data being read, the returned error
+// wraps the *roachpb.BatchTimestampBeforeGCError.
+func (db *DB) TxnAsOf(
+	ctx context.Context, asOf hlc.Timestamp, retryable func(context.Context, *Txn) error,
+) error {
+	err := db.Txn(ctx, func(ctx context.Context, txn *Txn) error {
+		txn.SetFixedTimestamp(ctx, asOf)
+		txn.readOnly = true
+		return retryable(ctx, txn)
+	})
+	if gcErr, ok := errors.Cause(err).(*roachpb.BatchTimestampBeforeGCError); ok {
+		return errors.Wrapf(gcErr, "cannot read as of %s", asOf)
+	}
+	return err
+}
+
+// TxnBatch runs the batch built by build in a transaction which is committed
+// by that same batch. Unlike with Run, the operations of the batch are applied
+// in the order in which they were added, so that a read of a key sees the
+// writes to it which precede it in the batch. build is called to build a new
+// batch on every attempt of the transaction, and may retain the batch to
+// inspect its Results once TxnBatch returns.
+func (db *DB) TxnBatch(ctx context.Context, build func(*Batch)) error {
+	return db.Txn(ctx, func(ctx context.Context, txn *Txn) error {
+		b := txn.NewBatch()
+		build(b)
+		return txn.CommitInBatch(ctx, b)
+	})
+}
+
+// TxnWithSpans is like Txn, but it also returns the key spans read and
+// written by the transaction once it has committed, e.g. to investigate why
+// two transactions conflict. The spans are those tracked by the transaction's
+// coordinator: the written spans are its intent spans and the read spans are
+// the spans it would refresh if its timestamp were pushed. The coordinator
+// merges overlapping spans and may condense many spans into fewer, coarser
+// ones covering them, so the spans are a superset of the keys accessed. The
+// read spans are nil if the coordinator stopped tracking them.
+func (db *DB) TxnWithSpans(
+	ctx context.Context, retryable func(context.Context, *Txn) error,
+) (read []roachpb.Span, written []roachpb.Span, err error) {
+	var txn *Txn
+	if err := db.Txn(ctx, func(ctx context.Context, t *Txn) error {
+		txn = t
+		return retryable(ctx, t)
+	}); err != nil {
+		return nil, nil, err
+	}
+	meta := txn.GetTxnCoordMeta(ctx)
+	if !meta.RefreshInvalid {
+		read = meta.RefreshReads
+	}
+	return read, meta.Intents, nil
+}
+
 // send runs the specified calls synchronously in a single batch and returns
 // any errors. Returns (nil, nil) for an empty batch.
 func (db *DB) send(
 	ctx context.Context, ba roachpb.BatchRequest,
+) (*roachpb.BatchResponse, *roachpb.Error) {
+	if db.ctx.DefaultRoutingPolicy == RoutingPolicyNearestReplica &&
+		ba.ReadConsistency == roachpb.CONSISTENT &&
+		roachpb.INCONSISTENT.SupportsBatch(ba) == nil {
+		ba.ReadConsistency = roachpb.INCONSISTENT
+	}
+	return db.sendToLeaseholder(ctx, ba)
+}
+
+// sendToLeaseholder runs the batch non-transactionally at the read
+// consistency of its header, ignoring the DefaultRoutingPolicy.
+func (db *DB) sendToLeaseholder(
+	ctx context.Context, ba roachpb.BatchRequest,
 ) (*roachpb.BatchResponse, *roachpb.Error) {
 	return db.sendUsingSender(ctx, ba, db.NonTransactionalSender())
 }
 
+// InvalidReadConsistencyError is returned when a batch contains requests which
+// are not supported at its read consistency, e.g. writes in an INCONSISTENT
+// batch.
+type InvalidReadConsistencyError struct {
+	ReadConsistency roachpb.ReadConsistencyType
+	// Methods are the distinct methods of the unsupported requests, in the
+	// order in which they first appear in the batch.
+	Methods []roachpb.Method
+}
+
+func (e *InvalidReadConsistencyError) Error() string {
+	if len(e.Methods) == 1 {
+		return fmt.Sprintf("method %s not allowed with %s batch", e.Methods[0], e.ReadConsistency)
+	}
+	return fmt.Sprintf("methods %s not allowed with %s batch", e.Methods, e.ReadConsistency)
+}
+
+// checkReadConsistency returns an *InvalidReadConsistencyError if the batch
+// contains requests which are not supported at its read consistency.
+func checkReadConsistency(ba roachpb.BatchRequest) error {
+	if ba.ReadConsistency.SupportsBatch(ba) == nil {
+		return nil
+	}
+	err := &InvalidReadConsistencyError{ReadConsistency: ba.ReadConsistency}
+	var single roachpb.BatchRequest
+	single.Requests = make([]roachpb.RequestUnion, 1)
+	for _, ru := range ba.Requests {
+		single.Requests[0] = ru
+		if ba.ReadConsistency.SupportsBatch(single) == nil {
+			continue
+		}
+		m := ru.GetInner().Method()
+		seen := false
+		for _, prev := range err.Methods {
+			seen = seen || prev == m
+		}
+		if !seen {
+			err.Methods = append(err.Methods, m)
+		}
+	}
+	return err
+}
+
 // sendUsingSender uses the specified sender to send the batch request.
 func (db *DB) sendUsingSender(
 	ctx context.Context, ba roachpb.BatchRequest, sender Sender,
@@ -624,24 +2306,179 @@ func (db *DB) sendUsingSender(
 	if len(ba.Requests) == 0 {
 		return nil, nil
 	}
-	if err := ba.ReadConsistency.SupportsBatch(ba); err != nil {
+	if err := checkReadConsistency(ba); err != nil {
 		return nil, roachpb.NewError(err)
 	}
 	if ba.UserPriority == 0 && db.ctx.UserPriority != 1 {
 		ba.UserPriority = db.ctx.UserPriority
 	}
 
+	kr := db.ctx.KeyRewriter
+	origBa := ba
+	if kr != nil {
+		var err error
+		if ba, err = encodeBatchKeys(kr, ba); err != nil {
+			return nil, roachpb.NewError(err)
+		}
+	}
+
 	tracing.AnnotateTrace()
+	start := timeutil.Now()
 	br, pErr := sender.Send(ctx, ba)
+	if threshold := db.ctx.SlowRequestThreshold; threshold > 0 {
+		if elapsed := timeutil.Since(start); elapsed > threshold {
+			logSlowBatch(ctx, &ba, elapsed)
+		}
+	}
+	if db.ctx.TrackIOStats {
+		db.recordIOStats(&ba, br)
+	}
+	if br != nil && kr != nil {
+		decodeResponseKeys(kr, origBa, br)
+	}
 	if pErr != nil {
 		if log.V(1) {
 			log.Infof(ctx, "failed batch: %s", pErr)
 		}
-		return nil, pErr
+		// Any responses received before the error are passed along so that
+		// they can be reported as partial results.
+		return br, pErr
 	}
 	return br, nil
 }
 
+// logSlowBatch logs a batch whose send took longer than the DB's
+// SlowRequestThreshold.
+func logSlowBatch(ctx context.Context, ba *roachpb.BatchRequest, elapsed time.Duration) {
+	rs, err := keys.Range(*ba)
+	if err != nil {
+		log.Warningf(ctx, "slow batch took %s: %s", elapsed, ba.Summary())
+		return
+	}
+	log.Warningf(ctx, "slow batch took %s: %s on %s", elapsed, ba.Summary(), rs)
+}
+
+// keyRewriterMethods is the set of the methods whose keys are all carried in
+// their request header, or are rewritten by encodeBatchKeys. The requests of
+// other methods, e.g. AddSSTable, carry keys which encodeBatchKeys can't
+// rewrite, so they are rejected when a KeyRewriter is set.
+var keyRewriterMethods = map[roachpb.Method]struct{}{
+	roachpb.Get:                 {},
+	roachpb.Put:                 {},
+	roachpb.ConditionalPut:      {},
+	roachpb.InitPut:             {},
+	roachpb.Increment:           {},
+	roachpb.Delete:              {},
+	roachpb.DeleteRange:         {},
+	roachpb.ClearRange:          {},
+	roachpb.Scan:                {},
+	roachpb.ReverseScan:         {},
+	roachpb.BeginTransaction:    {},
+	roachpb.EndTransaction:      {},
+	roachpb.AdminSplit:          {},
+	roachpb.AdminMerge:          {},
+	roachpb.AdminTransferLease:  {},
+	roachpb.AdminChangeReplicas: {},
+	roachpb.AdminRelocateRange:  {},
+	roachpb.AdminScatter:        {},
+}
+
+// encodeBatchKeys returns a copy of ba whose request spans have been encoded
+// with kr. The requests of ba are not modified, since the batch's results are
+// later filled in using the keys of the original requests. The checksums of
+// the values written by the requests are recomputed against the encoded keys,
+// which the KV layer verifies them against.
+func encodeBatchKeys(kr KeyRewriter, ba roachpb.BatchRequest) (roachpb.BatchRequest, error) {
+	reqs := make([]roachpb.RequestUnion, len(ba.Requests))
+	for i := range ba.Requests {
+		req := ba.Requests[i].GetInner().ShallowCopy()
+		if _, ok := keyRewriterMethods[req.Method()]; !ok {
+			return roachpb.BatchRequest{}, errors.Errorf(
+				"%s requests can't be sent with a KeyRewriter", req.Method())
+		}
+		h := req.Header()
+		if h.Key != nil {
+			h.Key = kr.Encode(h.Key)
+		}
+		if h.EndKey != nil {
+			h.EndKey = kr.Encode(h.EndKey)
+		}
+		req.SetHeader(h)
+		switch t := req.(type) {
+		case *roachpb.PutRequest:
+			t.Value = checksumValue(t.Value, h.Key)
+		case *roachpb.ConditionalPutRequest:
+			t.Value = checksumValue(t.Value, h.Key)
+		case *roachpb.InitPutRequest:
+			t.Value = checksumValue(t.Value, h.Key)
+		case *roachpb.AdminSplitRequest:
+			if t.SplitKey != nil {
+				t.SplitKey = kr.Encode(t.SplitKey)
+			}
+		case *roachpb.EndTransactionRequest:
+			if len(t.IntentSpans) > 0 {
+				return roachpb.BatchRequest{}, errors.Errorf(
+					"%s requests with intent spans can't be sent with a KeyRewriter", req.Method())
+			}
+		}
+		reqs[i].MustSetInner(req)
+	}
+	ba.Requests = reqs
+	return ba, nil
+}
+
+// checksumValue returns a copy of v whose checksum has been computed against
+// key. The RawBytes of v are copied, since they may be shared with the
+// caller's request.
+func checksumValue(v roachpb.Value, key roachpb.Key) roachpb.Value {
+	if v.RawBytes == nil {
+		return v
+	}
+	v.RawBytes = append([]byte(nil), v.RawBytes...)
+	v.ClearChecksum()
+	v.InitChecksum(key)
+	return v
+}
+
+// decodeResponseKeys decodes, in place, the keys returned in br with kr. The
+// checksums of the returned values are recomputed against the decoded keys. ba
+// is the batch as sent by the caller, before its keys were encoded.
+func decodeResponseKeys(kr KeyRewriter, ba roachpb.BatchRequest, br *roachpb.BatchResponse) {
+	decodeRows := func(rows []roachpb.KeyValue) {
+		for i := range rows {
+			rows[i].Key = kr.Decode(rows[i].Key)
+			rows[i].Value = checksumValue(rows[i].Value, rows[i].Key)
+		}
+	}
+	for i := range br.Responses {
+		reply := br.Responses[i].GetInner()
+		switch t := reply.(type) {
+		case *roachpb.GetResponse:
+			if t.Value != nil && i < len(ba.Requests) {
+				v := checksumValue(*t.Value, ba.Requests[i].GetInner().Header().Key)
+				t.Value = &v
+			}
+		case *roachpb.ScanResponse:
+			decodeRows(t.Rows)
+			decodeRows(t.IntentRows)
+		case *roachpb.ReverseScanResponse:
+			decodeRows(t.Rows)
+			decodeRows(t.IntentRows)
+		case *roachpb.DeleteRangeResponse:
+			for j := range t.Keys {
+				t.Keys[j] = kr.Decode(t.Keys[j])
+			}
+		}
+		if h := reply.Header(); h.ResumeSpan != nil {
+			h.ResumeSpan = &roachpb.Span{
+				Key:    kr.Decode(h.ResumeSpan.Key),
+				EndKey: kr.Decode(h.ResumeSpan.EndKey),
+			}
+			reply.SetHeader(h)
+		}
+	}
+}
+
 // getOneErr returns the error for a single-request Batch that was run.
 // runErr is the error returned by Run, b is the Batch that was passed to Run.
 func getOneErr(runErr error, b *Batch) error {
@@ -677,21 +2514,40 @@ func getOneRow(runErr error, b *Batch) (KeyValue, error) {
 	return res.Rows[0], nil
 }
 
-// IncrementValRetryable increments a key's value by a specified amount and
-// returns the new value.
-//
-// It performs the increment as a retryable non-transactional increment. The key
-// might be incremented multiple times because of the retries.
-func IncrementValRetryable(ctx context.Context, db *DB, key roachpb.Key, inc int64) (int64, error) {
+// RunRetryable runs fn, retrying it with the provided options for as long as
+// it returns an UnhandledRetryableError or an AmbiguousResultError. It returns
+// the error returned by the last attempt, which is nil on success. Since an
+// ambiguous result may have been applied, fn must be safe to run multiple
+// times.
+func RunRetryable(
+	ctx context.Context, db *DB, opts retry.Options, fn func(context.Context) error,
+) error {
 	var err error
-	var res KeyValue
-	for r := retry.Start(base.DefaultRetryOptions()); r.Next(); {
-		res, err = db.Inc(ctx, key, inc)
+	for r := retry.StartWithCtx(ctx, opts); r.Next(); {
+		err = fn(ctx)
 		switch err.(type) {
 		case *roachpb.UnhandledRetryableError, *roachpb.AmbiguousResultError:
+			log.VEventf(db.AnnotateCtx(ctx), 2, "retrying after error: %v", err)
 			continue
 		}
 		break
 	}
+	return err
+}
+
+// IncrementValRetryable increments a key's value by a specified amount and
+// returns the new value.
+//
+// It performs the increment as a retryable non-transactional increment. The key
+// might be incremented multiple times because of the retries. To increment it
+// at most once, run a batch with an idempotency key instead, see
+// Batch.SetIdempotencyKey.
+func IncrementValRetryable(ctx context.Context, db *DB, key roachpb.Key, inc int64) (int64, error) {
+	var res KeyValue
+	err := RunRetryable(ctx, db, base.DefaultRetryOptions(), func(ctx context.Context) error {
+		var err error
+		res, err = db.Inc(ctx, key, inc)
+		return err
+	})
 	return res.ValueInt(), err
 }
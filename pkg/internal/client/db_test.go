@@ -17,12 +17,29 @@ package client_test
 import (
 	"bytes"
 	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/cockroachdb/cockroach/pkg/base"
 	"github.com/cockroachdb/cockroach/pkg/internal/client"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/testutils"
 	"github.com/cockroachdb/cockroach/pkg/testutils/serverutils"
+	"github.com/cockroachdb/cockroach/pkg/util/bitarray"
+	"github.com/cockroachdb/cockroach/pkg/util/hlc"
 	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
+	"github.com/cockroachdb/cockroach/pkg/util/limit"
+	"github.com/cockroachdb/cockroach/pkg/util/log"
+	"github.com/cockroachdb/cockroach/pkg/util/protoutil"
+	"github.com/cockroachdb/cockroach/pkg/util/retry"
+	"github.com/cockroachdb/cockroach/pkg/util/stop"
+	"github.com/cockroachdb/cockroach/pkg/util/syncutil"
+	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
+	"github.com/pkg/errors"
 )
 
 func setup(t *testing.T) (serverutils.TestServerInterface, *client.DB) {
@@ -191,6 +208,2391 @@ func TestDB_Inc(t *testing.T) {
 	checkIntResult(t, 100, result.ValueInt())
 }
 
+func TestDB_BulkInc(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	// The sender fails any batch containing an increment of "bad", mimicking
+	// an increment of a key holding a non-integer value.
+	var batches int
+	counters := map[string]int64{"b": 10}
+	sender := func(
+		_ context.Context, ba roachpb.BatchRequest,
+	) (*roachpb.BatchResponse, *roachpb.Error) {
+		batches++
+		for i, ru := range ba.Requests {
+			if req := ru.GetIncrement(); string(req.Key) == "bad" {
+				pErr := roachpb.NewErrorf("key %q does not contain an integer value", req.Key)
+				pErr.SetErrorIndex(int32(i))
+				return nil, pErr
+			}
+		}
+		br := ba.CreateReply()
+		for i, ru := range ba.Requests {
+			req := ru.GetIncrement()
+			counters[string(req.Key)] += req.Increment
+			br.Responses[i].GetIncrement().NewValue = counters[string(req.Key)]
+		}
+		return br, nil
+	}
+	clock := hlc.NewClock(hlc.UnixNano, time.Nanosecond)
+	db := client.NewDB(
+		testutils.MakeAmbientCtx(), client.NonTransactionalFactoryFunc(sender), clock)
+
+	kvs, errs, err := db.BulkInc(context.TODO(), []client.Increment{
+		{Key: "a", Delta: 1},
+		{Key: "bad", Delta: 2},
+		{Key: "b", Delta: 3},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if batches != 2 {
+		t.Errorf("expected 2 batches, got %d", batches)
+	}
+	if len(kvs) != 3 || len(errs) != 3 {
+		t.Fatalf("expected 3 results, got %d values and %d errors", len(kvs), len(errs))
+	}
+	for i, key := range []string{"a", "bad", "b"} {
+		checkResult(t, []byte(key), kvs[i].Key)
+	}
+	checkIntResult(t, 1, kvs[0].ValueInt())
+	checkIntResult(t, 13, kvs[2].ValueInt())
+	if errs[0] != nil || errs[2] != nil {
+		t.Errorf("unexpected errors: %v", errs)
+	}
+	if !testutils.IsError(errs[1], "does not contain an integer value") {
+		t.Errorf("unexpected error for \"bad\": %v", errs[1])
+	}
+	if kvs[1].Value != nil {
+		t.Errorf("expected nil value for \"bad\", got %v", kvs[1].Value)
+	}
+
+	// Errors which occur before the batch is sent are returned as is.
+	batches = 0
+	if _, _, err := db.BulkInc(context.TODO(), []client.Increment{
+		{Key: "a", Delta: 1},
+		{Key: 1, Delta: 2},
+	}); !testutils.IsError(err, "unable to marshal key") {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if batches != 0 {
+		t.Errorf("expected no batches, got %d", batches)
+	}
+}
+
+// countingLimiter is a client.Limiter which records its use.
+type countingLimiter struct {
+	begun, finished int
+}
+
+func (l *countingLimiter) Begin(context.Context) error {
+	l.begun++
+	return nil
+}
+
+func (l *countingLimiter) Finish() { l.finished++ }
+
+func TestDB_RunLimited(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	blocked := make(chan struct{})
+	unblock := make(chan struct{})
+	var sent []string
+	var mu syncutil.Mutex
+	sender := func(
+		_ context.Context, ba roachpb.BatchRequest,
+	) (*roachpb.BatchResponse, *roachpb.Error) {
+		key := string(ba.Requests[0].GetInner().Header().Key)
+		mu.Lock()
+		sent = append(sent, key)
+		mu.Unlock()
+		if key == "block" {
+			close(blocked)
+			<-unblock
+		}
+		return ba.CreateReply(), nil
+	}
+	clock := hlc.NewClock(hlc.UnixNano, time.Nanosecond)
+	defaultLimiter := &countingLimiter{}
+	dbCtx := client.DefaultDBContext()
+	dbCtx.DefaultConcurrencyLimiter = defaultLimiter
+	db := client.NewDBWithContext(
+		testutils.MakeAmbientCtx(), client.NonTransactionalFactoryFunc(sender), clock, dbCtx)
+
+	if err := db.Put(context.TODO(), "a", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if defaultLimiter.begun != 1 || defaultLimiter.finished != 1 {
+		t.Fatalf("expected the default limiter to be used once, got %+v", defaultLimiter)
+	}
+
+	limiter := limit.MakeConcurrentRequestLimiter("test", 1)
+	errCh := make(chan error, 1)
+	go func() {
+		b := &client.Batch{}
+		b.Put("block", "1")
+		errCh <- db.RunLimited(context.TODO(), b, &limiter)
+	}()
+	<-blocked
+
+	// The only slot is taken, so the batch must wait until its context is
+	// canceled and must not be sent.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	b := &client.Batch{}
+	b.Put("b", "1")
+	if err := db.RunLimited(ctx, b, &limiter); err != context.DeadlineExceeded {
+		t.Fatalf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+
+	close(unblock)
+	if err := <-errCh; err != nil {
+		t.Fatal(err)
+	}
+	b = &client.Batch{}
+	b.Put("c", "1")
+	if err := db.RunLimited(context.TODO(), b, &limiter); err != nil {
+		t.Fatal(err)
+	}
+	if defaultLimiter.begun != 1 || defaultLimiter.finished != 1 {
+		t.Fatalf("expected RunLimited not to use the default limiter, got %+v", defaultLimiter)
+	}
+	mu.Lock()
+	if expected := []string{"a", "block", "c"}; !reflect.DeepEqual(sent, expected) {
+		t.Errorf("expected %v to be sent, got %v", expected, sent)
+	}
+	mu.Unlock()
+
+	// Passing the default limiter to RunLimited must only take one of its
+	// slots, or a limiter with a single slot would deadlock.
+	dbCtx.DefaultConcurrencyLimiter = &limiter
+	db = client.NewDBWithContext(
+		testutils.MakeAmbientCtx(), client.NonTransactionalFactoryFunc(sender), clock, dbCtx)
+	b = &client.Batch{}
+	b.Put("d", "1")
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := db.RunLimited(ctx, b, &limiter); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDB_MaxBatchSize(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	var sent int
+	sender := func(
+		_ context.Context, ba roachpb.BatchRequest,
+	) (*roachpb.BatchResponse, *roachpb.Error) {
+		sent++
+		return ba.CreateReply(), nil
+	}
+	clock := hlc.NewClock(hlc.UnixNano, time.Nanosecond)
+	dbCtx := client.DefaultDBContext()
+	dbCtx.MaxBatchRequests = 3
+	dbCtx.MaxBatchBytes = 100
+	db := client.NewDBWithContext(
+		testutils.MakeAmbientCtx(), client.NonTransactionalFactoryFunc(sender), clock, dbCtx)
+	ctx := context.TODO()
+
+	testCases := []struct {
+		keys  int
+		value string
+		err   string
+	}{
+		{3, "small", ""},
+		{4, "small", "batch of 4 requests exceeds the limit of 3 requests"},
+		{1, strings.Repeat("x", 100), "batch of 1 requests exceeds the limit of 100 bytes"},
+	}
+	for i, tc := range testCases {
+		sent = 0
+		b := &client.Batch{}
+		for j := 0; j < tc.keys; j++ {
+			b.Put(fmt.Sprintf("k%d", j), tc.value)
+		}
+		err := db.Run(ctx, b)
+		if !testutils.IsError(err, tc.err) {
+			t.Errorf("%d: expected error %q, got %v", i, tc.err, err)
+		}
+		if (sent == 1) != (tc.err == "") {
+			t.Errorf("%d: unexpected number of batches sent: %d", i, sent)
+		}
+	}
+}
+
+type prefixRewriter struct {
+	prefix roachpb.Key
+}
+
+func (r prefixRewriter) Encode(k roachpb.Key) roachpb.Key {
+	return append(r.prefix[:len(r.prefix):len(r.prefix)], k...)
+}
+
+func (r prefixRewriter) Decode(k roachpb.Key) roachpb.Key {
+	return bytes.TrimPrefix(k, r.prefix)
+}
+
+func TestDB_KeyRewriter(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	prefix := roachpb.Key("tenant/")
+	var sentSpans []roachpb.Span
+	sender := func(
+		_ context.Context, ba roachpb.BatchRequest,
+	) (*roachpb.BatchResponse, *roachpb.Error) {
+		br := ba.CreateReply()
+		for i, ru := range ba.Requests {
+			h := ru.GetInner().Header()
+			sentSpans = append(sentSpans, roachpb.Span{Key: h.Key, EndKey: h.EndKey})
+			if put, ok := ru.GetInner().(*roachpb.PutRequest); ok {
+				if err := put.Value.Verify(put.Key); err != nil {
+					return nil, roachpb.NewError(err)
+				}
+			}
+			// The returned values are checksummed against the encoded keys, as
+			// they would be by the KV layer.
+			value := roachpb.MakeValueFromString("v")
+			value.InitChecksum(h.Key)
+			switch req := ru.GetInner().(type) {
+			case *roachpb.GetRequest:
+				br.Responses[i].GetInner().(*roachpb.GetResponse).Value = &value
+			case *roachpb.ScanRequest:
+				reply := br.Responses[i].GetInner().(*roachpb.ScanResponse)
+				reply.Rows = []roachpb.KeyValue{{Key: req.Key, Value: value}}
+				reply.ResumeSpan = &roachpb.Span{Key: req.Key.Next(), EndKey: req.EndKey}
+			}
+		}
+		return br, nil
+	}
+	clock := hlc.NewClock(hlc.UnixNano, time.Nanosecond)
+	dbCtx := client.DefaultDBContext()
+	dbCtx.KeyRewriter = prefixRewriter{prefix: prefix}
+	db := client.NewDBWithContext(
+		testutils.MakeAmbientCtx(), client.NonTransactionalFactoryFunc(sender), clock, dbCtx)
+
+	b := &client.Batch{}
+	b.Get("a")
+	b.Scan("b", roachpb.Key("b").PrefixEnd())
+	b.Put("d", "1")
+	if err := db.Run(context.TODO(), b); err != nil {
+		t.Fatal(err)
+	}
+
+	expSpans := []roachpb.Span{
+		{Key: roachpb.Key("tenant/a")},
+		{Key: roachpb.Key("tenant/b"), EndKey: roachpb.Key("tenant/c")},
+		{Key: roachpb.Key("tenant/d")},
+	}
+	if !reflect.DeepEqual(expSpans, sentSpans) {
+		t.Errorf("expected spans %s to be sent, got %s", expSpans, sentSpans)
+	}
+	for i, key := range []roachpb.Key{roachpb.Key("a"), roachpb.Key("b")} {
+		row := b.Results[i].Rows[0]
+		if !row.Key.Equal(key) {
+			t.Errorf("%d: expected key %q, got %q", i, key, row.Key)
+		}
+		if err := row.Value.Verify(key); err != nil {
+			t.Errorf("%d: %s", i, err)
+		}
+	}
+	expResume := roachpb.Span{Key: roachpb.Key("b").Next(), EndKey: roachpb.Key("c")}
+	if resume := b.Results[1].ResumeSpan; !resume.Equal(expResume) {
+		t.Errorf("expected resume span %s, got %s", expResume, resume)
+	}
+
+	// Requests carrying keys outside of their header are rejected.
+	sentSpans = nil
+	b = &client.Batch{}
+	b.AddRawRequest(&roachpb.AddSSTableRequest{
+		RequestHeader: roachpb.RequestHeader{Key: roachpb.Key("a"), EndKey: roachpb.Key("b")},
+	})
+	err := db.Run(context.TODO(), b)
+	if !testutils.IsError(err, "can't be sent with a KeyRewriter") {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if len(sentSpans) != 0 {
+		t.Errorf("expected nothing to be sent, got %s", sentSpans)
+	}
+
+	// Transactional writes are rewritten too.
+	var putKeys []roachpb.Key
+	txnDB := client.NewDBWithContext(testutils.MakeAmbientCtx(), mockTxnFactory(
+		func(ba roachpb.BatchRequest) (*roachpb.BatchResponse, *roachpb.Error) {
+			for _, ru := range ba.Requests {
+				if put, ok := ru.GetInner().(*roachpb.ConditionalPutRequest); ok {
+					putKeys = append(putKeys, put.Key)
+					if err := put.Value.Verify(put.Key); err != nil {
+						return nil, roachpb.NewError(err)
+					}
+				}
+			}
+			return ba.CreateReply(), nil
+		}), clock, dbCtx)
+	if err := txnDB.Txn(context.TODO(), func(ctx context.Context, txn *client.Txn) error {
+		return txn.CPut(ctx, "b", "3", "2")
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if exp := []roachpb.Key{roachpb.Key("tenant/b")}; !reflect.DeepEqual(exp, putKeys) {
+		t.Errorf("expected puts to %s, got %s", exp, putKeys)
+	}
+}
+
+func TestDB_ScanSortedByValue(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	var maxSpanRequestKeys int64
+	sender := func(
+		_ context.Context, ba roachpb.BatchRequest,
+	) (*roachpb.BatchResponse, *roachpb.Error) {
+		maxSpanRequestKeys = ba.MaxSpanRequestKeys
+		br := ba.CreateReply()
+		reply := br.Responses[0].GetInner().(*roachpb.ScanResponse)
+		for _, kv := range []struct{ key, value string }{
+			{"a", "3"}, {"b", "1"}, {"c", "2"}, {"d", "1"},
+		} {
+			reply.Rows = append(reply.Rows, roachpb.KeyValue{
+				Key: roachpb.Key(kv.key), Value: roachpb.MakeValueFromString(kv.value),
+			})
+		}
+		return br, nil
+	}
+	clock := hlc.NewClock(hlc.UnixNano, time.Nanosecond)
+	db := client.NewDB(testutils.MakeAmbientCtx(), client.NonTransactionalFactoryFunc(sender), clock)
+
+	rows, err := db.ScanSortedByValue(context.TODO(), "a", "e", 4, func(a, b client.KeyValue) bool {
+		return bytes.Compare(a.ValueBytes(), b.ValueBytes()) < 0
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if maxSpanRequestKeys != 4 {
+		t.Errorf("expected scan to be bounded to 4 keys, got %d", maxSpanRequestKeys)
+	}
+	var keys []string
+	for _, row := range rows {
+		keys = append(keys, string(row.Key))
+	}
+	if exp := []string{"b", "d", "c", "a"}; !reflect.DeepEqual(exp, keys) {
+		t.Errorf("expected keys %s, got %s", exp, keys)
+	}
+}
+
+func TestBatch_PartialResults(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	// The sender responds to the first request and blocks on the second one
+	// until the batch's timeout fires.
+	sender := func(
+		ctx context.Context, ba roachpb.BatchRequest,
+	) (*roachpb.BatchResponse, *roachpb.Error) {
+		br := ba.CreateReply()
+		if len(ba.Requests) == 1 {
+			return br, nil
+		}
+		br.Responses[1] = roachpb.ResponseUnion{}
+		<-ctx.Done()
+		return br, roachpb.NewError(ctx.Err())
+	}
+	clock := hlc.NewClock(hlc.UnixNano, time.Nanosecond)
+	db := client.NewDB(testutils.MakeAmbientCtx(), client.NonTransactionalFactoryFunc(sender), clock)
+	ctx := context.TODO()
+
+	b := &client.Batch{}
+	b.Put("a", "1")
+	b.Put("b", "2")
+	b.SetTimeout(10 * time.Millisecond)
+	if err := db.Run(ctx, b); !testutils.IsError(err, `operation "batch" timed out`) {
+		t.Fatalf("expected timeout error, got %v", err)
+	}
+	results, ok := b.PartialResults()
+	if ok {
+		t.Error("expected the batch to be incomplete")
+	}
+	if err := results[0].Err; err != nil {
+		t.Errorf("expected the first put to succeed, got %v", err)
+	}
+	if err := results[1].Err; !testutils.IsError(err, "timed out") {
+		t.Errorf("expected the second put to time out, got %v", err)
+	}
+
+	b = &client.Batch{}
+	b.Put("a", "1")
+	b.SetTimeout(time.Minute)
+	if err := db.Run(ctx, b); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := b.PartialResults(); !ok {
+		t.Error("expected the batch to be complete")
+	}
+
+	// Responses received along with an error other than the timeout don't
+	// make for partial results.
+	sender = func(
+		ctx context.Context, ba roachpb.BatchRequest,
+	) (*roachpb.BatchResponse, *roachpb.Error) {
+		return ba.CreateReply(), roachpb.NewErrorf("boom")
+	}
+	db = client.NewDB(testutils.MakeAmbientCtx(), client.NonTransactionalFactoryFunc(sender), clock)
+	b = &client.Batch{}
+	b.Put("a", "1")
+	b.SetTimeout(time.Minute)
+	if err := db.Run(ctx, b); !testutils.IsError(err, "boom") {
+		t.Fatalf("expected boom, got %v", err)
+	}
+	results, ok = b.PartialResults()
+	if ok {
+		t.Error("expected the batch to be incomplete")
+	}
+	if err := results[0].Err; !testutils.IsError(err, "boom") {
+		t.Errorf("expected the put to fail, got %v", err)
+	}
+}
+
+func TestDB_GetVersioned(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	gcThreshold := hlc.Timestamp{WallTime: 10}
+	sender := func(
+		_ context.Context, ba roachpb.BatchRequest,
+	) (*roachpb.BatchResponse, *roachpb.Error) {
+		if ba.ReadConsistency != roachpb.INCONSISTENT {
+			return nil, roachpb.NewErrorf("unexpected read consistency %s", ba.ReadConsistency)
+		}
+		if ba.Timestamp.Less(gcThreshold) {
+			return nil, roachpb.NewError(&roachpb.BatchTimestampBeforeGCError{
+				Timestamp: ba.Timestamp, Threshold: gcThreshold,
+			})
+		}
+		br := ba.CreateReply()
+		// The key was written at 20.
+		if !ba.Timestamp.Less(hlc.Timestamp{WallTime: 20}) {
+			v := roachpb.MakeValueFromString("v")
+			v.Timestamp = hlc.Timestamp{WallTime: 20}
+			br.Responses[0].GetInner().(*roachpb.GetResponse).Value = &v
+		}
+		return br, nil
+	}
+	clock := hlc.NewClock(hlc.UnixNano, time.Nanosecond)
+	db := client.NewDB(testutils.MakeAmbientCtx(), client.NonTransactionalFactoryFunc(sender), clock)
+	ctx := context.TODO()
+
+	kv, err := db.GetVersioned(ctx, "a", hlc.Timestamp{WallTime: 25})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := kv.ValueBytes(); string(v) != "v" {
+		t.Errorf("expected value %q, got %q", "v", v)
+	}
+	kv, err = db.GetVersioned(ctx, "a", hlc.Timestamp{WallTime: 15})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kv.Exists() {
+		t.Errorf("expected no value, got %s", kv.Value)
+	}
+	if _, err := db.GetVersioned(ctx, "a", hlc.Timestamp{WallTime: 5}); !testutils.IsError(
+		err, "cannot read as of .*: batch timestamp .* must be after replica GC threshold",
+	) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestFaultInjectionSender(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	var sent int
+	wrapped := client.SenderFunc(func(
+		_ context.Context, ba roachpb.BatchRequest,
+	) (*roachpb.BatchResponse, *roachpb.Error) {
+		sent++
+		return ba.CreateReply(), nil
+	})
+	send := func(s client.Sender) *roachpb.Error {
+		var ba roachpb.BatchRequest
+		ba.Add(&roachpb.GetRequest{RequestHeader: roachpb.RequestHeader{Key: roachpb.Key("a")}})
+		_, pErr := s.Send(context.TODO(), ba)
+		return pErr
+	}
+
+	for _, afterSend := range []bool{false, true} {
+		sent = 0
+		s := client.FaultInjectionSender(wrapped, client.FaultConfig{
+			Probability: 1,
+			Error:       &roachpb.AmbiguousResultError{},
+			AfterSend:   afterSend,
+		})
+		pErr := send(s)
+		if _, ok := pErr.GetDetail().(*roachpb.AmbiguousResultError); !ok {
+			t.Errorf("afterSend=%t: expected an ambiguous result error, got %v", afterSend, pErr)
+		}
+		if exp := map[bool]int{false: 0, true: 1}[afterSend]; sent != exp {
+			t.Errorf("afterSend=%t: expected %d batches to be sent, got %d", afterSend, exp, sent)
+		}
+	}
+
+	// Senders with the same seed fail the same batches.
+	failures := func() []bool {
+		s := client.FaultInjectionSender(wrapped, client.FaultConfig{
+			Probability: 0.5,
+			Error:       &roachpb.NotLeaseHolderError{},
+			Seed:        1,
+		})
+		var res []bool
+		for i := 0; i < 20; i++ {
+			res = append(res, send(s) != nil)
+		}
+		return res
+	}
+	first := failures()
+	if second := failures(); !reflect.DeepEqual(first, second) {
+		t.Errorf("expected identical failures, got %v and %v", first, second)
+	}
+	var numFailed int
+	for _, failed := range first {
+		if failed {
+			numFailed++
+		}
+	}
+	if numFailed == 0 || numFailed == len(first) {
+		t.Errorf("expected some but not all batches to fail, got %v", first)
+	}
+
+	latency := 10 * time.Millisecond
+	s := client.FaultInjectionSender(wrapped, client.FaultConfig{Latency: latency})
+	start := timeutil.Now()
+	if pErr := send(s); pErr != nil {
+		t.Fatal(pErr)
+	}
+	if elapsed := timeutil.Since(start); elapsed < latency {
+		t.Errorf("expected the batch to take at least %s, took %s", latency, elapsed)
+	}
+}
+
+func TestDB_IOStats(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	sender := func(
+		_ context.Context, ba roachpb.BatchRequest,
+	) (*roachpb.BatchResponse, *roachpb.Error) {
+		br := ba.CreateReply()
+		for i, ru := range ba.Requests {
+			if get, ok := ru.GetInner().(*roachpb.GetRequest); ok {
+				v := roachpb.MakeValueFromString("value")
+				v.InitChecksum(get.Key)
+				br.Responses[i].GetGet().Value = &v
+			}
+		}
+		return br, nil
+	}
+	clock := hlc.NewClock(hlc.UnixNano, time.Nanosecond)
+	run := func(db *client.DB) *client.Batch {
+		b := &client.Batch{}
+		b.Get("a")
+		b.Get("b")
+		b.Put("c", "value")
+		if err := db.Run(context.TODO(), b); err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+
+	db := client.NewDB(testutils.MakeAmbientCtx(), client.NonTransactionalFactoryFunc(sender), clock)
+	run(db)
+	if stats := db.IOStats(); stats != nil {
+		t.Errorf("expected no stats to be tracked, got %v", stats)
+	}
+
+	dbCtx := client.DefaultDBContext()
+	dbCtx.TrackIOStats = true
+	db = client.NewDBWithContext(
+		testutils.MakeAmbientCtx(), client.NonTransactionalFactoryFunc(sender), clock, dbCtx)
+	b := run(db)
+	stats := db.IOStats()
+	if len(stats) != 2 {
+		t.Fatalf("expected stats for two methods, got %v", stats)
+	}
+	get, put := stats[roachpb.Get.String()], stats[roachpb.Put.String()]
+	if get.Requests != 2 || put.Requests != 1 {
+		t.Errorf("expected 2 gets and 1 put, got %v", stats)
+	}
+	var expGetResponseBytes int64
+	for _, r := range b.RawResponse().Responses[:2] {
+		expGetResponseBytes += int64(r.Size())
+	}
+	if get.RequestBytes <= 0 || put.RequestBytes <= 0 || get.ResponseBytes != expGetResponseBytes {
+		t.Errorf("unexpected sizes %v, expected %d get response bytes", stats, expGetResponseBytes)
+	}
+}
+
+func TestDB_SlowRequestThreshold(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	var mu syncutil.Mutex
+	var slow []string
+	log.Intercept(context.Background(), func(entry log.Entry) {
+		if strings.Contains(entry.Message, "slow batch") {
+			mu.Lock()
+			defer mu.Unlock()
+			slow = append(slow, entry.Message)
+		}
+	})
+	defer log.Intercept(context.Background(), nil)
+
+	var delay time.Duration
+	sender := func(
+		_ context.Context, ba roachpb.BatchRequest,
+	) (*roachpb.BatchResponse, *roachpb.Error) {
+		time.Sleep(delay)
+		return ba.CreateReply(), nil
+	}
+	clock := hlc.NewClock(hlc.UnixNano, time.Nanosecond)
+	dbCtx := client.DefaultDBContext()
+	dbCtx.SlowRequestThreshold = 5 * time.Millisecond
+	db := client.NewDBWithContext(
+		testutils.MakeAmbientCtx(), client.NonTransactionalFactoryFunc(sender), clock, dbCtx)
+	ctx := context.TODO()
+
+	if err := db.Put(ctx, "a", "b"); err != nil {
+		t.Fatal(err)
+	}
+	delay = 10 * time.Millisecond
+	if _, err := db.Scan(ctx, "c", "d", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(slow) != 1 {
+		t.Fatalf("expected the scan to be logged as slow, got %q", slow)
+	}
+	if !strings.Contains(slow[0], "1 Scan on {c-d}") {
+		t.Errorf("expected the scan's method and span to be logged, got %q", slow[0])
+	}
+}
+
+func TestDB_DefaultRoutingPolicy(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	var consistency roachpb.ReadConsistencyType
+	sender := func(
+		_ context.Context, ba roachpb.BatchRequest,
+	) (*roachpb.BatchResponse, *roachpb.Error) {
+		consistency = ba.ReadConsistency
+		return ba.CreateReply(), nil
+	}
+	clock := hlc.NewClock(hlc.UnixNano, time.Nanosecond)
+	dbCtx := client.DefaultDBContext()
+	dbCtx.DefaultRoutingPolicy = client.RoutingPolicyNearestReplica
+	db := client.NewDBWithContext(
+		testutils.MakeAmbientCtx(), client.NonTransactionalFactoryFunc(sender), clock, dbCtx)
+	ctx := context.TODO()
+
+	if _, err := db.Get(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if consistency != roachpb.INCONSISTENT {
+		t.Errorf("expected read to be %s, got %s", roachpb.INCONSISTENT, consistency)
+	}
+	if _, err := db.Scan(ctx, "a", "b", 0); err != nil {
+		t.Fatal(err)
+	}
+	if consistency != roachpb.INCONSISTENT {
+		t.Errorf("expected scan to be %s, got %s", roachpb.INCONSISTENT, consistency)
+	}
+	if err := db.Put(ctx, "a", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if consistency != roachpb.CONSISTENT {
+		t.Errorf("expected write to be %s, got %s", roachpb.CONSISTENT, consistency)
+	}
+	b := &client.Batch{}
+	b.Header.ReadConsistency = roachpb.READ_UNCOMMITTED
+	b.Get("a")
+	if err := db.Run(ctx, b); err != nil {
+		t.Fatal(err)
+	}
+	if consistency != roachpb.READ_UNCOMMITTED {
+		t.Errorf("expected read to be %s, got %s", roachpb.READ_UNCOMMITTED, consistency)
+	}
+	b = &client.Batch{}
+	b.RequireLeaseholder()
+	b.Scan("a", "b")
+	if err := db.Run(ctx, b); err != nil {
+		t.Fatal(err)
+	}
+	if consistency != roachpb.CONSISTENT {
+		t.Errorf("expected pinned scan to be %s, got %s", roachpb.CONSISTENT, consistency)
+	}
+	if _, err := db.ScanChecksum(ctx, "a", "b"); err != nil {
+		t.Fatal(err)
+	}
+	if consistency != roachpb.CONSISTENT {
+		t.Errorf("expected checksum scan to be %s, got %s", roachpb.CONSISTENT, consistency)
+	}
+}
+
+func TestDB_ScanSingleRange(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	// The sender pretends that there is a range boundary at "m".
+	sender := func(
+		_ context.Context, ba roachpb.BatchRequest,
+	) (*roachpb.BatchResponse, *roachpb.Error) {
+		if opts := ba.ScanOptions; opts == nil || !opts.StopAtRangeBoundary {
+			t.Errorf("expected the scan to stop at range boundaries, got %v", opts)
+		}
+		if ba.MaxSpanRequestKeys != 10 {
+			t.Errorf("expected a limit of 10 keys, got %d", ba.MaxSpanRequestKeys)
+		}
+		br := ba.CreateReply()
+		resp := br.Responses[0].GetScan()
+		resp.Rows = []roachpb.KeyValue{
+			{Key: roachpb.Key("b"), Value: roachpb.MakeValueFromString("1")},
+		}
+		resp.ResumeSpan = &roachpb.Span{Key: roachpb.Key("m"), EndKey: roachpb.Key("z")}
+		resp.ResumeReason = roachpb.RESUME_RANGE_BOUNDARY
+		return br, nil
+	}
+	clock := hlc.NewClock(hlc.UnixNano, time.Nanosecond)
+	db := client.NewDB(
+		testutils.MakeAmbientCtx(), client.NonTransactionalFactoryFunc(sender), clock)
+
+	rows, resume, err := db.ScanSingleRange(context.TODO(), "a", "z", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkLen(t, 1, len(rows))
+	checkResult(t, []byte("1"), rows[0].ValueBytes())
+	expected := roachpb.Span{Key: roachpb.Key("m"), EndKey: roachpb.Key("z")}
+	if !expected.Equal(resume) {
+		t.Errorf("expected resume span %s, got %s", expected, resume)
+	}
+}
+
+func TestDB_ScanChan(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	var data []roachpb.KeyValue
+	for i := 0; i < 10; i++ {
+		data = append(data, roachpb.KeyValue{
+			Key:   roachpb.Key(fmt.Sprintf("k%d", i)),
+			Value: roachpb.MakeValueFromString(fmt.Sprintf("v%d", i)),
+		})
+	}
+	var scans int
+	sender := func(
+		_ context.Context, ba roachpb.BatchRequest,
+	) (*roachpb.BatchResponse, *roachpb.Error) {
+		scans++
+		req := ba.Requests[0].GetScan()
+		br := ba.CreateReply()
+		resp := br.Responses[0].GetScan()
+		for _, kv := range data {
+			if int64(len(resp.Rows)) == ba.MaxSpanRequestKeys {
+				break
+			}
+			if req.Span().ContainsKey(kv.Key) {
+				resp.Rows = append(resp.Rows, kv)
+			}
+		}
+		return br, nil
+	}
+	clock := hlc.NewClock(hlc.UnixNano, time.Nanosecond)
+	db := client.NewDB(
+		testutils.MakeAmbientCtx(), client.NonTransactionalFactoryFunc(sender), clock)
+
+	t.Run("all", func(t *testing.T) {
+		rowCh, errCh := db.ScanChan(context.TODO(), "k", "l", client.ScanChanOptions{PageSize: 3})
+		var n int
+		for kv := range rowCh {
+			checkResult(t, data[n].Key, kv.Key)
+			n++
+		}
+		if err := <-errCh; err != nil {
+			t.Fatal(err)
+		}
+		checkLen(t, len(data), n)
+		// Four full pages followed by an empty one.
+		if scans != 4 {
+			t.Errorf("expected 4 scans, got %d", scans)
+		}
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		rowCh, errCh := db.ScanChan(ctx, "k", "l", client.ScanChanOptions{PageSize: 1})
+		<-rowCh
+		cancel()
+		for range rowCh {
+		}
+		if err := <-errCh; err != context.Canceled {
+			t.Fatalf("expected %v, got %v", context.Canceled, err)
+		}
+	})
+}
+
+func TestBatchFromRequests(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	ts := hlc.Timestamp{WallTime: 10}
+	put := &roachpb.PutRequest{
+		RequestHeader: roachpb.RequestHeader{Key: roachpb.Key("a")},
+		Value:         roachpb.MakeValueFromString("1"),
+	}
+	get := &roachpb.GetRequest{RequestHeader: roachpb.RequestHeader{Key: roachpb.Key("b")}}
+	var sent roachpb.BatchRequest
+	sender := func(
+		_ context.Context, ba roachpb.BatchRequest,
+	) (*roachpb.BatchResponse, *roachpb.Error) {
+		sent = ba
+		return ba.CreateReply(), nil
+	}
+	clock := hlc.NewClock(hlc.UnixNano, time.Nanosecond)
+	db := client.NewDB(
+		testutils.MakeAmbientCtx(), client.NonTransactionalFactoryFunc(sender), clock)
+
+	b := client.BatchFromRequests([]roachpb.Request{put, get}, roachpb.Header{Timestamp: ts})
+	// The batch holds copies of the requests.
+	put.Key = roachpb.Key("c")
+	if err := db.Run(context.Background(), b); err != nil {
+		t.Fatal(err)
+	}
+	if sent.Timestamp != ts {
+		t.Errorf("expected the batch to be sent at %s, got %s", ts, sent.Timestamp)
+	}
+	if len(sent.Requests) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(sent.Requests))
+	}
+	if req := sent.Requests[0].GetPut(); req == put || !req.Key.Equal(roachpb.Key("a")) {
+		t.Errorf("expected a copy of the put of a, got %v", req)
+	}
+	if req := sent.Requests[1].GetGet(); req == get || !req.Key.Equal(roachpb.Key("b")) {
+		t.Errorf("expected a copy of the get of b, got %v", req)
+	}
+	if n := len(b.RawResponse().Responses); n != 2 {
+		t.Errorf("expected 2 responses, got %d", n)
+	}
+}
+
+func TestDB_InvalidReadConsistency(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	var sent bool
+	sender := func(
+		_ context.Context, ba roachpb.BatchRequest,
+	) (*roachpb.BatchResponse, *roachpb.Error) {
+		sent = true
+		return ba.CreateReply(), nil
+	}
+	clock := hlc.NewClock(hlc.UnixNano, time.Nanosecond)
+	db := client.NewDB(
+		testutils.MakeAmbientCtx(), client.NonTransactionalFactoryFunc(sender), clock)
+	ctx := context.Background()
+
+	b := &client.Batch{}
+	b.Header.ReadConsistency = roachpb.INCONSISTENT
+	b.Put("a", "1")
+	b.Get("a")
+	b.Del("b")
+	b.Put("c", "2")
+	err := db.Run(ctx, b)
+	icErr, ok := err.(*client.InvalidReadConsistencyError)
+	if !ok {
+		t.Fatalf("expected an *InvalidReadConsistencyError, got %T: %v", err, err)
+	}
+	if icErr.ReadConsistency != roachpb.INCONSISTENT {
+		t.Errorf("expected INCONSISTENT, got %s", icErr.ReadConsistency)
+	}
+	if exp := []roachpb.Method{roachpb.Put, roachpb.Delete}; !reflect.DeepEqual(icErr.Methods, exp) {
+		t.Errorf("expected methods %v, got %v", exp, icErr.Methods)
+	}
+	if exp := "methods [Put Delete] not allowed with INCONSISTENT batch"; err.Error() != exp {
+		t.Errorf("expected %q, got %q", exp, err.Error())
+	}
+	if b.Results[0].Err == nil {
+		t.Error("expected the results to carry the error")
+	}
+	if sent {
+		t.Error("expected the batch not to be sent")
+	}
+
+	// Batches whose requests are all supported are sent as usual.
+	b = &client.Batch{}
+	b.Header.ReadConsistency = roachpb.INCONSISTENT
+	b.Get("a")
+	b.Scan("a", "b")
+	if err := db.Run(ctx, b); err != nil {
+		t.Fatal(err)
+	}
+	if !sent {
+		t.Error("expected the batch to be sent")
+	}
+}
+
+func TestDB_Prefetch(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	scanned := make(chan roachpb.BatchRequest, 1)
+	var fail bool
+	sender := func(
+		_ context.Context, ba roachpb.BatchRequest,
+	) (*roachpb.BatchResponse, *roachpb.Error) {
+		shouldFail := fail
+		scanned <- ba
+		if shouldFail {
+			return nil, roachpb.NewErrorf("boom")
+		}
+		return ba.CreateReply(), nil
+	}
+	clock := hlc.NewClock(hlc.UnixNano, time.Nanosecond)
+	dbCtx := client.DefaultDBContext()
+	stopper := stop.NewStopper()
+	defer stopper.Stop(context.TODO())
+	dbCtx.Stopper = stopper
+	db := client.NewDBWithContext(
+		testutils.MakeAmbientCtx(), client.NonTransactionalFactoryFunc(sender), clock, dbCtx)
+
+	// The read is issued even though the caller's context is already
+	// canceled, since it isn't tied to it.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	db.Prefetch(ctx, "a", "b")
+	ba := <-scanned
+	req := ba.Requests[0].GetScan()
+	if req == nil {
+		t.Fatalf("expected a scan, got %s", ba)
+	}
+	checkResult(t, roachpb.Key("a"), req.Key)
+	checkResult(t, roachpb.Key("b"), req.EndKey)
+	if ba.MaxSpanRequestKeys <= 0 {
+		t.Errorf("expected a bounded scan, got limit %d", ba.MaxSpanRequestKeys)
+	}
+
+	// Errors are not surfaced.
+	fail = true
+	db.Prefetch(context.TODO(), "a", "b")
+	<-scanned
+}
+
+// TestDB_ScanResumeBoundary checks that ReverseScanForEach doesn't emit a key
+// twice when a page is resumed at a boundary which includes the last key of
+// the previous page.
+func TestDB_ScanResumeBoundary(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	var keys []roachpb.Key
+	for i := 0; i < 10; i++ {
+		keys = append(keys, roachpb.Key(fmt.Sprintf("k%d", i)))
+	}
+	const pageSize = 3
+	sender := func(
+		_ context.Context, ba roachpb.BatchRequest,
+	) (*roachpb.BatchResponse, *roachpb.Error) {
+		br := ba.CreateReply()
+		req := ba.Requests[0].GetReverseScan()
+		resp := br.Responses[0].GetReverseScan()
+		for i := len(keys) - 1; i >= 0; i-- {
+			if !req.Span().ContainsKey(keys[i]) {
+				continue
+			}
+			if int64(len(resp.Rows)) == pageSize {
+				// Mistakenly resume at a span which includes the last key.
+				resp.ResumeSpan = &roachpb.Span{
+					Key:    req.Key,
+					EndKey: resp.Rows[len(resp.Rows)-1].Key.Next(),
+				}
+				break
+			}
+			resp.Rows = append(resp.Rows, roachpb.KeyValue{Key: keys[i]})
+		}
+		return br, nil
+	}
+	clock := hlc.NewClock(hlc.UnixNano, time.Nanosecond)
+	db := client.NewDB(
+		testutils.MakeAmbientCtx(), client.NonTransactionalFactoryFunc(sender), clock)
+	ctx := context.TODO()
+
+	next := len(keys) - 1
+	if err := db.ReverseScanForEach(ctx, "k", "l", func(kv client.KeyValue) error {
+		if next < 0 || !kv.Key.Equal(keys[next]) {
+			return errors.Errorf("unexpected key %s, expected key %d", kv.Key, next)
+		}
+		next--
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if next != -1 {
+		t.Fatalf("expected all keys to be visited, stopped before key %d", next)
+	}
+}
+
+func TestDB_ScanChecksum(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	var data []roachpb.KeyValue
+	for i := 0; i < 10; i++ {
+		data = append(data, roachpb.KeyValue{
+			Key:   roachpb.Key(fmt.Sprintf("k%d", i)),
+			Value: roachpb.MakeValueFromString(fmt.Sprintf("v%d", i)),
+		})
+	}
+	// pageSize emulates ranges which return fewer rows than requested.
+	var pageSize int
+	sender := func(
+		_ context.Context, ba roachpb.BatchRequest,
+	) (*roachpb.BatchResponse, *roachpb.Error) {
+		req := ba.Requests[0].GetScan()
+		br := ba.CreateReply()
+		resp := br.Responses[0].GetScan()
+		for _, kv := range data {
+			if !req.Span().ContainsKey(kv.Key) {
+				continue
+			}
+			if len(resp.Rows) == pageSize {
+				resp.ResumeSpan = &roachpb.Span{Key: kv.Key, EndKey: req.EndKey}
+				break
+			}
+			resp.Rows = append(resp.Rows, kv)
+		}
+		return br, nil
+	}
+	clock := hlc.NewClock(hlc.UnixNano, time.Nanosecond)
+	db := client.NewDB(
+		testutils.MakeAmbientCtx(), client.NonTransactionalFactoryFunc(sender), clock)
+	ctx := context.TODO()
+
+	checksum := func() uint64 {
+		t.Helper()
+		sum, err := db.ScanChecksum(ctx, "k", "l")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sum
+	}
+	pageSize = len(data)
+	expected := checksum()
+	if expected == 0 {
+		t.Fatal("expected a non-zero checksum")
+	}
+	for _, pageSize = range []int{1, 3, 7} {
+		if sum := checksum(); sum != expected {
+			t.Errorf("page size %d: expected checksum %x, got %x", pageSize, expected, sum)
+		}
+	}
+
+	// The value checksums don't contribute to the checksum.
+	data[2].Value.InitChecksum(data[2].Key)
+	if sum := checksum(); sum != expected {
+		t.Errorf("expected checksum %x with value checksums, got %x", expected, sum)
+	}
+
+	// Changing the data changes the checksum.
+	data[2].Value.SetString("changed")
+	if sum := checksum(); sum == expected {
+		t.Errorf("expected checksum to change with the data")
+	}
+	data[2].Value.SetString("v2")
+
+	// Swapping the values of two keys changes the checksum.
+	data[3].Value, data[4].Value = data[4].Value, data[3].Value
+	if sum := checksum(); sum == expected {
+		t.Errorf("expected checksum to change when values move between keys")
+	}
+}
+
+func TestDB_DelRangeInBatches(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	var keys []roachpb.Key
+	var chunks []int
+	var onChunk func()
+	sender := func(
+		_ context.Context, ba roachpb.BatchRequest,
+	) (*roachpb.BatchResponse, *roachpb.Error) {
+		req := ba.Requests[0].GetDeleteRange()
+		br := ba.CreateReply()
+		resp := br.Responses[0].GetDeleteRange()
+		var remaining []roachpb.Key
+		for _, k := range keys {
+			if !req.Span().ContainsKey(k) {
+				remaining = append(remaining, k)
+				continue
+			}
+			if int64(len(resp.Keys)) == ba.MaxSpanRequestKeys {
+				if resp.ResumeSpan == nil {
+					resp.ResumeSpan = &roachpb.Span{Key: k, EndKey: req.EndKey}
+				}
+				remaining = append(remaining, k)
+				continue
+			}
+			resp.Keys = append(resp.Keys, k)
+		}
+		keys = remaining
+		chunks = append(chunks, len(resp.Keys))
+		if onChunk != nil {
+			onChunk()
+		}
+		return br, nil
+	}
+	clock := hlc.NewClock(hlc.UnixNano, time.Nanosecond)
+	db := client.NewDB(
+		testutils.MakeAmbientCtx(), client.NonTransactionalFactoryFunc(sender), clock)
+	reset := func() {
+		keys = nil
+		for i := 0; i < 10; i++ {
+			keys = append(keys, roachpb.Key(fmt.Sprintf("k%d", i)))
+		}
+		keys = append(keys, roachpb.Key("l"))
+		chunks = nil
+	}
+
+	reset()
+	deleted, err := db.DelRangeInBatches(context.TODO(), "k", "l", 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 10 {
+		t.Errorf("expected 10 keys to be deleted, got %d", deleted)
+	}
+	if expected := []int{4, 4, 2}; !reflect.DeepEqual(expected, chunks) {
+		t.Errorf("expected chunks %v, got %v", expected, chunks)
+	}
+	if len(keys) != 1 || !keys[0].Equal(roachpb.Key("l")) {
+		t.Errorf("expected only the key outside of the span to remain, got %v", keys)
+	}
+
+	// Canceling the context stops the deletion between chunks.
+	reset()
+	ctx, cancel := context.WithCancel(context.Background())
+	onChunk = cancel
+	deleted, err = db.DelRangeInBatches(ctx, "k", "l", 4)
+	if err != context.Canceled {
+		t.Errorf("expected %v, got %v", context.Canceled, err)
+	}
+	if deleted != 4 || len(chunks) != 1 {
+		t.Errorf("expected a single chunk of 4 keys to be deleted, got %d keys in %v", deleted, chunks)
+	}
+
+	_, err = db.DelRangeInBatches(context.TODO(), "k", "l", 0)
+	if !testutils.IsError(err, "batch size must be positive") {
+		t.Errorf("expected a batch size error, got %v", err)
+	}
+}
+
+func TestDB_ReverseScanForEach(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	const numKeys = 10000
+	keys := make([]roachpb.Key, numKeys)
+	for i := range keys {
+		keys[i] = roachpb.Key(fmt.Sprintf("k%05d", i))
+	}
+	var pages int
+	sender := func(
+		_ context.Context, ba roachpb.BatchRequest,
+	) (*roachpb.BatchResponse, *roachpb.Error) {
+		pages++
+		req := ba.Requests[0].GetReverseScan()
+		br := ba.CreateReply()
+		resp := br.Responses[0].GetReverseScan()
+		for i := len(keys) - 1; i >= 0; i-- {
+			if !req.Span().ContainsKey(keys[i]) {
+				continue
+			}
+			if int64(len(resp.Rows)) == ba.MaxSpanRequestKeys {
+				resp.ResumeSpan = &roachpb.Span{
+					Key:    req.Key,
+					EndKey: resp.Rows[len(resp.Rows)-1].Key,
+				}
+				break
+			}
+			resp.Rows = append(resp.Rows, roachpb.KeyValue{
+				Key: keys[i], Value: roachpb.MakeValueFromString("v"),
+			})
+		}
+		return br, nil
+	}
+	clock := hlc.NewClock(hlc.UnixNano, time.Nanosecond)
+	db := client.NewDB(
+		testutils.MakeAmbientCtx(), client.NonTransactionalFactoryFunc(sender), clock)
+	ctx := context.TODO()
+
+	// Walk the whole span, checking that no key is skipped or repeated across
+	// page boundaries.
+	next := numKeys - 1
+	if err := db.ReverseScanForEach(ctx, "k", "l", func(kv client.KeyValue) error {
+		if next < 0 || !kv.Key.Equal(keys[next]) {
+			return errors.Errorf("expected key %d, got %s", next, kv.Key)
+		}
+		next--
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if next != -1 {
+		t.Fatalf("expected all keys to be visited, stopped before key %d", next)
+	}
+	if pages != numKeys/1000 {
+		t.Errorf("expected %d pages, got %d", numKeys/1000, pages)
+	}
+
+	// The sentinel error stops the iteration without an error.
+	var visited int
+	if err := db.ReverseScanForEach(ctx, keys[10], keys[5000], func(client.KeyValue) error {
+		visited++
+		if visited == 1500 {
+			return client.ErrStopIteration
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if visited != 1500 {
+		t.Errorf("expected 1500 keys to be visited, got %d", visited)
+	}
+
+	// Other errors are returned.
+	errBoom := errors.New("boom")
+	if err := db.ReverseScanForEach(ctx, "k", "l", func(client.KeyValue) error {
+		return errBoom
+	}); err != errBoom {
+		t.Errorf("expected %v, got %v", errBoom, err)
+	}
+}
+
+func TestDB_ScanFiltered(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	data := []roachpb.KeyValue{
+		{Key: roachpb.Key("a1"), Value: roachpb.MakeValueFromString("x")},
+		{Key: roachpb.Key("a2"), Value: roachpb.MakeValueFromString("xxxx")},
+		{Key: roachpb.Key("b1"), Value: roachpb.MakeValueFromString("x")},
+		{Key: roachpb.Key("c1"), Value: roachpb.MakeValueFromString("xxxx")},
+		{Key: roachpb.Key("c2"), Value: roachpb.MakeValueFromString("x")},
+	}
+	// The sender returns at most two rows per batch, as if the batches were
+	// limited by range boundaries, to exercise the pagination.
+	var scanned []roachpb.Span
+	sender := func(
+		_ context.Context, ba roachpb.BatchRequest,
+	) (*roachpb.BatchResponse, *roachpb.Error) {
+		if ba.MaxSpanRequestKeys == 0 {
+			t.Errorf("expected a limited batch")
+		}
+		br := ba.CreateReply()
+		var n int
+		for i, ru := range ba.Requests {
+			req := ru.GetScan()
+			scanned = append(scanned, req.Span())
+			resp := br.Responses[i].GetScan()
+			for _, kv := range data {
+				if !req.Span().ContainsKey(kv.Key) {
+					continue
+				}
+				if n == 2 {
+					resp.ResumeSpan = &roachpb.Span{Key: kv.Key, EndKey: req.EndKey}
+					break
+				}
+				resp.Rows = append(resp.Rows, kv)
+				n++
+			}
+		}
+		return br, nil
+	}
+	clock := hlc.NewClock(hlc.UnixNano, time.Nanosecond)
+	db := client.NewDB(
+		testutils.MakeAmbientCtx(), client.NonTransactionalFactoryFunc(sender), clock)
+
+	testCases := []struct {
+		filter   client.ScanFilter
+		expected []string
+		spans    int
+	}{
+		{client.ScanFilter{}, []string{"a1", "a2", "b1", "c1"}, 2},
+		{
+			client.ScanFilter{Prefixes: []roachpb.Key{roachpb.Key("c"), roachpb.Key("a")}},
+			[]string{"a1", "a2", "c1"}, 3,
+		},
+		{client.ScanFilter{Prefixes: []roachpb.Key{roachpb.Key("a"), roachpb.Key("a2")}}, []string{"a1", "a2"}, 1},
+		{client.ScanFilter{Prefixes: []roachpb.Key{roachpb.Key("d")}}, nil, 0},
+	}
+	for i, tc := range testCases {
+		scanned = nil
+		rows, err := db.ScanFiltered(context.TODO(), "a", "c2", tc.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var keys []string
+		for _, kv := range rows {
+			keys = append(keys, string(kv.Key))
+		}
+		if !reflect.DeepEqual(keys, tc.expected) {
+			t.Errorf("%d: expected %v, got %v", i, tc.expected, keys)
+		}
+		if len(scanned) != tc.spans {
+			t.Errorf("%d: expected %d scanned spans, got %v", i, tc.spans, scanned)
+		}
+	}
+}
+
+func TestDB_DelReturningExisted(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	data := map[string]bool{"a": true, "c": true}
+	sender := func(
+		_ context.Context, ba roachpb.BatchRequest,
+	) (*roachpb.BatchResponse, *roachpb.Error) {
+		br := ba.CreateReply()
+		for i, ru := range ba.Requests {
+			req := ru.GetDeleteRange()
+			if !req.ReturnKeys {
+				return nil, roachpb.NewErrorf("expected ReturnKeys to be set")
+			}
+			for k := range data {
+				if req.Span().ContainsKey(roachpb.Key(k)) {
+					delete(data, k)
+					resp := br.Responses[i].GetDeleteRange()
+					resp.Keys = append(resp.Keys, roachpb.Key(k))
+				}
+			}
+		}
+		return br, nil
+	}
+	clock := hlc.NewClock(hlc.UnixNano, time.Nanosecond)
+	db := client.NewDB(
+		testutils.MakeAmbientCtx(), client.NonTransactionalFactoryFunc(sender), clock)
+
+	existed, err := db.DelReturningExisted(context.TODO(), "c", "b", "a", "a\x00", "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []bool{true, false, true, false, false}; !reflect.DeepEqual(existed, expected) {
+		t.Errorf("expected %v, got %v", expected, existed)
+	}
+	if len(data) != 0 {
+		t.Errorf("expected all keys to be deleted, found %v", data)
+	}
+}
+
+// mockTxnFactory returns a TxnSenderFactory whose transactional senders reply
+// to batches with createReply, or with empty responses if it is nil. The
+// status of the transaction is updated by its EndTransaction request.
+func mockTxnFactory(
+	createReply func(roachpb.BatchRequest) (*roachpb.BatchResponse, *roachpb.Error),
+) client.MockTxnSenderFactory {
+	return client.MakeMockTxnSenderFactory(
+		func(
+			_ context.Context, txn *roachpb.Transaction, ba roachpb.BatchRequest,
+		) (*roachpb.BatchResponse, *roachpb.Error) {
+			ba.Txn = txn
+			br := ba.CreateReply()
+			if createReply != nil {
+				var pErr *roachpb.Error
+				if br, pErr = createReply(ba); pErr != nil {
+					return nil, pErr
+				}
+			}
+			br.Txn = txn.Clone()
+			if args, ok := ba.GetArg(roachpb.EndTransaction); ok {
+				br.Txn.Status = roachpb.ABORTED
+				if args.(*roachpb.EndTransactionRequest).Commit {
+					br.Txn.Status = roachpb.COMMITTED
+				}
+			}
+			*txn = *br.Txn
+			return br, nil
+		})
+}
+
+func TestDB_ScanDiff(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	clock := hlc.NewClock(hlc.UnixNano, time.Nanosecond)
+	asOf := hlc.Timestamp{WallTime: 10}
+	kv := func(key, value string) roachpb.KeyValue {
+		return roachpb.KeyValue{Key: roachpb.Key(key), Value: roachpb.MakeValueFromString(value)}
+	}
+	old := []roachpb.KeyValue{kv("a", "1"), kv("b", "2"), kv("c", "3")}
+	current := []roachpb.KeyValue{kv("a", "1"), kv("b", "22"), kv("d", "4")}
+	db := client.NewDB(testutils.MakeAmbientCtx(), mockTxnFactory(
+		func(ba roachpb.BatchRequest) (*roachpb.BatchResponse, *roachpb.Error) {
+			br := ba.CreateReply()
+			scan, ok := ba.GetArg(roachpb.Scan)
+			if !ok {
+				return br, nil
+			}
+			if ba.MaxSpanRequestKeys == 0 {
+				return nil, roachpb.NewErrorf("expected scan to be limited")
+			}
+			rows := current
+			if ba.Txn.OrigTimestamp == asOf {
+				rows = old
+			}
+			// Return a single row at a time to exercise pagination.
+			span := scan.Header().Span()
+			resp := br.Responses[0].GetScan()
+			for _, row := range rows {
+				if !span.ContainsKey(row.Key) {
+					continue
+				}
+				if len(resp.Rows) == 1 {
+					resp.ResumeSpan = &roachpb.Span{Key: row.Key, EndKey: span.EndKey}
+					break
+				}
+				resp.Rows = append(resp.Rows, row)
+			}
+			return br, nil
+		}), clock)
+
+	diffs, err := db.ScanDiff(context.Background(), "a", "z", asOf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pretty := func(v *roachpb.Value) string {
+		if v == nil {
+			return "nil"
+		}
+		s, err := v.GetBytes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(s)
+	}
+	var actual []string
+	for _, d := range diffs {
+		actual = append(actual,
+			fmt.Sprintf("%s:%s->%s", string(d.Key), pretty(d.Old), pretty(d.New)))
+	}
+	expected := []string{"b:2->22", "c:3->nil", "d:nil->4"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+}
+
+func TestDB_Swap(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	clock := hlc.NewClock(hlc.UnixNano, time.Nanosecond)
+	data := map[string]roachpb.Value{}
+	var writeBatches int
+	db := client.NewDB(testutils.MakeAmbientCtx(), mockTxnFactory(
+		func(ba roachpb.BatchRequest) (*roachpb.BatchResponse, *roachpb.Error) {
+			br := ba.CreateReply()
+			if !ba.IsReadOnly() && !ba.IsSingleEndTransactionRequest() {
+				writeBatches++
+			}
+			for i, ru := range ba.Requests {
+				switch req := ru.GetInner().(type) {
+				case *roachpb.GetRequest:
+					if v, ok := data[string(req.Key)]; ok {
+						br.Responses[i].GetGet().Value = &v
+					}
+				case *roachpb.PutRequest:
+					if err := req.Value.Verify(req.Key); err != nil {
+						return nil, roachpb.NewError(err)
+					}
+					data[string(req.Key)] = req.Value
+				case *roachpb.DeleteRequest:
+					delete(data, string(req.Key))
+				}
+			}
+			return br, nil
+		}), clock)
+	ctx := context.Background()
+
+	get := func(key string) string {
+		v, ok := data[key]
+		if !ok {
+			return "<absent>"
+		}
+		b, err := v.GetBytes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+	check := func(expA, expB string) {
+		t.Helper()
+		if a, b := get("a"), get("b"); a != expA || b != expB {
+			t.Errorf("expected a=%s b=%s, got a=%s b=%s", expA, expB, a, b)
+		}
+	}
+
+	data["a"] = roachpb.MakeValueFromString("1")
+	data["b"] = roachpb.MakeValueFromString("2")
+	if err := db.Swap(ctx, "a", "b"); err != nil {
+		t.Fatal(err)
+	}
+	check("2", "1")
+
+	delete(data, "b")
+	if err := db.Swap(ctx, "a", "b"); err != nil {
+		t.Fatal(err)
+	}
+	check("<absent>", "2")
+
+	delete(data, "b")
+	writeBatches = 0
+	if err := db.Swap(ctx, "a", "b"); err != nil {
+		t.Fatal(err)
+	}
+	check("<absent>", "<absent>")
+	if writeBatches != 0 {
+		t.Errorf("expected swapping absent keys not to write, got %d write batches", writeBatches)
+	}
+}
+
+func TestDB_TxnBatch(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	clock := hlc.NewClock(hlc.UnixNano, time.Nanosecond)
+	var batches [][]string
+	db := client.NewDB(testutils.MakeAmbientCtx(), mockTxnFactory(
+		func(ba roachpb.BatchRequest) (*roachpb.BatchResponse, *roachpb.Error) {
+			var calls []string
+			for _, ru := range ba.Requests {
+				calls = append(calls, ru.GetInner().Method().String())
+			}
+			batches = append(batches, calls)
+			br := ba.CreateReply()
+			if args, ok := ba.GetArg(roachpb.Get); ok {
+				for i := range br.Responses {
+					if get, ok := br.Responses[i].GetInner().(*roachpb.GetResponse); ok {
+						get.Value = &roachpb.Value{}
+						get.Value.SetString(string(args.Header().Key))
+					}
+				}
+			}
+			return br, nil
+		}), clock)
+	ctx := context.Background()
+
+	var b *client.Batch
+	if err := db.TxnBatch(ctx, func(batch *client.Batch) {
+		b = batch
+		b.Put("a", "b")
+		b.Get("a")
+	}); err != nil {
+		t.Fatal(err)
+	}
+	expected := [][]string{{"Put", "Get", "EndTransaction"}}
+	if !reflect.DeepEqual(expected, batches) {
+		t.Errorf("expected batches %v, got %v", expected, batches)
+	}
+	if v := b.Results[1].Rows[0].ValueBytes(); string(v) != "a" {
+		t.Errorf("expected the batch's results to be filled in, got %q", v)
+	}
+}
+
+// spanTrackingSenderFactory creates mock transactional senders which report
+// the provided meta, as a TxnCoordSender reports the spans it tracked.
+type spanTrackingSenderFactory struct {
+	client.MockTxnSenderFactory
+	meta roachpb.TxnCoordMeta
+}
+
+type spanTrackingSender struct {
+	*client.MockTransactionalSender
+	meta roachpb.TxnCoordMeta
+}
+
+func (f spanTrackingSenderFactory) TransactionalSender(
+	typ client.TxnType, coordMeta roachpb.TxnCoordMeta,
+) client.TxnSender {
+	return spanTrackingSender{
+		MockTransactionalSender: f.MockTxnSenderFactory.TransactionalSender(
+			typ, coordMeta).(*client.MockTransactionalSender),
+		meta: f.meta,
+	}
+}
+
+func (s spanTrackingSender) GetMeta(
+	context.Context, client.TxnStatusOpt,
+) (roachpb.TxnCoordMeta, error) {
+	return s.meta, nil
+}
+
+func TestDB_TxnWithSpans(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	clock := hlc.NewClock(hlc.UnixNano, time.Nanosecond)
+	ctx := context.Background()
+	read := []roachpb.Span{{Key: roachpb.Key("a"), EndKey: roachpb.Key("c")}}
+	written := []roachpb.Span{{Key: roachpb.Key("b")}}
+
+	for _, refreshInvalid := range []bool{false, true} {
+		factory := spanTrackingSenderFactory{
+			MockTxnSenderFactory: mockTxnFactory(nil),
+			meta: roachpb.TxnCoordMeta{
+				Intents:        written,
+				RefreshReads:   read,
+				RefreshInvalid: refreshInvalid,
+			},
+		}
+		db := client.NewDB(testutils.MakeAmbientCtx(), factory, clock)
+		r, w, err := db.TxnWithSpans(ctx, func(ctx context.Context, txn *client.Txn) error {
+			if _, err := txn.Scan(ctx, "a", "c", 0); err != nil {
+				return err
+			}
+			return txn.Put(ctx, "b", "v")
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expRead := read
+		if refreshInvalid {
+			expRead = nil
+		}
+		if !reflect.DeepEqual(expRead, r) || !reflect.DeepEqual(written, w) {
+			t.Errorf("refreshInvalid=%t: expected spans %v and %v, got %v and %v",
+				refreshInvalid, expRead, written, r, w)
+		}
+	}
+
+	errBoom := errors.New("boom")
+	db := client.NewDB(testutils.MakeAmbientCtx(), mockTxnFactory(nil), clock)
+	if _, _, err := db.TxnWithSpans(ctx, func(context.Context, *client.Txn) error {
+		return errBoom
+	}); err != errBoom {
+		t.Errorf("expected %v, got %v", errBoom, err)
+	}
+}
+
+func TestDB_EstimateDistinctPrefixes(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	// The keys have 4-byte prefixes, e.g. p000/00000, with 100 keys each.
+	var keys []roachpb.Key
+	sender := func(
+		_ context.Context, ba roachpb.BatchRequest,
+	) (*roachpb.BatchResponse, *roachpb.Error) {
+		br := ba.CreateReply()
+		switch req := ba.Requests[0].GetInner().(type) {
+		case *roachpb.ScanRequest:
+			resp := br.Responses[0].GetScan()
+			i := sort.Search(len(keys), func(i int) bool { return keys[i].Compare(req.Key) >= 0 })
+			for ; i < len(keys) && keys[i].Compare(req.EndKey) < 0; i++ {
+				if int64(len(resp.Rows)) == ba.MaxSpanRequestKeys {
+					resp.ResumeSpan = &roachpb.Span{Key: keys[i], EndKey: req.EndKey}
+					break
+				}
+				resp.Rows = append(resp.Rows, roachpb.KeyValue{Key: keys[i]})
+			}
+		case *roachpb.RangeStatsRequest:
+			resp := br.Responses[0].GetRangeStats()
+			resp.RangeInfos = []roachpb.RangeInfo{{Desc: roachpb.RangeDescriptor{
+				RangeID: 1, StartKey: roachpb.RKeyMin, EndKey: roachpb.RKeyMax,
+			}}}
+			resp.MVCCStats.LiveCount = int64(len(keys))
+		default:
+			return nil, roachpb.NewErrorf("unexpected request %s", req)
+		}
+		return br, nil
+	}
+	clock := hlc.NewClock(hlc.UnixNano, time.Nanosecond)
+	db := client.NewDB(
+		testutils.MakeAmbientCtx(), client.NonTransactionalFactoryFunc(sender), clock)
+	setPrefixes := func(n int) {
+		keys = nil
+		for i := 0; i < n; i++ {
+			for j := 0; j < 100; j++ {
+				keys = append(keys, roachpb.Key(fmt.Sprintf("p%03d/%05d", i, j)))
+			}
+		}
+	}
+
+	testCases := []struct {
+		prefixes  int
+		prefixLen int
+		expected  int64
+	}{
+		// The span is sampled entirely.
+		{prefixes: 20, prefixLen: 4, expected: 20},
+		// The keys are shorter than the prefix.
+		{prefixes: 2, prefixLen: 20, expected: 200},
+		// The estimate is extrapolated from the first 10000 keys.
+		{prefixes: 250, prefixLen: 4, expected: 250},
+		{prefixes: 0, prefixLen: 4, expected: 0},
+	}
+	for _, tc := range testCases {
+		setPrefixes(tc.prefixes)
+		n, err := db.EstimateDistinctPrefixes(context.TODO(), "p", "q", tc.prefixLen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The sketch is approximate.
+		if d := n - tc.expected; d < -tc.expected/50 || d > tc.expected/50 {
+			t.Errorf("%d prefixes of %d bytes: expected about %d, got %d",
+				tc.prefixes, tc.prefixLen, tc.expected, n)
+		}
+	}
+
+	_, err := db.EstimateDistinctPrefixes(context.TODO(), "p", "q", 0)
+	if !testutils.IsError(err, "invalid prefix length 0") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestDB_AdminRelocateRangeWithResult(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	replica := func(id int) roachpb.ReplicaDescriptor {
+		return roachpb.ReplicaDescriptor{
+			NodeID:    roachpb.NodeID(id),
+			StoreID:   roachpb.StoreID(id),
+			ReplicaID: roachpb.ReplicaID(id),
+		}
+	}
+	target := func(id int) roachpb.ReplicationTarget {
+		return roachpb.ReplicationTarget{NodeID: roachpb.NodeID(id), StoreID: roachpb.StoreID(id)}
+	}
+	desc := roachpb.RangeDescriptor{
+		RangeID: 1, StartKey: roachpb.RKeyMin, EndKey: roachpb.RKeyMax,
+	}
+	// relocated is the set of replicas the relocation moves the range to, and
+	// relocateErr the error it returns.
+	var relocated []roachpb.ReplicaDescriptor
+	var relocateErr *roachpb.Error
+	var relocations int
+	sender := func(
+		_ context.Context, ba roachpb.BatchRequest,
+	) (*roachpb.BatchResponse, *roachpb.Error) {
+		br := ba.CreateReply()
+		switch {
+		case ba.Requests[0].GetRangeStats() != nil:
+			resp := br.Responses[0].GetRangeStats()
+			resp.RangeInfos = []roachpb.RangeInfo{{Desc: desc}}
+		case ba.Requests[0].GetAdminRelocateRange() != nil:
+			relocations++
+			desc.Replicas = relocated
+			if relocateErr != nil {
+				return nil, relocateErr
+			}
+		default:
+			return nil, roachpb.NewErrorf("unexpected request %s", ba)
+		}
+		return br, nil
+	}
+	clock := hlc.NewClock(hlc.UnixNano, time.Nanosecond)
+	db := client.NewDB(
+		testutils.MakeAmbientCtx(), client.NonTransactionalFactoryFunc(sender), clock)
+	ctx := context.Background()
+
+	desc.Replicas = []roachpb.ReplicaDescriptor{replica(1), replica(2), replica(3)}
+	relocated = []roachpb.ReplicaDescriptor{replica(1), replica(2), replica(4)}
+	res, err := db.AdminRelocateRangeWithResult(
+		ctx, "a", []roachpb.ReplicationTarget{target(4), target(2), target(1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Converged {
+		t.Error("expected the relocation to converge")
+	}
+	before := []roachpb.ReplicaDescriptor{replica(1), replica(2), replica(3)}
+	if !reflect.DeepEqual(res.Before, before) {
+		t.Errorf("expected %v before, got %v", before, res.Before)
+	}
+	if !reflect.DeepEqual(res.After, relocated) {
+		t.Errorf("expected %v after, got %v", relocated, res.After)
+	}
+
+	// A failed relocation reports how far it got.
+	relocated = []roachpb.ReplicaDescriptor{replica(1), replica(4), replica(5)}
+	relocateErr = roachpb.NewErrorf("boom")
+	res, err = db.AdminRelocateRangeWithResult(
+		ctx, "a", []roachpb.ReplicationTarget{target(4), target(5), target(6)})
+	if !testutils.IsError(err, "boom") {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if res.Converged {
+		t.Error("expected the relocation not to converge")
+	}
+	if !reflect.DeepEqual(res.After, relocated) {
+		t.Errorf("expected %v after, got %v", relocated, res.After)
+	}
+
+	// Invalid targets are rejected without relocating the range.
+	relocations = 0
+	if _, err := db.AdminRelocateRangeWithResult(ctx, "a", nil); !testutils.IsError(
+		err, "no relocation targets",
+	) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := db.AdminRelocateRangeWithResult(
+		ctx, "a", []roachpb.ReplicationTarget{target(1), target(2), target(1)},
+	); !testutils.IsError(err, "duplicate relocation target store 1") {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if relocations != 0 {
+		t.Errorf("expected no relocations, got %d", relocations)
+	}
+}
+
+func TestDB_EstimateSpanSize(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	descs := []roachpb.RangeDescriptor{
+		{RangeID: 1, StartKey: roachpb.RKeyMin, EndKey: roachpb.RKey("c")},
+		{RangeID: 2, StartKey: roachpb.RKey("c"), EndKey: roachpb.RKey("f")},
+		{RangeID: 3, StartKey: roachpb.RKey("f"), EndKey: roachpb.RKeyMax},
+	}
+	var requested []roachpb.RangeID
+	sender := func(
+		_ context.Context, ba roachpb.BatchRequest,
+	) (*roachpb.BatchResponse, *roachpb.Error) {
+		if !ba.ReturnRangeInfo {
+			return nil, roachpb.NewErrorf("expected ReturnRangeInfo to be set")
+		}
+		key := ba.Requests[0].GetRangeStats().Key
+		for _, desc := range descs {
+			if !desc.ContainsKey(roachpb.RKey(key)) {
+				continue
+			}
+			requested = append(requested, desc.RangeID)
+			br := ba.CreateReply()
+			resp := br.Responses[0].GetRangeStats()
+			resp.RangeInfos = []roachpb.RangeInfo{{Desc: desc}}
+			resp.MVCCStats.LiveBytes = 100 * int64(desc.RangeID)
+			resp.MVCCStats.LiveCount = int64(desc.RangeID)
+			return br, nil
+		}
+		return nil, roachpb.NewErrorf("no range contains %s", key)
+	}
+	clock := hlc.NewClock(hlc.UnixNano, time.Nanosecond)
+	db := client.NewDB(
+		testutils.MakeAmbientCtx(), client.NonTransactionalFactoryFunc(sender), clock)
+
+	testCases := []struct {
+		begin, end string
+		bytes      int64
+		keys       int64
+		ranges     []roachpb.RangeID
+	}{
+		{"a", "b", 100, 1, []roachpb.RangeID{1}},
+		{"a", "c", 100, 1, []roachpb.RangeID{1}},
+		{"a", "d", 300, 3, []roachpb.RangeID{1, 2}},
+		{"d", "z", 500, 5, []roachpb.RangeID{2, 3}},
+		{"a", "z", 600, 6, []roachpb.RangeID{1, 2, 3}},
+		{"b", "b", 0, 0, nil},
+	}
+	for _, tc := range testCases {
+		requested = nil
+		bytes, keys, err := db.EstimateSpanSize(context.TODO(), tc.begin, tc.end)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes != tc.bytes || keys != tc.keys {
+			t.Errorf("[%s,%s): expected %d bytes and %d keys, got %d and %d",
+				tc.begin, tc.end, tc.bytes, tc.keys, bytes, keys)
+		}
+		if !reflect.DeepEqual(requested, tc.ranges) {
+			t.Errorf("[%s,%s): expected ranges %v, got %v", tc.begin, tc.end, tc.ranges, requested)
+		}
+	}
+}
+
+func TestDB_SuggestSplitKeys(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	// The keys a through t hold 10 bytes each, and are split into two ranges of
+	// 10 keys at k.
+	var data []roachpb.Key
+	for c := 'a'; c <= 't'; c++ {
+		data = append(data, roachpb.Key(string(c)))
+	}
+	descs := []roachpb.RangeDescriptor{
+		{RangeID: 1, StartKey: roachpb.RKeyMin, EndKey: roachpb.RKey("k")},
+		{RangeID: 2, StartKey: roachpb.RKey("k"), EndKey: roachpb.RKeyMax},
+	}
+	var scanned int64
+	sender := func(
+		_ context.Context, ba roachpb.BatchRequest,
+	) (*roachpb.BatchResponse, *roachpb.Error) {
+		br := ba.CreateReply()
+		switch req := ba.Requests[0].GetInner().(type) {
+		case *roachpb.RangeStatsRequest:
+			for _, desc := range descs {
+				if desc.ContainsKey(roachpb.RKey(req.Key)) {
+					resp := br.Responses[0].GetRangeStats()
+					resp.RangeInfos = []roachpb.RangeInfo{{Desc: desc}}
+					resp.MVCCStats.LiveBytes = 100
+					resp.MVCCStats.LiveCount = 10
+					return br, nil
+				}
+			}
+			return nil, roachpb.NewErrorf("no range contains %s", req.Key)
+		case *roachpb.ScanRequest:
+			resp := br.Responses[0].GetScan()
+			for _, k := range data {
+				if k.Compare(req.Key) < 0 || k.Compare(req.EndKey) >= 0 {
+					continue
+				}
+				if int64(len(resp.Rows)) == ba.MaxSpanRequestKeys {
+					resp.ResumeSpan = &roachpb.Span{Key: k, EndKey: req.EndKey}
+					break
+				}
+				resp.Rows = append(resp.Rows, roachpb.KeyValue{Key: k})
+			}
+			scanned += int64(len(resp.Rows))
+			return br, nil
+		}
+		return nil, roachpb.NewErrorf("unexpected request %s", ba)
+	}
+	clock := hlc.NewClock(hlc.UnixNano, time.Nanosecond)
+	db := client.NewDB(
+		testutils.MakeAmbientCtx(), client.NonTransactionalFactoryFunc(sender), clock)
+
+	testCases := []struct {
+		begin   string
+		target  int64
+		splits  []string
+		scanned int64
+	}{
+		// The first range starts before a, so its ten keys are counted. The
+		// chunk boundaries fall on f, on the boundary between the ranges, which
+		// isn't returned, and on p. Only the first six keys of each range need
+		// to be read.
+		{"a", 50, []string{"f", "p"}, 22},
+		{"a", 70, []string{"h", "o"}, 23},
+		{"a", 1000, nil, 10},
+		// Only the five keys from f to j of the first range are in the span.
+		{"f", 50, []string{"p"}, 11},
+		{"f", 30, []string{"i", "l", "o", "r"}, 17},
+		// The span starts at the boundary between the ranges.
+		{"k", 30, []string{"n", "q", "t"}, 10},
+	}
+	for _, tc := range testCases {
+		scanned = 0
+		keys, err := db.SuggestSplitKeys(context.TODO(), tc.begin, "z", tc.target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var splits []string
+		for _, k := range keys {
+			splits = append(splits, string(k))
+		}
+		if !reflect.DeepEqual(tc.splits, splits) {
+			t.Errorf("%s/%d: expected split keys %v, got %v",
+				tc.begin, tc.target, tc.splits, splits)
+		}
+		if scanned != tc.scanned {
+			t.Errorf("%s/%d: expected %d keys to be scanned, got %d",
+				tc.begin, tc.target, tc.scanned, scanned)
+		}
+	}
+}
+
+func TestDB_CheckConsistency(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	descs := []roachpb.RangeDescriptor{
+		{RangeID: 1, StartKey: roachpb.RKeyMin, EndKey: roachpb.RKey("c")},
+		{RangeID: 2, StartKey: roachpb.RKey("c"), EndKey: roachpb.RKey("f")},
+		{RangeID: 3, StartKey: roachpb.RKey("f"), EndKey: roachpb.RKeyMax},
+	}
+	sender := func(
+		_ context.Context, ba roachpb.BatchRequest,
+	) (*roachpb.BatchResponse, *roachpb.Error) {
+		req := ba.Requests[0].GetCheckConsistency()
+		if req.Mode != roachpb.ChecksumMode_CHECK_STATS {
+			return nil, roachpb.NewErrorf("unexpected mode %s", req.Mode)
+		}
+		for _, desc := range descs {
+			if !desc.ContainsKey(roachpb.RKey(req.Key)) {
+				continue
+			}
+			if !desc.ContainsKeyRange(roachpb.RKey(req.Key), roachpb.RKey(req.EndKey)) {
+				return nil, roachpb.NewErrorf("request %s spans multiple ranges", req.Span())
+			}
+			status := roachpb.CheckConsistencyResponse_RANGE_CONSISTENT
+			if desc.RangeID == 2 {
+				status = roachpb.CheckConsistencyResponse_RANGE_INCONSISTENT
+			}
+			br := ba.CreateReply()
+			resp := br.Responses[0].GetCheckConsistency()
+			resp.RangeInfos = []roachpb.RangeInfo{{Desc: desc}}
+			resp.Result = []roachpb.CheckConsistencyResponse_Result{{
+				RangeID:  desc.RangeID,
+				StartKey: desc.StartKey,
+				Status:   status,
+			}}
+			return br, nil
+		}
+		return nil, roachpb.NewErrorf("no range contains %s", req.Key)
+	}
+	clock := hlc.NewClock(hlc.UnixNano, time.Nanosecond)
+	db := client.NewDB(
+		testutils.MakeAmbientCtx(), client.NonTransactionalFactoryFunc(sender), clock)
+	ctx := context.TODO()
+
+	results, err := db.CheckConsistency(ctx, "b", "g", roachpb.ChecksumMode_CHECK_STATS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rangeIDs []roachpb.RangeID
+	for _, res := range results {
+		rangeIDs = append(rangeIDs, res.RangeID)
+	}
+	if expected := []roachpb.RangeID{1, 2, 3}; !reflect.DeepEqual(rangeIDs, expected) {
+		t.Fatalf("expected results for ranges %v, got %v", expected, rangeIDs)
+	}
+	if s := results[1].Status; s != roachpb.CheckConsistencyResponse_RANGE_INCONSISTENT {
+		t.Errorf("expected r2 to be inconsistent, got %s", s)
+	}
+
+	// An error returned by the callback stops the check.
+	var calls int
+	stopErr := errors.New("stop")
+	err = db.CheckConsistencyFn(ctx, "a", "z", roachpb.ChecksumMode_CHECK_STATS,
+		func(roachpb.CheckConsistencyResponse_Result) error {
+			calls++
+			return stopErr
+		})
+	if err != stopErr {
+		t.Fatalf("expected %v, got %v", stopErr, err)
+	}
+	if calls != 1 {
+		t.Errorf("expected the callback to be called once, got %d", calls)
+	}
+}
+
+func TestDB_AdminScatter(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	var req *roachpb.AdminScatterRequest
+	sender := func(
+		_ context.Context, ba roachpb.BatchRequest,
+	) (*roachpb.BatchResponse, *roachpb.Error) {
+		req = ba.Requests[0].GetAdminScatter()
+		br := ba.CreateReply()
+		br.Responses[0].GetAdminScatter().Ranges = []roachpb.AdminScatterResponse_Range{
+			{Span: req.Span()},
+		}
+		return br, nil
+	}
+	clock := hlc.NewClock(hlc.UnixNano, time.Nanosecond)
+	db := client.NewDB(
+		testutils.MakeAmbientCtx(), client.NonTransactionalFactoryFunc(sender), clock)
+
+	resp, err := db.AdminScatter(context.TODO(), "a", "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := roachpb.Span{Key: roachpb.Key("a"), EndKey: roachpb.Key("b")}
+	if !expected.Equal(req.Span()) || !req.RandomizeLeases {
+		t.Errorf("unexpected request %+v", req)
+	}
+	if len(resp.Ranges) != 1 || !expected.Equal(resp.Ranges[0].Span) {
+		t.Errorf("unexpected response %+v", resp)
+	}
+}
+
+func TestCrossRangeTxnWrapperSenderRejectsTxn(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	sender := func(
+		_ context.Context, ba roachpb.BatchRequest,
+	) (*roachpb.BatchResponse, *roachpb.Error) {
+		t.Fatal("unexpected call to the wrapped sender")
+		return nil, nil
+	}
+	clock := hlc.NewClock(hlc.UnixNano, time.Nanosecond)
+	db := client.NewDB(
+		testutils.MakeAmbientCtx(), client.NonTransactionalFactoryFunc(sender), clock)
+
+	var ba roachpb.BatchRequest
+	ba.Txn = &roachpb.Transaction{}
+	ba.Add(roachpb.NewGet(roachpb.Key("a")))
+	_, pErr := db.NonTransactionalSender().Send(context.TODO(), ba)
+	if !testutils.IsPError(pErr, "can't handle transactional requests") {
+		t.Fatalf("unexpected error: %v", pErr)
+	}
+}
+
+func TestDB_WaitForApplication(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	clock := hlc.NewClock(hlc.UnixNano, time.Nanosecond)
+	replicas := []roachpb.ReplicaDescriptor{
+		{NodeID: 1, StoreID: 1, ReplicaID: 1},
+		{NodeID: 2, StoreID: 2, ReplicaID: 2},
+	}
+
+	var mu syncutil.Mutex
+	waited := map[roachpb.StoreID]uint64{}
+	dbCtx := client.DefaultDBContext()
+	dbCtx.ApplicationWaiter = func(
+		ctx context.Context, repl roachpb.ReplicaDescriptor, rangeID roachpb.RangeID, leaseIndex uint64,
+	) error {
+		if rangeID != 7 {
+			return errors.Errorf("unexpected range ID %d", rangeID)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		waited[repl.StoreID] = leaseIndex
+		return nil
+	}
+	factory := client.NonTransactionalFactoryFunc(func(
+		_ context.Context, ba roachpb.BatchRequest,
+	) (*roachpb.BatchResponse, *roachpb.Error) {
+		return nil, roachpb.NewErrorf("unexpected batch %s", ba)
+	})
+	db := client.NewDBWithContext(testutils.MakeAmbientCtx(), factory, clock, dbCtx)
+	if err := db.WaitForApplication(context.TODO(), 7, replicas, 10); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[roachpb.StoreID]uint64{1: 10, 2: 10}
+	if !reflect.DeepEqual(waited, expected) {
+		t.Errorf("expected %v, got %v", expected, waited)
+	}
+
+	db = client.NewDB(testutils.MakeAmbientCtx(), factory, clock)
+	if err := db.WaitForApplication(context.TODO(), 7, replicas, 10); !testutils.IsError(err, "not supported") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestDB_PutBytesBatch(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	written := map[string][]byte{}
+	sender := func(
+		_ context.Context, ba roachpb.BatchRequest,
+	) (*roachpb.BatchResponse, *roachpb.Error) {
+		for _, ru := range ba.Requests {
+			req := ru.GetPut()
+			if err := req.Value.Verify(req.Key); err != nil {
+				return nil, roachpb.NewError(err)
+			}
+			// The checksum must have been computed against the key.
+			if err := req.Value.Verify(req.Key.Next()); err == nil {
+				return nil, roachpb.NewErrorf("no checksum for %s", req.Key)
+			}
+			b, err := req.Value.GetBytes()
+			if err != nil {
+				return nil, roachpb.NewError(err)
+			}
+			written[string(req.Key)] = b
+		}
+		return ba.CreateReply(), nil
+	}
+	clock := hlc.NewClock(hlc.UnixNano, time.Nanosecond)
+	db := client.NewDB(
+		testutils.MakeAmbientCtx(), client.NonTransactionalFactoryFunc(sender), clock)
+
+	entries := []client.BytesEntry{
+		{Key: roachpb.Key("a"), Value: []byte("1")},
+		{Key: roachpb.Key("b"), Value: []byte("2")},
+		{Key: roachpb.Key("c"), Value: nil},
+	}
+	if err := db.PutBytesBatch(context.TODO(), entries); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string][]byte{"a": []byte("1"), "b": []byte("2"), "c": {}}
+	if !reflect.DeepEqual(written, expected) {
+		t.Errorf("expected %q, got %q", expected, written)
+	}
+}
+
+func TestDB_MultiGetProto(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	values := map[string]roachpb.Value{}
+	for _, desc := range []roachpb.RangeDescriptor{{RangeID: 1}, {RangeID: 3}} {
+		var v roachpb.Value
+		if err := v.SetProto(&desc); err != nil {
+			t.Fatal(err)
+		}
+		values[fmt.Sprintf("r%d", desc.RangeID)] = v
+	}
+	var batches int
+	sender := func(
+		_ context.Context, ba roachpb.BatchRequest,
+	) (*roachpb.BatchResponse, *roachpb.Error) {
+		batches++
+		br := ba.CreateReply()
+		for i, ru := range ba.Requests {
+			if v, ok := values[string(ru.GetGet().Key)]; ok {
+				br.Responses[i].GetGet().Value = &v
+			}
+		}
+		return br, nil
+	}
+	clock := hlc.NewClock(hlc.UnixNano, time.Nanosecond)
+	db := client.NewDB(
+		testutils.MakeAmbientCtx(), client.NonTransactionalFactoryFunc(sender), clock)
+	ctx := context.TODO()
+
+	keys := []roachpb.Key{roachpb.Key("r3"), roachpb.Key("r2"), roachpb.Key("r1")}
+	descs := make([]roachpb.RangeDescriptor, len(keys))
+	descs[1].RangeID = 2
+	msgs := []protoutil.Message{&descs[0], &descs[1], &descs[2]}
+	if err := db.MultiGetProto(ctx, keys, msgs); err != nil {
+		t.Fatal(err)
+	}
+	if batches != 1 {
+		t.Errorf("expected a single batch, got %d", batches)
+	}
+	for i, expected := range []roachpb.RangeID{3, 0, 1} {
+		if descs[i].RangeID != expected {
+			t.Errorf("%d: expected r%d, got r%d", i, expected, descs[i].RangeID)
+		}
+	}
+
+	if err := db.MultiGetProto(ctx, keys, msgs[:2]); !testutils.IsError(err, "3 keys but 2 messages") {
+		t.Errorf("expected length mismatch error, got %v", err)
+	}
+}
+
+func TestRunRetryable(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	clock := hlc.NewClock(hlc.UnixNano, time.Nanosecond)
+	db := client.NewDB(testutils.MakeAmbientCtx(), client.NonTransactionalFactoryFunc(
+		func(context.Context, roachpb.BatchRequest) (*roachpb.BatchResponse, *roachpb.Error) {
+			return nil, roachpb.NewErrorf("unexpected batch")
+		}), clock)
+	opts := retry.Options{InitialBackoff: time.Microsecond, MaxBackoff: time.Microsecond}
+	errNonRetryable := errors.New("non-retryable")
+
+	testCases := []struct {
+		errs     []error
+		expected error
+	}{
+		{nil, nil},
+		{[]error{roachpb.NewAmbiguousResultError("boom")}, nil},
+		{[]error{&roachpb.UnhandledRetryableError{}, roachpb.NewAmbiguousResultError("boom")}, nil},
+		{[]error{errNonRetryable}, errNonRetryable},
+		{[]error{roachpb.NewAmbiguousResultError("boom"), errNonRetryable}, errNonRetryable},
+	}
+	for i, tc := range testCases {
+		var attempts int
+		err := client.RunRetryable(context.TODO(), db, opts, func(context.Context) error {
+			attempts++
+			if attempts <= len(tc.errs) {
+				return tc.errs[attempts-1]
+			}
+			return nil
+		})
+		if err != tc.expected {
+			t.Errorf("%d: expected %v, got %v", i, tc.expected, err)
+		}
+		expectedAttempts := len(tc.errs) + 1
+		if tc.expected != nil {
+			expectedAttempts = len(tc.errs)
+		}
+		if attempts != expectedAttempts {
+			t.Errorf("%d: expected %d attempts, got %d", i, expectedAttempts, attempts)
+		}
+	}
+
+	// The last retryable error is returned once the retries are exhausted.
+	opts.MaxRetries = 2
+	var attempts int
+	err := client.RunRetryable(context.TODO(), db, opts, func(context.Context) error {
+		attempts++
+		return roachpb.NewAmbiguousResultError("boom")
+	})
+	if _, ok := err.(*roachpb.AmbiguousResultError); !ok {
+		t.Errorf("expected an ambiguous result error, got %v", err)
+	}
+	if attempts != 3 {
+		t.Errorf("expected 3 attempts, got %d", attempts)
+	}
+}
+
+func TestDB_PutBitArray(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	values := map[string]roachpb.Value{}
+	sender := func(
+		_ context.Context, ba roachpb.BatchRequest,
+	) (*roachpb.BatchResponse, *roachpb.Error) {
+		br := ba.CreateReply()
+		for i, ru := range ba.Requests {
+			switch req := ru.GetInner().(type) {
+			case *roachpb.PutRequest:
+				values[string(req.Key)] = req.Value
+			case *roachpb.GetRequest:
+				if v, ok := values[string(req.Key)]; ok {
+					br.Responses[i].GetGet().Value = &v
+				}
+			}
+		}
+		return br, nil
+	}
+	clock := hlc.NewClock(hlc.UnixNano, time.Nanosecond)
+	db := client.NewDB(
+		testutils.MakeAmbientCtx(), client.NonTransactionalFactoryFunc(sender), clock)
+	ctx := context.TODO()
+
+	ba, err := bitarray.Parse("0110100111")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.PutBitArray(ctx, "a", ba); err != nil {
+		t.Fatal(err)
+	}
+	if tag := values["a"].GetTag(); tag != roachpb.ValueType_BITARRAY {
+		t.Fatalf("expected tag %s, got %s", roachpb.ValueType_BITARRAY, tag)
+	}
+	kv, err := db.Get(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res := kv.ValueBitArray(); bitarray.Compare(res, ba) != 0 {
+		t.Errorf("expected %s, got %s", ba, res)
+	}
+	if s := kv.PrettyValue(); s != "B'0110100111'" {
+		t.Errorf("unexpected pretty value %s", s)
+	}
+
+	kv, err = db.Get(ctx, "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res := kv.ValueBitArray(); !res.IsEmpty() {
+		t.Errorf("expected an empty bit array for a missing key, got %s", res)
+	}
+}
+
+func BenchmarkDB_PutBytes(b *testing.B) {
+	sender := func(
+		_ context.Context, ba roachpb.BatchRequest,
+	) (*roachpb.BatchResponse, *roachpb.Error) {
+		return ba.CreateReply(), nil
+	}
+	clock := hlc.NewClock(hlc.UnixNano, time.Nanosecond)
+	db := client.NewDB(
+		testutils.MakeAmbientCtx(), client.NonTransactionalFactoryFunc(sender), clock)
+	ctx := context.Background()
+
+	const numEntries = 100
+	entries := make([]client.BytesEntry, numEntries)
+	for i := range entries {
+		entries[i] = client.BytesEntry{
+			Key:   roachpb.Key(fmt.Sprintf("key-%03d", i)),
+			Value: bytes.Repeat([]byte("x"), 100),
+		}
+	}
+
+	b.Run("Put", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for _, e := range entries {
+				if err := db.Put(ctx, e.Key, e.Value); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+	b.Run("PutBytesBatch", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if err := db.PutBytesBatch(ctx, entries); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
 func TestBatch(t *testing.T) {
 	defer leaktest.AfterTest(t)()
 	s, db := setup(t)
@@ -360,6 +2762,96 @@ func TestTxn_Commit(t *testing.T) {
 	checkResults(t, expected, b.Results)
 }
 
+func TestKeyValueClone(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	// Simulate a response buffer which a sender may reuse after the batch
+	// has completed.
+	resp := roachpb.KeyValue{Key: roachpb.Key("a"), Value: roachpb.MakeValueFromString("1")}
+	kv := client.KeyValue{Key: resp.Key, Value: &resp.Value}
+	clone := kv.Clone()
+
+	resp.Key[0] = 'b'
+	for i := range resp.Value.RawBytes {
+		resp.Value.RawBytes[i] = 0
+	}
+	resp.Value.RawBytes = nil
+
+	if !bytes.Equal(clone.Key, []byte("a")) {
+		t.Errorf("expected key \"a\", got %q", clone.Key)
+	}
+	checkResult(t, []byte("1"), clone.ValueBytes())
+
+	if kv := (client.KeyValue{Key: roachpb.Key("a")}).Clone(); kv.Value != nil {
+		t.Errorf("expected nil value, got %v", kv.Value)
+	}
+}
+
+func TestKeyValueRoachConversion(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	rkv := roachpb.KeyValue{Key: roachpb.Key("a"), Value: roachpb.MakeValueFromString("1")}
+	rkv.Value.Timestamp = hlc.Timestamp{WallTime: 1}
+
+	kv := client.KeyValueFromRoach(rkv)
+	rkv.Key[0] = 'b'
+	checkResult(t, []byte("a"), kv.Key)
+	checkResult(t, []byte("1"), kv.ValueBytes())
+	if ts := kv.Value.Timestamp; ts != (hlc.Timestamp{}) {
+		t.Errorf("expected zero timestamp, got %s", ts)
+	}
+
+	back, ok := kv.ToRoach()
+	if !ok {
+		t.Fatal("expected conversion to succeed")
+	}
+	checkResult(t, []byte("a"), back.Key)
+	if !back.Value.EqualData(rkv.Value) {
+		t.Errorf("expected %v, got %v", rkv.Value, back.Value)
+	}
+
+	if _, ok := (client.KeyValue{Key: roachpb.Key("a")}).ToRoach(); ok {
+		t.Error("expected conversion of a nil value to fail")
+	}
+}
+
+func TestKeyValueSize(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	v := roachpb.MakeValueFromString("value")
+	kv := client.KeyValue{Key: roachpb.Key("key"), Value: &v}
+	if size, expected := kv.Size(), int64(3+len(v.RawBytes)); size != expected {
+		t.Errorf("expected size %d, got %d", expected, size)
+	}
+	if size := (client.KeyValue{Key: roachpb.Key("key")}).Size(); size != 3 {
+		t.Errorf("expected size 3 for a nil value, got %d", size)
+	}
+}
+
+func TestResultDiff(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	kv := func(key, value string) client.KeyValue {
+		v := roachpb.MakeValueFromString(value)
+		return client.KeyValue{Key: roachpb.Key(key), Value: &v}
+	}
+	r := client.Result{Rows: []client.KeyValue{kv("b", "2"), kv("a", "1"), kv("c", "3"), kv("e", "5")}}
+
+	if diff := r.Diff([]client.KeyValue{kv("a", "1"), kv("b", "2"), kv("c", "3"), kv("e", "5")}); diff != "" {
+		t.Errorf("expected no differences, got:\n%s", diff)
+	}
+
+	expected := `expected 3 rows, got 4
+mismatch: "b": expected "4", got "2"
+missing: "d"="4"
+extra: "c"="3"
+extra: "e"="5"
+`
+	if diff := r.Diff([]client.KeyValue{kv("a", "1"), kv("b", "4"), kv("d", "4")}); diff != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, diff)
+	}
+}
+
 func TestDB_Put_insecure(t *testing.T) {
 	defer leaktest.AfterTest(t)()
 	s, _, db := serverutils.StartServer(t, base.TestServerArgs{Insecure: true})
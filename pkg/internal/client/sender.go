@@ -16,10 +16,13 @@ package client
 
 import (
 	"context"
+	"math/rand"
+	"time"
 
 	"github.com/cockroachdb/cockroach/pkg/roachpb"
 	"github.com/cockroachdb/cockroach/pkg/storage/engine/enginepb"
 	"github.com/cockroachdb/cockroach/pkg/util/hlc"
+	"github.com/cockroachdb/cockroach/pkg/util/syncutil"
 )
 
 // TxnType specifies whether a transaction is the root (parent)
@@ -421,6 +424,89 @@ func (f NonTransactionalFactoryFunc) NonTransactionalSender() Sender {
 	return SenderFunc(f)
 }
 
+// SizeLimitSender returns a Sender which rejects, without sending them, the
+// batches which contain more than maxRequests requests or whose requests have
+// an encoded size of more than maxBytes. A limit of zero is not enforced.
+func SizeLimitSender(wrapped Sender, maxRequests int, maxBytes int64) Sender {
+	return SenderFunc(func(
+		ctx context.Context, ba roachpb.BatchRequest,
+	) (*roachpb.BatchResponse, *roachpb.Error) {
+		if maxRequests > 0 && len(ba.Requests) > maxRequests {
+			return nil, roachpb.NewErrorf(
+				"batch of %d requests exceeds the limit of %d requests", len(ba.Requests), maxRequests)
+		}
+		if maxBytes > 0 {
+			var size int64
+			for i := range ba.Requests {
+				size += int64(ba.Requests[i].Size())
+				if size > maxBytes {
+					return nil, roachpb.NewErrorf(
+						"batch of %d requests exceeds the limit of %d bytes", len(ba.Requests), maxBytes)
+				}
+			}
+		}
+		return wrapped.Send(ctx, ba)
+	})
+}
+
+// FaultConfig configures the faults injected by a FaultInjectionSender.
+type FaultConfig struct {
+	// Probability is the probability, between 0 and 1, with which a batch
+	// fails with Error.
+	Probability float64
+	// Error is the error with which failing batches fail, for example an
+	// AmbiguousResultError or a NotLeaseHolderError.
+	Error roachpb.ErrorDetailInterface
+	// AfterSend, if set, sends failing batches before failing them, which
+	// simulates a failure after the batch may have been applied. Otherwise
+	// failing batches are not sent.
+	AfterSend bool
+	// Latency is added to every batch before it is sent.
+	Latency time.Duration
+	// Seed seeds the choice of the failing batches, so that a sequence of
+	// batches fails identically across runs.
+	Seed int64
+}
+
+// FaultInjectionSender returns a Sender which delays and fails the batches
+// sent through it as specified by cfg, to exercise the handling of errors and
+// latency of the KV layer in tests. It is only meant to be used in tests; see
+// DBContext.FaultInjection.
+func FaultInjectionSender(wrapped Sender, cfg FaultConfig) Sender {
+	var mu struct {
+		syncutil.Mutex
+		rng *rand.Rand
+	}
+	mu.rng = rand.New(rand.NewSource(cfg.Seed))
+	shouldFail := func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return mu.rng.Float64() < cfg.Probability
+	}
+	return SenderFunc(func(
+		ctx context.Context, ba roachpb.BatchRequest,
+	) (*roachpb.BatchResponse, *roachpb.Error) {
+		if cfg.Latency > 0 {
+			t := time.NewTimer(cfg.Latency)
+			select {
+			case <-t.C:
+			case <-ctx.Done():
+				t.Stop()
+				return nil, roachpb.NewError(ctx.Err())
+			}
+		}
+		if cfg.Error == nil || !shouldFail() {
+			return wrapped.Send(ctx, ba)
+		}
+		if cfg.AfterSend {
+			if _, pErr := wrapped.Send(ctx, ba); pErr != nil {
+				return nil, pErr
+			}
+		}
+		return nil, roachpb.NewError(cfg.Error)
+	})
+}
+
 // SendWrappedWith is a convenience function which wraps the request in a batch
 // and sends it via the provided Sender and headers. It returns the unwrapped
 // response or an error. It's valid to pass a `nil` context; an empty one is
@@ -26,6 +26,7 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/util/log"
 	"github.com/cockroachdb/cockroach/pkg/util/protoutil"
 	"github.com/cockroachdb/cockroach/pkg/util/syncutil"
+	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
 	"github.com/cockroachdb/cockroach/pkg/util/tracing"
 	"github.com/cockroachdb/cockroach/pkg/util/uuid"
 	"github.com/pkg/errors"
@@ -50,9 +51,15 @@ type Txn struct {
 
 	// commitTriggers are run upon successful commit.
 	commitTriggers []func(ctx context.Context)
+	// preCommitChecks are run by exec before committing. See
+	// AddPreCommitCheck.
+	preCommitChecks []func(ctx context.Context, txn *Txn) error
 	// systemConfigTrigger is set to true when modifying keys from the SystemConfig
 	// span. This sets the SystemConfigTrigger on EndTransactionRequest.
 	systemConfigTrigger bool
+	// readOnly is set for historical transactions (see DB.TxnAsOf), whose
+	// batches containing writes are rejected.
+	readOnly bool
 
 	// mu holds fields that need to be synchronized for concurrent request execution.
 	mu struct {
@@ -79,6 +86,24 @@ type Txn struct {
 		// The txn has to be committed by this deadline. A nil value indicates no
 		// deadline.
 		deadline *hlc.Timestamp
+
+		// writes counts the batches containing writes sent through the txn. It
+		// is used to decide whether writes can be deferred, see
+		// SetRequire1PCIfPossible.
+		writes int
+
+		// commitWait is set if the commit must wait out the maximum clock
+		// offset before returning. See SetCommitWait.
+		commitWait bool
+
+		// require1PC is set if the blind writes issued before any other
+		// request are deferred until the next batch, so that they can be sent
+		// along with the commit. See SetRequire1PCIfPossible. deferred holds
+		// the writes awaiting the next batch, and committedIn1PC is set once
+		// the transaction has committed in one phase.
+		require1PC     bool
+		deferred       []roachpb.RequestUnion
+		committedIn1PC bool
 	}
 }
 
@@ -634,6 +659,22 @@ func (txn *Txn) AddCommitTrigger(trigger func(ctx context.Context)) {
 	txn.commitTriggers = append(txn.commitTriggers, trigger)
 }
 
+// AddPreCommitCheck adds a check to be run when the closure passed to DB.Txn
+// returns successfully, just before the transaction is committed. The check
+// runs within the transaction, so it can read the transaction's writes and
+// validate invariants atomically with the commit. If it returns an error, the
+// transaction is not committed and the error is handled as if the closure had
+// returned it: retryable errors cause the transaction to be retried, and other
+// errors abort it.
+//
+// Like commit triggers, the checks are discarded when the transaction is
+// retried. Since the closure is run again on each attempt, a check added by
+// the closure is added, and run, on each attempt. Checks are not run if the
+// closure commits the transaction itself.
+func (txn *Txn) AddPreCommitCheck(check func(ctx context.Context, txn *Txn) error) {
+	txn.preCommitChecks = append(txn.preCommitChecks, check)
+}
+
 // OnCurrentIncarnationFinish adds a closure to be executed when the transaction
 // sender moves from state "ready" to "done" or "aborted".
 // Note that, as the name suggests, this callback is not persistent across
@@ -690,6 +731,13 @@ func (txn *Txn) exec(ctx context.Context, fn func(context.Context, *Txn) error)
 
 		// Commit on success, unless the txn has already been committed by the
 		// closure. We allow that, as closure might want to run 1PC transactions.
+		if err == nil && txn.status() != roachpb.COMMITTED {
+			for _, check := range txn.preCommitChecks {
+				if err = check(ctx, txn); err != nil {
+					break
+				}
+			}
+		}
 		if err == nil {
 			if txn.status() != roachpb.COMMITTED {
 				err = txn.Commit(ctx)
@@ -743,6 +791,7 @@ func (txn *Txn) exec(ctx context.Context, fn func(context.Context, *Txn) error)
 // TODO(andrei): I think this is called in the wrong place. See #18170.
 func (txn *Txn) PrepareForRetry(ctx context.Context, err error) {
 	txn.commitTriggers = nil
+	txn.preCommitChecks = nil
 	log.VEventf(ctx, 2, "automatically retrying transaction: %s because of error: %s",
 		txn.DebugName(), err)
 }
@@ -785,14 +834,55 @@ func (txn *Txn) Send(
 		ba.Header.GatewayNodeID = txn.gatewayNodeID
 	}
 
+	if txn.readOnly && !ba.IsReadOnly() && !ba.IsSingleEndTransactionRequest() {
+		return nil, roachpb.NewErrorf("cannot write in a historical transaction")
+	}
+
 	txn.mu.Lock()
 	requestTxnID := txn.mu.ID
 	sender := txn.mu.sender
+	if !ba.IsReadOnly() {
+		txn.mu.writes++
+	}
+	var numDeferred int
+	if txn.mu.require1PC {
+		if txn.maybeDeferLocked(ba) {
+			txn.mu.Unlock()
+			return ba.CreateReply(), nil
+		}
+		ba, numDeferred = txn.prependDeferredLocked(ba)
+	}
+	commitWait := txn.mu.commitWait
 	txn.mu.Unlock()
 	br, pErr := txn.db.sendUsingSender(ctx, ba, sender)
 	if pErr == nil {
+		if numDeferred > 0 {
+			// Strip the responses to the deferred writes, which the caller
+			// doesn't expect.
+			br.Responses = br.Responses[numDeferred:]
+		}
+		if br != nil && len(br.Responses) > 0 {
+			last := br.Responses[len(br.Responses)-1].GetInner()
+			if et, ok := last.(*roachpb.EndTransactionResponse); ok && et.OnePhaseCommit {
+				txn.mu.Lock()
+				txn.mu.committedIn1PC = true
+				txn.mu.Unlock()
+			}
+		}
+		if commitWait && br.Txn != nil && br.Txn.Status == roachpb.COMMITTED {
+			txn.waitForCommit(ctx, br.Txn.Timestamp)
+		}
 		return br, nil
 	}
+	if numDeferred > 0 && pErr.Index != nil {
+		// Make the index of the failed request relative to the caller's batch.
+		// Errors of the deferred writes are attributed to the batch as a whole.
+		if pErr.Index.Index < int32(numDeferred) {
+			pErr.Index = nil
+		} else {
+			pErr.Index.Index -= int32(numDeferred)
+		}
+	}
 
 	if retryErr, ok := pErr.GetDetail().(*roachpb.TransactionRetryWithProtoRefreshError); ok {
 		if requestTxnID != retryErr.TxnID {
@@ -809,12 +899,127 @@ func (txn *Txn) Send(
 	return br, pErr
 }
 
+// SetCommitWait sets whether the transaction performs commit-wait: the batch
+// which commits the transaction doesn't return until the maximum clock offset
+// has elapsed since the commit timestamp, at which point the clocks of all the
+// nodes are past it. This guarantees that transactions started anywhere after
+// the commit returns observe its writes, i.e. linearizability, at the cost of
+// up to one maximum clock offset of commit latency. It has no effect on the
+// batches which don't commit the transaction.
+func (txn *Txn) SetCommitWait(commitWait bool) {
+	txn.mu.Lock()
+	defer txn.mu.Unlock()
+	txn.mu.commitWait = commitWait
+}
+
+// SetRequire1PCIfPossible sets whether the transaction arranges its requests
+// so as to commit in one phase, which saves the round trip of a separate
+// commit. The coordinator only attempts a one-phase commit if the commit is
+// sent in the same batch as all of the transaction's writes, so when set, the
+// batches of blind writes (i.e. puts and deletes) sent before any other request
+// are not sent right away but along with the next batch, which is usually the
+// one committing the transaction. In particular, a read-modify-write of a
+// single key then commits in one phase, as long as it is committed either
+// explicitly through Commit or CommitInBatch or implicitly by DB.Txn. Batches
+// are only deferred if their responses carry no information, so that the
+// empty responses returned in the meantime are those they would have
+// received; any other batch is sent immediately, along with the writes
+// deferred so far.
+//
+// This is only a hint: transactions whose writes span multiple ranges, or
+// which issue other writes, fall back to a regular commit. Use
+// CommittedInOnePhase to find out whether the hint was effective. Since the
+// deferred writes are only evaluated with the next batch, their errors are
+// reported by that batch. The deferred writes of a transaction which is
+// rolled back or restarted are discarded. The hint must be set before the
+// transaction is used and must not be used with concurrent requests.
+func (txn *Txn) SetRequire1PCIfPossible(require1PC bool) {
+	txn.mu.Lock()
+	defer txn.mu.Unlock()
+	txn.mu.require1PC = require1PC
+}
+
+// CommittedInOnePhase returns whether the transaction committed in one phase.
+// See SetRequire1PCIfPossible.
+func (txn *Txn) CommittedInOnePhase() bool {
+	txn.mu.Lock()
+	defer txn.mu.Unlock()
+	return txn.mu.committedIn1PC
+}
+
+// maybeDeferLocked defers the requests of ba until the next batch if they are
+// all blind writes, ba doesn't ask for range info and the transaction hasn't
+// sent any writes yet other than the deferred ones, in which case it returns
+// true. The empty reply to ba is then indistinguishable from the one it would
+// have received. The caller must have counted ba among the transaction's
+// writes.
+func (txn *Txn) maybeDeferLocked(ba roachpb.BatchRequest) bool {
+	if len(ba.Requests) == 0 || ba.ReturnRangeInfo ||
+		(txn.mu.writes != 1 && len(txn.mu.deferred) == 0) {
+		return false
+	}
+	for _, req := range ba.Requests {
+		switch t := req.GetInner().(type) {
+		case *roachpb.PutRequest:
+			if t.Inline {
+				return false
+			}
+		case *roachpb.DeleteRequest:
+		default:
+			return false
+		}
+	}
+	txn.mu.deferred = append(txn.mu.deferred, ba.Requests...)
+	return true
+}
+
+// prependDeferredLocked returns ba with the deferred writes prepended to its
+// requests, along with their number. The deferred writes are discarded instead
+// if ba rolls back the transaction.
+func (txn *Txn) prependDeferredLocked(ba roachpb.BatchRequest) (roachpb.BatchRequest, int) {
+	deferred := txn.mu.deferred
+	txn.mu.deferred = nil
+	if len(deferred) == 0 {
+		return ba, 0
+	}
+	if args, ok := ba.GetArg(roachpb.EndTransaction); ok &&
+		!args.(*roachpb.EndTransactionRequest).Commit {
+		return ba, 0
+	}
+	reqs := make([]roachpb.RequestUnion, 0, len(deferred)+len(ba.Requests))
+	ba.Requests = append(append(reqs, deferred...), ba.Requests...)
+	return ba, len(deferred)
+}
+
+// waitForCommit blocks until the local clock is the maximum clock offset past
+// the commit timestamp ts, or the context is canceled. The transaction has
+// committed by then, so cancellation is not reported as an error. Clockless
+// reads don't bound the clock offset, so there's no waiting with them.
+func (txn *Txn) waitForCommit(ctx context.Context, ts hlc.Timestamp) {
+	maxOffset := txn.db.clock.MaxOffset()
+	if maxOffset == timeutil.ClocklessMaxOffset {
+		return
+	}
+	wait := time.Duration(ts.WallTime-txn.db.clock.PhysicalNow()) + maxOffset
+	if wait <= 0 {
+		return
+	}
+	log.VEventf(ctx, 2, "waiting %s on commit for linearizability", wait)
+	t := time.NewTimer(wait)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-ctx.Done():
+	}
+}
+
 func (txn *Txn) handleErrIfRetryableLocked(ctx context.Context, err error) {
 	retryErr, ok := err.(*roachpb.TransactionRetryWithProtoRefreshError)
 	if !ok {
 		return
 	}
 	txn.resetDeadlineLocked()
+	txn.mu.deferred = nil
 	txn.replaceSenderIfTxnAbortedLocked(ctx, retryErr, retryErr.TxnID)
 }
 
@@ -952,6 +1157,7 @@ func (txn *Txn) GenerateForcedRetryableError(ctx context.Context, msg string) er
 	now := txn.db.clock.Now()
 	txn.mu.sender.ManualRestart(ctx, txn.mu.userPriority, now)
 	txn.resetDeadlineLocked()
+	txn.mu.deferred = nil
 	return roachpb.NewTransactionRetryWithProtoRefreshError(
 		msg,
 		txn.mu.ID,
@@ -491,3 +491,394 @@ func TestUpdateDeadlineMaybe(t *testing.T) {
 		t.Errorf("unexpected deadline: %s", d)
 	}
 }
+
+func TestTxnWithDeadline(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	clock := hlc.NewClock(hlc.UnixNano, time.Nanosecond)
+	var commitDeadline *hlc.Timestamp
+	db := NewDB(testutils.MakeAmbientCtx(), newTestTxnFactory(
+		func(ba roachpb.BatchRequest) (*roachpb.BatchResponse, *roachpb.Error) {
+			if et, ok := ba.GetArg(roachpb.EndTransaction); ok {
+				commitDeadline = et.(*roachpb.EndTransactionRequest).Deadline
+			}
+			return ba.CreateReply(), nil
+		}), clock)
+	ctx := context.Background()
+
+	deadline := clock.Now().Add(time.Hour.Nanoseconds(), 0)
+	if err := db.TxnWithDeadline(ctx, deadline, func(ctx context.Context, txn *Txn) error {
+		return txn.Put(ctx, "a", "b")
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if commitDeadline == nil || *commitDeadline != deadline {
+		t.Errorf("expected commit deadline %s, got %v", deadline, commitDeadline)
+	}
+
+	var called bool
+	err := db.TxnWithDeadline(ctx, hlc.Timestamp{WallTime: 1}, func(ctx context.Context, txn *Txn) error {
+		called = true
+		return nil
+	})
+	if _, ok := err.(*TxnDeadlineExceededError); !ok {
+		t.Errorf("expected deadline exceeded error, got %v", err)
+	}
+	if called {
+		t.Error("expected the closure not to be run past the deadline")
+	}
+}
+
+func TestTxnAsOf(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	clock := hlc.NewClock(hlc.UnixNano, time.Nanosecond)
+	gcThreshold := hlc.Timestamp{WallTime: 10}
+	var readTimestamps []hlc.Timestamp
+	var writes int
+	db := NewDB(testutils.MakeAmbientCtx(), newTestTxnFactory(
+		func(ba roachpb.BatchRequest) (*roachpb.BatchResponse, *roachpb.Error) {
+			if _, ok := ba.GetArg(roachpb.Get); !ok {
+				if !ba.IsReadOnly() && !ba.IsSingleEndTransactionRequest() {
+					writes++
+				}
+				return ba.CreateReply(), nil
+			}
+			if ba.Txn.OrigTimestamp.Less(gcThreshold) {
+				return nil, roachpb.NewError(&roachpb.BatchTimestampBeforeGCError{
+					Timestamp: ba.Txn.OrigTimestamp, Threshold: gcThreshold,
+				})
+			}
+			readTimestamps = append(readTimestamps, ba.Txn.OrigTimestamp)
+			return ba.CreateReply(), nil
+		}), clock)
+	ctx := context.Background()
+
+	asOf := hlc.Timestamp{WallTime: 20}
+	if err := db.TxnAsOf(ctx, asOf, func(ctx context.Context, txn *Txn) error {
+		for i := 0; i < 2; i++ {
+			if _, err := txn.Get(ctx, "a"); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if expected := []hlc.Timestamp{asOf, asOf}; !reflect.DeepEqual(expected, readTimestamps) {
+		t.Errorf("expected reads at %s, got %s", expected, readTimestamps)
+	}
+
+	err := db.TxnAsOf(ctx, asOf, func(ctx context.Context, txn *Txn) error {
+		return txn.Put(ctx, "a", "b")
+	})
+	if !testutils.IsError(err, "cannot write in a historical transaction") {
+		t.Errorf("expected write to be rejected, got %v", err)
+	}
+	if writes != 0 {
+		t.Errorf("expected no writes to be sent, got %d", writes)
+	}
+
+	err = db.TxnAsOf(ctx, hlc.Timestamp{WallTime: 5}, func(ctx context.Context, txn *Txn) error {
+		_, err := txn.Get(ctx, "a")
+		return err
+	})
+	if _, ok := errors.Cause(err).(*roachpb.BatchTimestampBeforeGCError); !ok {
+		t.Errorf("expected GC threshold error, got %v", err)
+	}
+}
+
+func TestTxnCommitWait(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	const maxOffset = 50 * time.Millisecond
+	clock := hlc.NewClock(hlc.UnixNano, maxOffset)
+	db := NewDB(testutils.MakeAmbientCtx(), newTestTxnFactory(nil), clock)
+	ctx := context.Background()
+
+	txn := NewTxn(ctx, db, 0 /* gatewayNodeID */, RootTxn)
+	txn.SetCommitWait(true)
+	if err := txn.Put(ctx, "a", "b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := txn.CommitOrCleanup(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// The commit returns once the clock is maxOffset past the commit timestamp.
+	if waited := clock.PhysicalNow() - txn.OrigTimestamp().WallTime; waited < int64(maxOffset) {
+		t.Errorf("expected commit to wait out %s, waited %s", maxOffset, time.Duration(waited))
+	}
+}
+
+func TestTxnPreCommitCheck(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	clock := hlc.NewClock(hlc.UnixNano, time.Nanosecond)
+	var calls []string
+	db := NewDB(testutils.MakeAmbientCtx(), newTestTxnFactory(
+		func(ba roachpb.BatchRequest) (*roachpb.BatchResponse, *roachpb.Error) {
+			for _, ru := range ba.Requests {
+				req := ru.GetInner()
+				if et, ok := req.(*roachpb.EndTransactionRequest); ok {
+					calls = append(calls, fmt.Sprintf("EndTransaction(commit=%t)", et.Commit))
+					continue
+				}
+				calls = append(calls, fmt.Sprintf("%s(%s)", req.Method(), string(req.Header().Key)))
+			}
+			return ba.CreateReply(), nil
+		}), clock)
+	ctx := context.Background()
+
+	for _, fail := range []bool{false, true} {
+		calls = nil
+		err := db.Txn(ctx, func(ctx context.Context, txn *Txn) error {
+			txn.AddPreCommitCheck(func(ctx context.Context, txn *Txn) error {
+				if _, err := txn.Get(ctx, "invariant"); err != nil {
+					return err
+				}
+				if fail {
+					return errors.New("invariant violated")
+				}
+				return nil
+			})
+			return txn.Put(ctx, "a", "b")
+		})
+		expected := []string{"Put(a)", "Get(invariant)", "EndTransaction(commit=true)"}
+		if fail {
+			if !testutils.IsError(err, "invariant violated") {
+				t.Errorf("expected the check's error, got %v", err)
+			}
+			expected[2] = "EndTransaction(commit=false)"
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(expected, calls) {
+			t.Errorf("fail=%t: expected calls %v, got %v", fail, expected, calls)
+		}
+	}
+}
+
+func TestTxnRequire1PCIfPossible(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	clock := hlc.NewClock(hlc.UnixNano, time.Nanosecond)
+	var batches [][]string
+	db := NewDB(testutils.MakeAmbientCtx(), newTestTxnFactory(
+		func(ba roachpb.BatchRequest) (*roachpb.BatchResponse, *roachpb.Error) {
+			var calls []string
+			for _, ru := range ba.Requests {
+				calls = append(calls, ru.GetInner().Method().String())
+			}
+			batches = append(batches, calls)
+			br := ba.CreateReply()
+			for i, ru := range ba.Requests {
+				switch req := ru.GetInner().(type) {
+				case *roachpb.GetRequest:
+					get := br.Responses[i].GetInner().(*roachpb.GetResponse)
+					get.Value = &roachpb.Value{}
+					get.Value.SetString(string(req.Key))
+				case *roachpb.EndTransactionRequest:
+					// The commit is in one phase if it is sent along with
+					// the writes.
+					br.Responses[i].GetInner().(*roachpb.EndTransactionResponse).OnePhaseCommit =
+						req.Commit && len(ba.Requests) > 1
+				}
+			}
+			return br, nil
+		}), clock)
+	ctx := context.Background()
+
+	testCases := []struct {
+		name     string
+		hint     bool
+		fn       func(context.Context, *Txn) error
+		expected [][]string
+		onePC    bool
+	}{
+		{
+			name: "read-modify-write",
+			hint: true,
+			fn: func(ctx context.Context, txn *Txn) error {
+				if _, err := txn.Get(ctx, "a"); err != nil {
+					return err
+				}
+				if err := txn.Put(ctx, "a", "b"); err != nil {
+					return err
+				}
+				return txn.Del(ctx, "c")
+			},
+			expected: [][]string{{"Get"}, {"Put", "Delete", "EndTransaction"}},
+			onePC:    true,
+		},
+		{
+			name: "no hint",
+			fn: func(ctx context.Context, txn *Txn) error {
+				return txn.Put(ctx, "a", "b")
+			},
+			expected: [][]string{{"Put"}, {"EndTransaction"}},
+		},
+		{
+			name: "read after write",
+			hint: true,
+			fn: func(ctx context.Context, txn *Txn) error {
+				if err := txn.Put(ctx, "a", "b"); err != nil {
+					return err
+				}
+				kv, err := txn.Get(ctx, "c")
+				if err != nil {
+					return err
+				}
+				if v := kv.ValueBytes(); string(v) != "c" {
+					return errors.Errorf("expected the response to the get, got %q", v)
+				}
+				return txn.Put(ctx, "d", "e")
+			},
+			expected: [][]string{{"Put", "Get"}, {"Put"}, {"EndTransaction"}},
+		},
+		{
+			name: "conditional write",
+			hint: true,
+			fn: func(ctx context.Context, txn *Txn) error {
+				return txn.CPut(ctx, "a", "b", nil)
+			},
+			expected: [][]string{{"ConditionalPut"}, {"EndTransaction"}},
+		},
+		{
+			name: "range info",
+			hint: true,
+			fn: func(ctx context.Context, txn *Txn) error {
+				b := txn.NewBatch()
+				b.Header.ReturnRangeInfo = true
+				b.Put("a", "b")
+				return txn.Run(ctx, b)
+			},
+			expected: [][]string{{"Put"}, {"EndTransaction"}},
+		},
+		{
+			name: "commit in batch",
+			hint: true,
+			fn: func(ctx context.Context, txn *Txn) error {
+				if err := txn.Put(ctx, "a", "b"); err != nil {
+					return err
+				}
+				b := txn.NewBatch()
+				b.Put("c", "d")
+				return txn.CommitInBatch(ctx, b)
+			},
+			expected: [][]string{{"Put", "Put", "EndTransaction"}},
+			onePC:    true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			batches = nil
+			var txn *Txn
+			if err := db.Txn(ctx, func(ctx context.Context, tx *Txn) error {
+				txn = tx
+				txn.SetRequire1PCIfPossible(tc.hint)
+				return tc.fn(ctx, txn)
+			}); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(tc.expected, batches) {
+				t.Errorf("expected batches %v, got %v", tc.expected, batches)
+			}
+			if onePC := txn.CommittedInOnePhase(); onePC != tc.onePC {
+				t.Errorf("expected one-phase commit %t, got %t", tc.onePC, onePC)
+			}
+		})
+	}
+
+	// The deferred writes of a transaction which is rolled back are
+	// discarded.
+	batches = nil
+	if err := db.Txn(ctx, func(ctx context.Context, txn *Txn) error {
+		txn.SetRequire1PCIfPossible(true)
+		if err := txn.Put(ctx, "a", "b"); err != nil {
+			return err
+		}
+		return errors.New("boom")
+	}); !testutils.IsError(err, "boom") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := [][]string{{"EndTransaction"}}; !reflect.DeepEqual(expected, batches) {
+		t.Errorf("expected batches %v, got %v", expected, batches)
+	}
+}
+
+func TestBatchIdempotencyKey(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	mc := hlc.NewManualClock(1)
+	clock := hlc.NewClock(mc.UnixNano, time.Nanosecond)
+	// data holds the values written by the committed transactions, and
+	// pending those written by the running one.
+	data := map[string]roachpb.Value{}
+	var pending map[string]roachpb.Value
+	var incs int
+	db := NewDB(testutils.MakeAmbientCtx(), newTestTxnFactory(
+		func(ba roachpb.BatchRequest) (*roachpb.BatchResponse, *roachpb.Error) {
+			if pending == nil {
+				pending = map[string]roachpb.Value{}
+			}
+			br := ba.CreateReply()
+			for i, ru := range ba.Requests {
+				switch req := ru.GetInner().(type) {
+				case *roachpb.GetRequest:
+					if v, ok := data[string(req.Key)]; ok {
+						br.Responses[i].GetInner().(*roachpb.GetResponse).Value = &v
+					}
+				case *roachpb.PutRequest:
+					v := req.Value
+					v.Timestamp = ba.Txn.Timestamp
+					pending[string(req.Key)] = v
+				case *roachpb.IncrementRequest:
+					incs++
+					br.Responses[i].GetInner().(*roachpb.IncrementResponse).NewValue = int64(incs)
+				case *roachpb.EndTransactionRequest:
+					if req.Commit {
+						for k, v := range pending {
+							data[k] = v
+						}
+					}
+					pending = nil
+				}
+			}
+			return br, nil
+		}), clock)
+	ctx := context.Background()
+
+	run := func() int64 {
+		b := &Batch{}
+		b.SetIdempotencyKey(roachpb.Key("op"))
+		b.Inc("a", 1)
+		if err := db.Run(ctx, b); err != nil {
+			t.Fatal(err)
+		}
+		return b.Results[0].Rows[0].ValueInt()
+	}
+	if v := run(); v != 1 {
+		t.Fatalf("expected the increment to return 1, got %d", v)
+	}
+	// Re-sending the batch returns the recorded results without running it
+	// again.
+	if v := run(); v != 1 {
+		t.Fatalf("expected the recorded increment to return 1, got %d", v)
+	}
+	if incs != 1 {
+		t.Fatalf("expected the increment to be applied once, got %d", incs)
+	}
+	// After the window, the key can be reused.
+	mc.Increment(IdempotencyWindow.Nanoseconds())
+	if v := run(); v != 2 {
+		t.Fatalf("expected the increment to return 2, got %d", v)
+	}
+	if incs != 2 {
+		t.Fatalf("expected the increment to be applied twice, got %d", incs)
+	}
+}
+
+// nonTxnSenderFactory creates mock transactional senders and uses the
+// provided sender for non-transactional requests.
+type nonTxnSenderFactory struct {
+	MockTxnSenderFactory
+	nonTxnSender Sender
+}
+
+func (f nonTxnSenderFactory) NonTransactionalSender() Sender {
+	return f.nonTxnSender
+}
@@ -21,6 +21,7 @@ import (
 
 	"github.com/cockroachdb/apd"
 	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/util/bitarray"
 	"github.com/cockroachdb/cockroach/pkg/util/duration"
 	"github.com/cockroachdb/cockroach/pkg/util/protoutil"
 )
@@ -86,6 +87,10 @@ func marshalValue(v interface{}) (roachpb.Value, error) {
 		err := r.SetDuration(t)
 		return r, err
 
+	case bitarray.BitArray:
+		r.SetBitArray(t)
+		return r, nil
+
 	case protoutil.Message:
 		err := r.SetProto(t)
 		return r, err
@@ -316,6 +316,11 @@ func NewServer(cfg Config, stopper *stop.Stopper) (*Server, error) {
 	dbCtx := client.DefaultDBContext()
 	dbCtx.NodeID = &s.nodeIDContainer
 	dbCtx.Stopper = s.stopper
+	dbCtx.ApplicationWaiter = func(
+		ctx context.Context, repl roachpb.ReplicaDescriptor, rangeID roachpb.RangeID, leaseIndex uint64,
+	) error {
+		return storage.WaitForReplicaApplication(ctx, s.nodeDialer, repl, rangeID, leaseIndex)
+	}
 	s.db = client.NewDBWithContext(s.cfg.AmbientCtx, s.tcsFactory, s.clock, dbCtx)
 
 	nlActive, nlRenewal := s.cfg.NodeLivenessDurations()
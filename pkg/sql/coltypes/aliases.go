@@ -19,7 +19,6 @@ import (
 
 	"github.com/cockroachdb/cockroach/pkg/sql/pgwire/pgerror"
 	"github.com/cockroachdb/cockroach/pkg/sql/sem/types"
-	"github.com/lib/pq/oid"
 )
 
 var (
@@ -106,6 +105,8 @@ var (
 	RegProcedure = &TOid{Name: "REGPROCEDURE"}
 	// RegType is an immutable T instance.
 	RegType = &TOid{Name: "REGTYPE"}
+	// RegCollation is an immutable T instance.
+	RegCollation = &TOid{Name: "REGCOLLATION"}
 
 	// OidVector is an immutable T instance.
 	OidVector = &TVector{Name: "OIDVECTOR", ParamType: Oid}
@@ -152,10 +153,32 @@ func ArrayOf(colType T, bounds []int32) (T, error) {
 
 var typNameLiterals map[string]T
 
+// nonColumnTypes holds the types known to pg_type which have no column type
+// of their own. Resolving their names to the column type of the type they
+// are represented as would lose their OID, so they are left to
+// postgresPredefinedTypeIssues instead.
+var nonColumnTypes = map[types.T]struct{}{
+	types.Money:    {},
+	types.Xid:      {},
+	types.Cid:      {},
+	types.Tid:      {},
+	types.CString:  {},
+	types.Internal: {},
+	types.Jsonpath: {},
+	types.PgLSN:    {},
+}
+
 func init() {
 	typNameLiterals = make(map[string]T)
 	for o, t := range types.OidToType {
-		name := strings.ToLower(oid.TypeName[o])
+		elem := t
+		if a, ok := t.(types.TArray); ok {
+			elem = a.Typ
+		}
+		if _, ok := nonColumnTypes[elem]; ok {
+			continue
+		}
+		name := strings.ToLower(types.PGTypeName(o))
 		if _, ok := typNameLiterals[name]; !ok {
 			colTyp, err := DatumTypeToColumnType(t)
 			if err != nil {
@@ -185,8 +208,12 @@ func TypeForNonKeywordTypeName(name string) (T, bool, int) {
 // PostgreSQL types that are already implemented in CockroachDB.
 var postgresPredefinedTypeIssues = map[string]int{
 	"box":           21286,
+	"cid":           -1,
 	"cidr":          18846,
 	"circle":        21286,
+	"cstring":       -1,
+	"internal":      -1,
+	"jsonpath":      -1,
 	"line":          21286,
 	"lseg":          21286,
 	"macaddr":       -1,
@@ -196,8 +223,10 @@ var postgresPredefinedTypeIssues = map[string]int{
 	"pg_lsn":        -1,
 	"point":         21286,
 	"polygon":       21286,
+	"tid":           -1,
 	"tsquery":       7821,
 	"tsvector":      7821,
 	"txid_snapshot": -1,
+	"xid":           -1,
 	"xml":           -1,
 }
@@ -37,6 +37,8 @@ func TOidToType(ct *TOid) types.T {
 		return types.RegProcedure
 	case RegType:
 		return types.RegType
+	case RegCollation:
+		return types.RegCollation
 	default:
 		panic(fmt.Sprintf("unexpected *TOid: %v", ct))
 	}
@@ -58,6 +60,8 @@ func OidTypeToColType(t types.T) *TOid {
 		return RegProcedure
 	case types.RegType:
 		return RegType
+	case types.RegCollation:
+		return RegCollation
 	default:
 		panic(fmt.Sprintf("unexpected type: %v", t))
 	}
@@ -105,7 +109,8 @@ func DatumTypeToColumnType(t types.T) (T, error) {
 		types.RegNamespace,
 		types.RegProc,
 		types.RegProcedure,
-		types.RegType:
+		types.RegType,
+		types.RegCollation:
 		return OidTypeToColType(t), nil
 	}
 
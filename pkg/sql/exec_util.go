@@ -701,6 +701,7 @@ func checkResultType(typ types.T) error {
 	case types.RegProc:
 	case types.RegProcedure:
 	case types.RegType:
+	case types.RegCollation:
 	default:
 		// Compare all types that cannot rely on == equality.
 		istype := typ.FamilyEqual
@@ -355,7 +355,7 @@ func (b *Builder) buildColumnAccess(
 	childTyp := colAccess.Input.DataType().(types.TTuple)
 	colIdx := int(colAccess.Idx)
 	lbl := ""
-	if childTyp.Labels != nil {
+	if colIdx < len(childTyp.Labels) {
 		lbl = childTyp.Labels[colIdx]
 	}
 	return tree.NewTypedColumnAccessExpr(input, lbl, colIdx), nil
@@ -15,6 +15,8 @@
 package optbuilder
 
 import (
+	"fmt"
+
 	"github.com/cockroachdb/cockroach/pkg/sql/opt"
 	"github.com/cockroachdb/cockroach/pkg/sql/opt/memo"
 	"github.com/cockroachdb/cockroach/pkg/sql/pgwire/pgerror"
@@ -137,11 +139,16 @@ func (b *Builder) finishBuildGeneratorFunction(
 		b.populateSynthesizedColumn(outCol, fn)
 	} else {
 		// Multi-column return type. Use the tuple labels in the SRF's return type
-		// as column aliases.
+		// as column aliases, or positional aliases if the SRF returns an
+		// anonymous record.
 		typ := f.ResolvedType()
 		tType := typ.(types.TTuple)
 		for i := range tType.Types {
-			b.synthesizeColumn(outScope, tType.Labels[i], tType.Types[i], nil, fn)
+			alias := fmt.Sprintf("column%d", i+1)
+			if i < len(tType.Labels) {
+				alias = tType.Labels[i]
+			}
+			b.synthesizeColumn(outScope, alias, tType.Types[i], nil, fn)
 		}
 	}
 
@@ -43,7 +43,7 @@ func (b *Builder) expandStar(
 		texpr := inScope.resolveType(t.Expr, types.Any)
 		typ := texpr.ResolvedType()
 		tType, ok := typ.(types.TTuple)
-		if !ok || tType.Labels == nil {
+		if !ok || tType.Labels == nil || len(tType.Labels) < len(tType.Types) {
 			panic(builderError{tree.NewTypeIsNotCompositeError(typ)})
 		}
 
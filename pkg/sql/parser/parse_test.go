@@ -716,6 +716,7 @@ func TestParse(t *testing.T) {
 		{`SELECT 1:::REGPROC`},
 		{`SELECT 1:::REGCLASS`},
 		{`SELECT 1:::REGNAMESPACE`},
+		{`SELECT 1:::REGCOLLATION`},
 
 		{`SELECT 'a' AS "12345"`},
 		{`SELECT 'a' AS clnm`},
@@ -2941,8 +2942,12 @@ func TestUnimplementedSyntax(t *testing.T) {
 		{`SELECT a(b) WITHIN GROUP (ORDER BY c)`, 0, `within group`},
 
 		{`CREATE TABLE a(b BOX)`, 21286, `box`},
+		{`CREATE TABLE a(b CID)`, 0, `cid`},
 		{`CREATE TABLE a(b CIDR)`, 18846, `cidr`},
 		{`CREATE TABLE a(b CIRCLE)`, 21286, `circle`},
+		{`CREATE TABLE a(b CSTRING)`, 0, `cstring`},
+		{`CREATE TABLE a(b INTERNAL)`, 0, `internal`},
+		{`CREATE TABLE a(b JSONPATH)`, 0, `jsonpath`},
 		{`CREATE TABLE a(b LINE)`, 21286, `line`},
 		{`CREATE TABLE a(b LSEG)`, 21286, `lseg`},
 		{`CREATE TABLE a(b MACADDR)`, 0, `macaddr`},
@@ -2952,9 +2957,11 @@ func TestUnimplementedSyntax(t *testing.T) {
 		{`CREATE TABLE a(b PG_LSN)`, 0, `pg_lsn`},
 		{`CREATE TABLE a(b POINT)`, 21286, `point`},
 		{`CREATE TABLE a(b POLYGON)`, 21286, `polygon`},
+		{`CREATE TABLE a(b TID)`, 0, `tid`},
 		{`CREATE TABLE a(b TSQUERY)`, 7821, `tsquery`},
 		{`CREATE TABLE a(b TSVECTOR)`, 7821, `tsvector`},
 		{`CREATE TABLE a(b TXID_SNAPSHOT)`, 0, `txid_snapshot`},
+		{`CREATE TABLE a(b XID)`, 0, `xid`},
 		{`CREATE TABLE a(b XML)`, 0, `xml`},
 		{`CREATE TABLE a(b TIMETZ)`, 26097, `type`},
 
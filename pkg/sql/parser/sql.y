@@ -538,7 +538,7 @@ func newNameFromStr(s string) *tree.Name {
 %token <str> QUERIES QUERY
 
 %token <str> RANGE RANGES READ REAL RECURSIVE REF REFERENCES
-%token <str> REGCLASS REGPROC REGPROCEDURE REGNAMESPACE REGTYPE
+%token <str> REGCLASS REGCOLLATION REGPROC REGPROCEDURE REGNAMESPACE REGTYPE
 %token <str> REMOVE_PATH RENAME REPEATABLE REPLACE
 %token <str> RELEASE RESET RESTORE RESTRICT RESUME RETURNING REVOKE RIGHT
 %token <str> ROLE ROLES ROLLBACK ROLLUP ROW ROWS RSHIFT RULE
@@ -6619,6 +6619,10 @@ postgres_oid:
   {
     $$.val = coltypes.RegNamespace
   }
+| REGCOLLATION
+  {
+    $$.val = coltypes.RegCollation
+  }
 
 opt_float:
   '(' ICONST ')'
@@ -8881,6 +8885,7 @@ unreserved_keyword:
 | RECURSIVE
 | REF
 | REGCLASS
+| REGCOLLATION
 | REGPROC
 | REGPROCEDURE
 | REGNAMESPACE
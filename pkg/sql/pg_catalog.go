@@ -386,9 +386,9 @@ CREATE TABLE pg_catalog.pg_attribute (
 					zeroVal,                        // attstattarget
 					typLen(colTyp),                 // attlen
 					tree.NewDInt(tree.DInt(colID)), // attnum
-					zeroVal,                        // attndims
+					columnNDims(&column.Type),      // attndims
 					negOneVal,                      // attcacheoff
-					negOneVal,                      // atttypmod
+					columnTypmod(&column.Type),     // atttypmod
 					tree.DNull,                     // attbyval (see pg_type.typbyval)
 					tree.DNull,                     // attstorage
 					tree.DNull,                     // attalign
@@ -2073,31 +2073,20 @@ CREATE TABLE pg_catalog.pg_type (
 				typType := typTypeBase
 				typElem := oidZero
 				typArray := oidZero
-				builtinPrefix := builtins.PGIOBuiltinPrefix(typ)
-				if cat == typCategoryArray {
-					switch typ {
-					case types.IntVector:
-						// IntVector needs a special case because its a special snowflake
-						// type. It's just like an Int2Array, but it has its own OID. We
-						// can't just wrap our Int2Array type in an OID wrapper, though,
-						// because Int2Array is not an exported, first-class type - it's an
-						// input-only type that translates immediately to int8array. This
-						// would go away if we decided to export Int2Array as a real type.
-						typElem = tree.NewDOid(tree.DInt(oid.T_int2))
-					case types.OidVector:
-						// Same story as above for OidVector.
-						typElem = tree.NewDOid(tree.DInt(oid.T_oid))
-					default:
-						builtinPrefix = "array_"
-						typElem = tree.NewDOid(tree.DInt(types.UnwrapType(typ).(types.TArray).Typ.Oid()))
+				ioFuncs := types.TypeIOFunctions(typ)
+				if elem, ok := types.ElementOidForArray(o); ok {
+					typElem = tree.NewDOid(tree.DInt(elem))
+				}
+				if cat != typCategoryArray {
+					if array, ok := types.ArrayOidForElement(o); ok {
+						typArray = tree.NewDOid(tree.DInt(array))
 					}
-				} else {
-					typArray = tree.NewDOid(tree.DInt(types.TArray{Typ: typ}.Oid()))
 				}
 				if cat == typCategoryPseudo {
 					typType = typTypePseudo
 				}
-				typname := strings.ToLower(oid.TypeName[o])
+				typname := strings.ToLower(types.PGTypeName(o))
+				typIsPreferred := tree.MakeDBool(tree.DBool(types.IsPreferred(typ)))
 
 				if err := addRow(
 					tree.NewDOid(tree.DInt(o)), // oid
@@ -2108,7 +2097,7 @@ CREATE TABLE pg_catalog.pg_type (
 					typByVal(typ),              // typbyval
 					typType,                    // typtype
 					cat,                        // typcategory
-					tree.DBoolFalse,            // typispreferred
+					typIsPreferred,             // typispreferred
 					tree.DBoolTrue,             // typisdefined
 					typDelim,                   // typdelim
 					oidZero,                    // typrelid
@@ -2116,13 +2105,13 @@ CREATE TABLE pg_catalog.pg_type (
 					typArray,                   // typarray
 
 					// regproc references
-					h.RegProc(builtinPrefix+"in"),   // typinput
-					h.RegProc(builtinPrefix+"out"),  // typoutput
-					h.RegProc(builtinPrefix+"recv"), // typreceive
-					h.RegProc(builtinPrefix+"send"), // typsend
-					oidZero,                         // typmodin
-					oidZero,                         // typmodout
-					oidZero,                         // typanalyze
+					h.RegProc(ioFuncs.Input),   // typinput
+					h.RegProc(ioFuncs.Output),  // typoutput
+					h.RegProc(ioFuncs.Receive), // typreceive
+					h.RegProc(ioFuncs.Send),    // typsend
+					oidZero,                    // typmodin
+					oidZero,                    // typmodout
+					oidZero,                    // typanalyze
 
 					tree.DNull,      // typalign
 					tree.DNull,      // typstorage
@@ -2315,6 +2304,43 @@ var datumToTypeCategory = map[reflect.Type]*tree.DString{
 	reflect.TypeOf(types.Oid):         typCategoryNumeric,
 	reflect.TypeOf(types.UUID):        typCategoryUserDefined,
 	reflect.TypeOf(types.INet):        typCategoryNetworkAddr,
+	reflect.TypeOf(types.Internal):    typCategoryPseudo,
+}
+
+// columnTypmod returns the atttypmod of a column of the provided type.
+func columnTypmod(typ *sqlbase.ColumnType) tree.Datum {
+	typmod := int32(-1)
+	switch typ.SemanticType {
+	case sqlbase.ColumnType_STRING:
+		switch typ.VisibleType {
+		case sqlbase.ColumnType_VARCHAR:
+			typmod = types.AttTypmod(types.MakeVarChar(typ.Width))
+		case sqlbase.ColumnType_CHAR:
+			typmod = types.AttTypmod(types.MakeChar(typ.Width))
+		}
+	case sqlbase.ColumnType_DECIMAL:
+		typmod = types.DecimalTypmod(typ.Precision, typ.Width)
+	default:
+		if colTyp := typ.ToDatumType(); colTyp != nil {
+			typmod = types.AttTypmod(colTyp)
+		}
+	}
+	if typmod == -1 {
+		return negOneVal
+	}
+	return tree.NewDInt(tree.DInt(typmod))
+}
+
+// columnNDims returns the number of dimensions of the provided column type,
+// as reported by pg_attribute.attndims. As in Postgres, this is 0 for the
+// columns not declared as arrays, including those of type int2vector.
+func columnNDims(typ *sqlbase.ColumnType) tree.Datum {
+	if typ.SemanticType != sqlbase.ColumnType_ARRAY {
+		return zeroVal
+	}
+	elem := typ.ToDatumType().(types.TArray).Typ
+	ndims := types.ArrayDims(types.MakeArrayWithDims(elem, len(typ.ArrayDimensions)))
+	return tree.NewDInt(tree.DInt(ndims))
 }
 
 func typCategory(typ types.T) tree.Datum {
@@ -2324,6 +2350,12 @@ func typCategory(typ types.T) tree.Datum {
 		}
 		return typCategoryArray
 	}
+	if typ == types.CString {
+		return typCategoryPseudo
+	}
+	if typ == types.Jsonpath || typ == types.PgLSN {
+		return typCategoryUserDefined
+	}
 	return datumToTypeCategory[reflect.TypeOf(types.UnwrapType(typ))]
 }
 
@@ -55,6 +55,15 @@ const (
 	FormatBinary FormatCode = 1
 )
 
+// PreferredFormatCode returns the format in which values of the provided type
+// are best transmitted: binary if the type supports it, text otherwise.
+func PreferredFormatCode(t types.T) FormatCode {
+	if types.SupportsBinaryFormat(t) {
+		return FormatBinary
+	}
+	return FormatText
+}
+
 var _ BufferedReader = &bufio.Reader{}
 var _ BufferedReader = &bytes.Buffer{}
 
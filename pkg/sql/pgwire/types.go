@@ -50,21 +50,15 @@ type pgType struct {
 	// Note that the protocol has both int16 and int32 size fields,
 	// so this attribute is an unsized int and should be cast
 	// as needed.
-	// This field does *not* correspond to the encoded length of a
-	// data type, so it's unclear what, if anything, it is used for.
-	// To get the right value, "SELECT oid, typlen FROM pg_type"
-	// on a postgres server.
+	// This is the length of the binary encoding of the values of fixed-width
+	// types, which is also their typlen in pg_type.
 	size int
 }
 
 func pgTypeForParserType(t types.T) pgType {
-	size := -1
-	if s, variable := tree.DatumTypeSize(t); !variable {
-		size = int(s)
-	}
 	return pgType{
 		oid:  t.Oid(),
-		size: size,
+		size: types.BinaryWireLength(t),
 	}
 }
 
@@ -159,8 +153,8 @@ func (b *writeBuffer) writeTextDatum(
 		b.writeFromFmtCtx(b.textFormatter)
 
 	case *tree.DArray:
-		switch d.ResolvedType().Oid() {
-		case oid.T_int2vector, oid.T_oidvector:
+		switch types.TextFormatHint(d.ResolvedType()) {
+		case types.TextFormatVector:
 			// vectors are serialized as a string of space-separated values.
 			sep := ""
 			// TODO(justin): add a test for nested arrays when #32552 is
@@ -424,8 +418,11 @@ func (b *writeBuffer) writeBinaryDatum(
 		subWriter := newWriteBuffer(nil /* bytecount */)
 		// Put the number of datums.
 		subWriter.putInt32(int32(len(v.D)))
-		for _, elem := range v.D {
-			oid := elem.ResolvedType().Oid()
+		// The OID of each field is taken from the type of the tuple rather than
+		// from the datum, so that NULL fields are described by their column type.
+		fieldTypes := v.ResolvedType().(types.TTuple).Types
+		for i, elem := range v.D {
+			oid := fieldTypes[i].Oid()
 			subWriter.putInt32(int32(oid))
 			subWriter.writeBinaryDatum(ctx, elem, sessionLoc, oid)
 		}
@@ -118,6 +118,46 @@ func TestWriteBinaryArray(t *testing.T) {
 	}
 }
 
+func TestWriteBinaryAnonymousRecord(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	typ := types.MakeAnonymousRecord([]types.T{types.Int, types.String}).(types.TTuple)
+	d := tree.NewDTuple(typ, tree.NewDInt(1), tree.DNull)
+
+	buf := newWriteBuffer(nil /* bytecount */)
+	buf.writeBinaryDatum(context.Background(), d, time.UTC, typ.Oid())
+	if buf.err != nil {
+		t.Fatal(buf.err)
+	}
+
+	rbuf := pgwirebase.ReadBuffer{Msg: buf.wrapped.Bytes()}
+	getUint32 := func() uint32 {
+		v, err := rbuf.GetUint32()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	// The length of the record, followed by its number of fields and the OID,
+	// length and value of each of them.
+	_ = getUint32()
+	if n := getUint32(); n != 2 {
+		t.Fatalf("expected 2 fields, got %d", n)
+	}
+	var fieldOids []oid.Oid
+	for i := 0; i < 2; i++ {
+		fieldOids = append(fieldOids, oid.Oid(getUint32()))
+		if n := int32(getUint32()); n > 0 {
+			if _, err := rbuf.GetBytes(int(n)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if expected := []oid.Oid{oid.T_int8, oid.T_text}; !reflect.DeepEqual(expected, fieldOids) {
+		t.Errorf("expected field oids %v, got %v", expected, fieldOids)
+	}
+}
+
 func TestIntArrayRoundTrip(t *testing.T) {
 	defer leaktest.AfterTest(t)()
 
@@ -16,6 +16,7 @@ package sql
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/cockroachdb/cockroach/pkg/sql/pgwire/pgerror"
 	"github.com/cockroachdb/cockroach/pkg/sql/sem/builtins"
@@ -154,10 +155,15 @@ func (p *planner) ProjectSet(
 				tType := typ.(types.TTuple)
 
 				// Prepare the result columns. Use the tuple labels in the SRF's
-				// return type as column labels.
+				// return type as column labels, or positional labels if the SRF
+				// returns an anonymous record.
 				for j := range tType.Types {
+					name := fmt.Sprintf("column%d", j+1)
+					if j < len(tType.Labels) {
+						name = tType.Labels[j]
+					}
 					n.columns = append(n.columns, sqlbase.ResultColumn{
-						Name: tType.Labels[j],
+						Name: name,
 						Typ:  tType.Types[j],
 					})
 				}
@@ -52,36 +52,11 @@ func makeNotUsableFalseBuiltin() builtinDefinition {
 	}
 }
 
-// typeBuiltinsHaveUnderscore is a map to keep track of which types have i/o
-// builtins with underscores in between their type name and the i/o builtin
-// name, like date_in vs int8in. There seems to be no other way to
-// programmatically determine whether or not this underscore is present, hence
-// the existence of this map.
-var typeBuiltinsHaveUnderscore = map[oid.Oid]struct{}{
-	types.Any.Oid():         {},
-	types.AnyArray.Oid():    {},
-	types.Date.Oid():        {},
-	types.Time.Oid():        {},
-	types.Decimal.Oid():     {},
-	types.Interval.Oid():    {},
-	types.JSON.Oid():        {},
-	types.UUID.Oid():        {},
-	oid.T_varbit:            {},
-	oid.T_bit:               {},
-	types.Timestamp.Oid():   {},
-	types.TimestampTZ.Oid(): {},
-	types.FamTuple.Oid():    {},
-}
-
 // PGIOBuiltinPrefix returns the string prefix to a type's IO functions. This
 // is either the type's postgres display name or the type's postgres display
 // name plus an underscore, depending on the type.
 func PGIOBuiltinPrefix(typ types.T) string {
-	builtinPrefix := strings.ToLower(oid.TypeName[typ.Oid()])
-	if _, ok := typeBuiltinsHaveUnderscore[typ.Oid()]; ok {
-		return builtinPrefix + "_"
-	}
-	return builtinPrefix
+	return types.PGIOFuncPrefix(typ)
 }
 
 // initPGBuiltins adds all of the postgres builtins to the Builtins map.
@@ -114,7 +89,10 @@ func initPGBuiltins() {
 	}
 
 	// Make crdb_internal.create_regfoo builtins.
-	for _, typ := range []types.TOid{types.RegType, types.RegProc, types.RegProcedure, types.RegClass, types.RegNamespace} {
+	for _, typ := range []types.TOid{
+		types.RegType, types.RegProc, types.RegProcedure, types.RegClass, types.RegNamespace,
+		types.RegCollation,
+	} {
 		typName := typ.SQLName()
 		builtins["crdb_internal.create_"+typName] = makeCreateRegDef(typ)
 	}
@@ -148,14 +126,11 @@ func makeTypeIOBuiltins(builtinPrefix string, typ types.T) map[string]builtinDef
 	typname := typ.String()
 	return map[string]builtinDefinition{
 		builtinPrefix + "send": makeTypeIOBuiltin(tree.ArgTypes{{typname, typ}}, types.Bytes),
-		// Note: PG takes type 2281 "internal" for these builtins, which we don't
-		// provide. We won't implement these functions anyway, so it shouldn't
-		// matter.
-		builtinPrefix + "recv": makeTypeIOBuiltin(tree.ArgTypes{{"input", types.Any}}, typ),
-		// Note: PG returns 'cstring' for these builtins, but we don't support that.
-		builtinPrefix + "out": makeTypeIOBuiltin(tree.ArgTypes{{typname, typ}}, types.Bytes),
-		// Note: PG takes 'cstring' for these builtins, but we don't support that.
-		builtinPrefix + "in": makeTypeIOBuiltin(tree.ArgTypes{{"input", types.Any}}, typ),
+		// Note: we won't implement these functions, which can't be called from
+		// SQL anyway since nothing is of type internal.
+		builtinPrefix + "recv": makeTypeIOBuiltin(tree.ArgTypes{{"input", types.Internal}}, typ),
+		builtinPrefix + "out":  makeTypeIOBuiltin(tree.ArgTypes{{typname, typ}}, types.CString),
+		builtinPrefix + "in":   makeTypeIOBuiltin(tree.ArgTypes{{"input", types.CString}}, typ),
 	}
 }
 
@@ -308,7 +308,8 @@ func (expr *NumVal) ResolveAsType(ctx *SemaContext, typ types.T) (Datum, error)
 		types.RegNamespace,
 		types.RegProc,
 		types.RegProcedure,
-		types.RegType:
+		types.RegType,
+		types.RegCollation:
 
 		d, err := expr.ResolveAsType(ctx, types.Int)
 		if err != nil {
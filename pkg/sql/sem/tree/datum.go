@@ -3555,4 +3555,6 @@ var baseDatumTypeSizes = map[types.T]struct {
 	types.INet:        {unsafe.Sizeof(DIPAddr{}), fixedSize},
 	// TODO(jordan,justin): This seems suspicious.
 	types.Any: {unsafe.Sizeof(DString("")), variableSize},
+	// There are no values of type internal.
+	types.Internal: {0, fixedSize},
 }
@@ -1542,7 +1542,8 @@ func validCastTypes(t types.T) []castInfo {
 		return uuidCastTypes
 	case types.INet:
 		return inetCastTypes
-	case types.Oid, types.RegClass, types.RegNamespace, types.RegProc, types.RegProcedure, types.RegType:
+	case types.Oid, types.RegClass, types.RegNamespace, types.RegProc, types.RegProcedure, types.RegType,
+		types.RegCollation:
 		return oidCastTypes
 	default:
 		// TODO(eisen): currently dead -- there is no syntax yet for casting
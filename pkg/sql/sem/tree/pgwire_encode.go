@@ -17,6 +17,8 @@ package tree
 import (
 	"bytes"
 	"unicode/utf8"
+
+	"github.com/cockroachdb/cockroach/pkg/sql/sem/types"
 )
 
 func (d *DTuple) pgwireFormat(ctx *FmtCtx) {
@@ -114,7 +116,7 @@ func (d *DArray) pgwireFormat(ctx *FmtCtx) {
 	ctx.WriteByte('}')
 }
 
-var tupleQuoteSet, arrayQuoteSet asciiSet
+var tupleQuoteSet asciiSet
 
 func init() {
 	var ok bool
@@ -122,32 +124,16 @@ func init() {
 	if !ok {
 		panic("tuple asciiset")
 	}
-	arrayQuoteSet, ok = makeASCIISet(" \t\v\f\r\n{},\"\\")
-	if !ok {
-		panic("array asciiset")
-	}
 }
 
 func pgwireQuoteStringInTuple(in string) bool {
 	return in == "" || tupleQuoteSet.in(in)
 }
 
-func pgwireQuoteStringInArray(in string) bool {
-	if in == "" || arrayQuoteSet.in(in) {
-		return true
-	}
-	if len(in) == 4 &&
-		(in[0] == 'n' || in[0] == 'N') &&
-		(in[1] == 'u' || in[1] == 'U') &&
-		(in[2] == 'l' || in[2] == 'L') &&
-		(in[3] == 'l' || in[3] == 'L') {
-		return true
-	}
-	return false
-}
-
+// pgwireFormatStringInArray writes a string element of an array to buf. It
+// follows the rules of types.ArrayElementEncode, but avoids its allocations.
 func pgwireFormatStringInArray(buf *bytes.Buffer, in string) {
-	quote := pgwireQuoteStringInArray(in)
+	quote := types.ArrayElementNeedsQuoting(types.String, in)
 	if quote {
 		buf.WriteByte('"')
 	}
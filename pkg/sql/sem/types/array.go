@@ -0,0 +1,114 @@
+// Copyright 2018 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package types
+
+import (
+	"encoding/hex"
+	"strings"
+	"unicode/utf8"
+)
+
+// TMultiDimArray is the type of an array declared with more than one
+// dimension, as in INT[][]. Its values are represented like those of the
+// one-dimensional array type it wraps, which it behaves like in all respects
+// other than its number of dimensions, as reported by pg_attribute.attndims.
+type TMultiDimArray struct {
+	TArray
+	Dims int
+}
+
+// MakeArrayWithDims returns the type of an array of elem with ndims
+// dimensions. Arrays with one dimension, or fewer, are plain TArrays.
+func MakeArrayWithDims(elem T, ndims int) T {
+	if ndims <= 1 {
+		return TArray{Typ: elem}
+	}
+	return TMultiDimArray{TArray: TArray{Typ: elem}, Dims: ndims}
+}
+
+// ArrayDims returns the number of dimensions of the provided array type,
+// which is 1 unless it was declared with more by MakeArrayWithDims, or 0 if
+// the type is not an array.
+func ArrayDims(t T) int {
+	if m, ok := t.(TMultiDimArray); ok {
+		return m.Dims
+	}
+	if _, ok := UnwrapType(t).(TArray); ok {
+		return 1
+	}
+	return 0
+}
+
+func (t TMultiDimArray) String() string {
+	return t.TArray.String() + strings.Repeat("[]", t.Dims-1)
+}
+
+// arrayQuoteChars are the characters which require an array element to be
+// quoted in the text representation of the array.
+var arrayQuoteChars [utf8.RuneSelf]bool
+
+func init() {
+	for _, c := range " \t\v\f\r\n{},\"\\" {
+		arrayQuoteChars[c] = true
+	}
+}
+
+// ArrayElementNeedsQuoting returns whether the text representation value of
+// an element of type elem must be double quoted when it is written within the
+// text representation of an array, i.e. whether it is empty, contains a
+// delimiter, a brace, whitespace, a double quote or a backslash, or would
+// otherwise be read back as NULL. The elements of bytea arrays are always
+// quoted since their hex representation starts with a backslash.
+func ArrayElementNeedsQuoting(elem T, value string) bool {
+	if UnwrapType(elem) == Bytes {
+		return true
+	}
+	if value == "" || strings.EqualFold(value, "null") {
+		return true
+	}
+	for i := 0; i < len(value); i++ {
+		if c := value[i]; c < utf8.RuneSelf && arrayQuoteChars[c] {
+			return true
+		}
+	}
+	return false
+}
+
+// ArrayElementEncode returns the text representation of an element of type
+// elem within the text representation of an array, as sent over pgwire. For
+// bytea elements raw holds the bytes of the element, which are hex encoded;
+// for the other types it holds the element's own text representation. The
+// element is double quoted if ArrayElementNeedsQuoting says so, in which case
+// double quotes and backslashes are escaped with a backslash.
+func ArrayElementEncode(elem T, raw []byte) string {
+	if UnwrapType(elem) == Bytes {
+		return `"\\x` + hex.EncodeToString(raw) + `"`
+	}
+	value := string(raw)
+	if !ArrayElementNeedsQuoting(elem, value) {
+		return value
+	}
+	var b strings.Builder
+	b.Grow(len(value) + 2)
+	b.WriteByte('"')
+	for i := 0; i < len(value); i++ {
+		if c := value[i]; c == '"' || c == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(value[i])
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,83 @@
+// Copyright 2018 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package types
+
+import "testing"
+
+func TestArrayElementEncode(t *testing.T) {
+	testCases := []struct {
+		elem     T
+		raw      string
+		quote    bool
+		expected string
+	}{
+		{String, "abc", false, `abc`},
+		{String, "", true, `""`},
+		{String, "a,b", true, `"a,b"`},
+		{String, "{a}", true, `"{a}"`},
+		{String, "a b", true, `"a b"`},
+		{String, `a"b\c`, true, `"a\"b\\c"`},
+		{String, "NuLl", true, `"NuLl"`},
+		{String, "nulls", false, `nulls`},
+		{String, "café", false, `café`},
+		{Name, "a,b", true, `"a,b"`},
+		{Int, "-12", false, `-12`},
+		{Bytes, "\x01\xab", true, `"\\x01ab"`},
+		{Bytes, "", true, `"\\x"`},
+	}
+	for _, tc := range testCases {
+		if quote := ArrayElementNeedsQuoting(tc.elem, tc.raw); quote != tc.quote {
+			t.Errorf("%s %q: expected quoting %t, got %t", tc.elem, tc.raw, tc.quote, quote)
+		}
+		if s := ArrayElementEncode(tc.elem, []byte(tc.raw)); s != tc.expected {
+			t.Errorf("%s %q: expected %s, got %s", tc.elem, tc.raw, tc.expected, s)
+		}
+	}
+}
+
+func TestArrayDims(t *testing.T) {
+	testCases := []struct {
+		typ      T
+		dims     int
+		expected string
+	}{
+		{Int, 0, "int"},
+		{MakeArrayWithDims(Int, 0), 1, "int[]"},
+		{MakeArrayWithDims(Int, 1), 1, "int[]"},
+		{MakeArrayWithDims(Int, 2), 2, "int[][]"},
+		{MakeArrayWithDims(String, 3), 3, "string[][][]"},
+		{IntVector, 1, "int[]"},
+	}
+	for _, tc := range testCases {
+		if dims := ArrayDims(tc.typ); dims != tc.dims {
+			t.Errorf("%s: expected %d dimensions, got %d", tc.typ, tc.dims, dims)
+		}
+		if s := tc.typ.String(); s != tc.expected {
+			t.Errorf("expected %s, got %s", tc.expected, s)
+		}
+	}
+
+	// Multi-dimensional arrays behave like one-dimensional ones otherwise.
+	typ := MakeArrayWithDims(Int, 2)
+	if u := UnwrapType(typ); !Identical(u, TArray{Typ: Int}) {
+		t.Errorf("expected %s to unwrap to int[], got %s", typ, u)
+	}
+	if !typ.Equivalent(TArray{Typ: Int}) {
+		t.Errorf("expected %s to be equivalent to int[]", typ)
+	}
+	if Identical(typ, TArray{Typ: Int}) {
+		t.Errorf("expected %s not to be identical to int[]", typ)
+	}
+}
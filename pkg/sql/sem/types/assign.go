@@ -0,0 +1,296 @@
+// Copyright 2018 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package types
+
+import "github.com/lib/pq/oid"
+
+// CastKind is the kind of coercion required to convert values of a type to
+// another type. The kinds match the contexts in which Postgres applies casts,
+// as recorded in pg_cast.castcontext.
+type CastKind int
+
+const (
+	// CastNone means that values can be used without being converted.
+	CastNone CastKind = iota
+	// CastImplicit means that values are converted by a cast which Postgres
+	// applies implicitly in any context, e.g. from int4 to int8.
+	CastImplicit
+	// CastAssignment means that values are converted by a cast which Postgres
+	// only applies implicitly when they are assigned to a column, e.g. from
+	// int8 to int4. Such casts may fail or lose information, e.g. by
+	// truncating strings.
+	CastAssignment
+)
+
+func (k CastKind) String() string {
+	switch k {
+	case CastNone:
+		return "none"
+	case CastImplicit:
+		return "implicit"
+	case CastAssignment:
+		return "assignment"
+	default:
+		return "unknown"
+	}
+}
+
+// numericRanks and dateTimeRanks order the numeric types and the date and
+// time types respectively. Within each of them, a cast to a type of higher
+// rank is implicit and a cast to a type of lower rank is an assignment cast.
+var numericRanks = map[oid.Oid]int{
+	oid.T_int2:    1,
+	oid.T_int4:    2,
+	oid.T_int8:    3,
+	oid.T_numeric: 4,
+	oid.T_float4:  5,
+	oid.T_float8:  6,
+}
+
+var dateTimeRanks = map[oid.Oid]int{
+	oid.T_date:        1,
+	oid.T_timestamp:   2,
+	oid.T_timestamptz: 3,
+}
+
+// stringOids is the set of the Oids of the types in the string category which
+// can be converted to one another.
+var stringOids = map[oid.Oid]struct{}{
+	oid.T_bpchar:  {},
+	oid.T_char:    {},
+	oid.T_name:    {},
+	oid.T_text:    {},
+	oid.T_varchar: {},
+}
+
+// IsAssignable returns whether values of type src can be assigned to a column
+// of type dest, as by INSERT and UPDATE, and if so the kind of coercion
+// required. The rules are those of Postgres' assignment casts, which differ
+// from Equivalent:
+//
+// - the numeric types, and the date and time types, are assignable to one
+//   another, implicitly when widening (e.g. int4 to int8 or date to
+//   timestamp) and by assignment when narrowing;
+// - values of any type are assignable to the string types, by assignment
+//   unless the source is itself a string type whose values fit, e.g. a
+//   varchar(5) is assigned to a varchar(10) without coercion but a
+//   varchar(10) to a varchar(5) requires an assignment cast;
+// - arrays are assignable if their elements are.
+func IsAssignable(src, dest T) (CastKind, bool) {
+	if dest == Any || Identical(src, dest) {
+		return CastNone, true
+	}
+	if src == Unknown {
+		return CastImplicit, true
+	}
+	srcOid, destOid := src.Oid(), dest.Oid()
+	if _, ok := stringOids[destOid]; ok {
+		if _, ok := stringOids[srcOid]; !ok {
+			return CastAssignment, true
+		}
+		return stringCast(src, dest), true
+	}
+	srcArr, srcIsArr := UnwrapType(src).(TArray)
+	destArr, destIsArr := UnwrapType(dest).(TArray)
+	if srcIsArr || destIsArr {
+		if !srcIsArr || !destIsArr {
+			return 0, false
+		}
+		kind, ok := IsAssignable(srcArr.Typ, destArr.Typ)
+		if ok && kind == CastNone && src.Oid() != dest.Oid() {
+			// An array is converted to or from one of the vector types.
+			kind = CastImplicit
+		}
+		return kind, ok
+	}
+	if srcRank, ok := numericRanks[srcOid]; ok {
+		if destRank, ok := numericRanks[destOid]; ok {
+			return rankCast(srcRank, destRank), true
+		}
+	}
+	if srcRank, ok := dateTimeRanks[srcOid]; ok {
+		if destRank, ok := dateTimeRanks[destOid]; ok {
+			return rankCast(srcRank, destRank), true
+		}
+	}
+	if srcOid == oid.T_money || destOid == oid.T_money {
+		// Money is converted from and to integers and decimals by assignment.
+		if srcOid == oid.T_money && destOid == oid.T_money {
+			return CastNone, true
+		}
+		other := srcOid
+		if srcOid == oid.T_money {
+			other = destOid
+		}
+		if r, ok := numericRanks[other]; ok && r <= numericRanks[oid.T_numeric] {
+			return CastAssignment, true
+		}
+		return 0, false
+	}
+	if _, ok := dest.(TOid); ok {
+		switch s := src.(type) {
+		case TOid:
+			// The regfoo types are converted to and from oid, but not to one
+			// another.
+			if s.oidType == oid.T_oid || destOid == oid.T_oid {
+				return CastImplicit, true
+			}
+			return 0, false
+		default:
+			if r, ok := numericRanks[srcOid]; ok && r <= numericRanks[oid.T_int8] {
+				return CastImplicit, true
+			}
+			return 0, false
+		}
+	}
+	if _, ok := src.(TOid); ok {
+		if destOid == oid.T_int4 || destOid == oid.T_int8 {
+			return CastAssignment, true
+		}
+		return 0, false
+	}
+	if _, ok := dest.(TRestrictedInterval); ok && src.FamilyEqual(Interval) {
+		// Values are truncated to the fields of the destination.
+		return CastAssignment, true
+	}
+	if src.Equivalent(dest) {
+		return CastImplicit, true
+	}
+	return 0, false
+}
+
+func rankCast(srcRank, destRank int) CastKind {
+	switch {
+	case srcRank < destRank:
+		return CastImplicit
+	case srcRank > destRank:
+		return CastAssignment
+	default:
+		return CastNone
+	}
+}
+
+// stringCast returns the kind of coercion required to convert values of the
+// string type src to the string type dest.
+func stringCast(src, dest T) CastKind {
+	srcWidth, destWidth := stringWidth(src), stringWidth(dest)
+	if destWidth > 0 && (srcWidth == 0 || srcWidth > destWidth) {
+		return CastAssignment
+	}
+	if src.Oid() == dest.Oid() {
+		return CastNone
+	}
+	return CastImplicit
+}
+
+// stringWidth returns the maximum width of the values of a string type, or 0
+// if it is unbounded.
+func stringWidth(t T) int32 {
+	if t.Oid() == oid.T_char {
+		return 1
+	}
+	w, _ := CharWidth(t)
+	return w
+}
+
+// numericBits holds the number of bits of the magnitude of the values of the
+// integer and floating point types which are represented exactly, i.e. the
+// width of the integers excluding their sign, and the width of the mantissa
+// of the floats. Decimals are represented exactly regardless of their
+// magnitude.
+var numericBits = map[oid.Oid]int{
+	oid.T_int2:   15,
+	oid.T_int4:   31,
+	oid.T_int8:   63,
+	oid.T_float4: 24,
+	oid.T_float8: 53,
+}
+
+// CastIsLossy returns whether casting values of type from to type to may lose
+// information, i.e. whether the cast may fail, round or truncate some values.
+// Unlike IsAssignable, it is not based on the kinds of casts Postgres applies
+// implicitly but on the widths and precisions of the types, e.g.:
+//
+// - widening casts, such as from int4 to int8 or from float4 to float8, are
+//   lossless, whereas narrowing casts, such as from int8 to int4, are lossy;
+// - casts from integers to floats are lossy if the mantissa of the float is
+//   narrower than the integer, e.g. from int8 to float8, and casts from
+//   decimals or floats to integers, or from decimals to floats, are lossy;
+// - casts between the string types are lossless, unless the destination has a
+//   declared width which the values of the source may exceed, as are casts to
+//   unconstrained string types from any type, whereas casts from strings to
+//   other types are lossy since they may fail to parse;
+// - casts from timestamps to dates and to intervals restricted to fields
+//   which the source may hold are lossy;
+// - casts between arrays are lossy if casts between their elements are.
+//
+// Casts which aren't supported are reported as lossy.
+func CastIsLossy(from, to T) bool {
+	if from == Unknown || Identical(from, to) {
+		return false
+	}
+	fromOid, toOid := from.Oid(), to.Oid()
+	if _, ok := stringOids[toOid]; ok {
+		if _, ok := stringOids[fromOid]; ok {
+			return stringCast(from, to) == CastAssignment
+		}
+		return stringWidth(to) != 0
+	}
+	if _, ok := stringOids[fromOid]; ok {
+		return true
+	}
+	fromArr, fromIsArr := UnwrapType(from).(TArray)
+	toArr, toIsArr := UnwrapType(to).(TArray)
+	if fromIsArr || toIsArr {
+		return !fromIsArr || !toIsArr || CastIsLossy(fromArr.Typ, toArr.Typ)
+	}
+	if _, ok := numericRanks[fromOid]; ok {
+		if _, ok := numericRanks[toOid]; ok {
+			return numericCastIsLossy(fromOid, toOid)
+		}
+	}
+	if fromRank, ok := dateTimeRanks[fromOid]; ok {
+		if toRank, ok := dateTimeRanks[toOid]; ok {
+			// Only dates lose the time of day; the timestamps are converted
+			// to one another through the session's time zone without loss.
+			return toRank == dateTimeRanks[oid.T_date] && fromRank != toRank
+		}
+	}
+	if from.FamilyEqual(Interval) && to.FamilyEqual(Interval) {
+		fromFields, _ := IntervalFieldsFromType(from)
+		toFields, _ := IntervalFieldsFromType(to)
+		return fromFields&^toFields != 0
+	}
+	if kind, ok := IsAssignable(from, to); ok && kind != CastAssignment {
+		return false
+	}
+	return true
+}
+
+// numericCastIsLossy returns whether casting values between the numeric types
+// with the provided Oids may lose information.
+func numericCastIsLossy(fromOid, toOid oid.Oid) bool {
+	if fromOid == oid.T_numeric || toOid == oid.T_numeric {
+		// Decimals hold the integers and floats exactly, but not the other
+		// way around.
+		return fromOid == oid.T_numeric
+	}
+	fromIsFloat := fromOid == oid.T_float4 || fromOid == oid.T_float8
+	toIsFloat := toOid == oid.T_float4 || toOid == oid.T_float8
+	if fromIsFloat && !toIsFloat {
+		return true
+	}
+	return numericBits[fromOid] > numericBits[toOid]
+}
@@ -0,0 +1,148 @@
+// Copyright 2018 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package types
+
+import "testing"
+
+func TestIsAssignable(t *testing.T) {
+	const notAssignable = CastKind(-1)
+	testCases := []struct {
+		src, dest T
+		kind      CastKind
+	}{
+		{Int, Int, CastNone},
+		{Int, Any, CastNone},
+		{Unknown, Int, CastImplicit},
+
+		{typeInt2, Int, CastImplicit},
+		{Int, typeInt4, CastAssignment},
+		{Int, Float, CastImplicit},
+		{Float, Int, CastAssignment},
+		{Decimal, Float, CastImplicit},
+		{Float, Decimal, CastAssignment},
+		{typeFloat4, Float, CastImplicit},
+		{Money, Decimal, CastAssignment},
+		{Int, Money, CastAssignment},
+		{Float, Money, notAssignable},
+
+		{Date, TimestampTZ, CastImplicit},
+		{TimestampTZ, Timestamp, CastAssignment},
+		{Timestamp, Date, CastAssignment},
+
+		{MakeVarChar(5), MakeVarChar(10), CastNone},
+		{MakeVarChar(10), MakeVarChar(5), CastAssignment},
+		{String, MakeVarChar(5), CastAssignment},
+		{String, typeVarChar, CastImplicit},
+		{MakeVarChar(5), String, CastImplicit},
+		{MakeChar(3), MakeVarChar(3), CastImplicit},
+		{Name, String, CastImplicit},
+		{String, typeQChar, CastAssignment},
+		{typeQChar, String, CastImplicit},
+		{Int, String, CastAssignment},
+		{Bool, MakeVarChar(5), CastAssignment},
+		{String, Int, notAssignable},
+		{Bool, Int, notAssignable},
+
+		{Int, Oid, CastImplicit},
+		{Oid, Int, CastAssignment},
+		{RegClass, Oid, CastImplicit},
+		{Oid, RegType, CastImplicit},
+		{RegClass, RegType, notAssignable},
+
+		{Interval, MakeRestrictedInterval(IntervalFieldDay), CastAssignment},
+		{MakeRestrictedInterval(IntervalFieldDay), Interval, CastImplicit},
+		{typeBit, BitArray, CastImplicit},
+
+		{TArray{typeInt2}, TArray{Int}, CastImplicit},
+		{TArray{Int}, TArray{String}, CastAssignment},
+		{TArray{MakeVarChar(5)}, TArray{MakeVarChar(5)}, CastNone},
+		{TArray{typeInt2}, IntVector, CastImplicit},
+		{TArray{String}, String, CastAssignment},
+		{String, TArray{String}, notAssignable},
+		{TArray{String}, TArray{Int}, notAssignable},
+	}
+	for _, tc := range testCases {
+		kind, ok := IsAssignable(tc.src, tc.dest)
+		if !ok {
+			kind = notAssignable
+		}
+		if kind != tc.kind {
+			t.Errorf("%s to %s: expected %s, got %s", tc.src, tc.dest, tc.kind, kind)
+		}
+	}
+}
+
+func TestCastIsLossy(t *testing.T) {
+	testCases := []struct {
+		from, to T
+		lossy    bool
+	}{
+		{Int, Int, false},
+		{Unknown, Int, false},
+
+		// Widening is lossless, narrowing is lossy.
+		{typeInt2, typeInt4, false},
+		{typeInt4, Int, false},
+		{Int, typeInt4, true},
+		{typeInt4, typeInt2, true},
+		{typeFloat4, Float, false},
+		{Float, typeFloat4, true},
+		{Int, Decimal, false},
+		{Float, Decimal, false},
+		{Decimal, Int, true},
+		{Decimal, Float, true},
+		{Float, Int, true},
+		{typeInt2, typeFloat4, false},
+		{typeInt4, typeFloat4, true},
+		{typeInt4, Float, false},
+		{Int, Float, true},
+
+		// Strings are lossless unless truncated.
+		{String, typeVarChar, false},
+		{typeVarChar, String, false},
+		{MakeVarChar(5), String, false},
+		{String, MakeVarChar(5), true},
+		{MakeVarChar(5), MakeVarChar(10), false},
+		{MakeVarChar(10), MakeVarChar(5), true},
+		{MakeChar(5), MakeVarChar(5), false},
+		{String, typeQChar, true},
+		{Int, String, false},
+		{Int, MakeVarChar(5), true},
+		{String, Int, true},
+
+		{Date, Timestamp, false},
+		{Timestamp, TimestampTZ, false},
+		{TimestampTZ, Date, true},
+		{Interval, MakeRestrictedInterval(IntervalFieldYear), true},
+		{MakeRestrictedInterval(IntervalFieldYear), Interval, false},
+		{
+			MakeRestrictedInterval(IntervalFieldYear),
+			MakeRestrictedInterval(IntervalFieldYear | IntervalFieldMonth),
+			false,
+		},
+
+		{TArray{typeInt4}, TArray{Int}, false},
+		{TArray{Int}, TArray{typeInt4}, true},
+		{TArray{String}, String, false},
+		{String, TArray{String}, true},
+
+		{Bool, Int, true},
+	}
+	for _, tc := range testCases {
+		if lossy := CastIsLossy(tc.from, tc.to); lossy != tc.lossy {
+			t.Errorf("%s to %s: expected lossy=%t, got %t", tc.from, tc.to, tc.lossy, lossy)
+		}
+	}
+}
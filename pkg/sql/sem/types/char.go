@@ -0,0 +1,90 @@
+// Copyright 2018 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package types
+
+import (
+	"fmt"
+
+	"github.com/lib/pq/oid"
+)
+
+// typmodHeader is added to the declared width of character types and to the
+// precision and scale of decimals to form their Postgres type modifier
+// (VARHDRSZ in Postgres).
+const typmodHeader = 4
+
+// TSizedString is the type of a VARCHAR(n) or CHAR(n) with a declared
+// maximum width. It behaves like the unconstrained type it wraps in all other
+// respects.
+type TSizedString struct {
+	T
+	Width int32
+}
+
+// MakeVarChar returns the type of a VARCHAR(width), or the unconstrained
+// VARCHAR type if width is not positive.
+func MakeVarChar(width int32) T {
+	if width <= 0 {
+		return typeVarChar
+	}
+	return TSizedString{T: typeVarChar, Width: width}
+}
+
+// MakeChar returns the type of a CHAR(width), or the unconstrained bpchar
+// type if width is not positive.
+func MakeChar(width int32) T {
+	if width <= 0 {
+		return typeBpChar
+	}
+	return TSizedString{T: typeBpChar, Width: width}
+}
+
+// CharWidth returns the declared maximum width of the provided character
+// type. The boolean is false if the type has no declared width.
+func CharWidth(t T) (int32, bool) {
+	if s, ok := t.(TSizedString); ok {
+		return s.Width, true
+	}
+	return 0, false
+}
+
+// CharTypmod returns the Postgres type modifier of a character type with the
+// provided width. Character types without a declared width have a type
+// modifier of -1.
+func CharTypmod(width int32) int32 {
+	if width <= 0 {
+		return -1
+	}
+	return width + typmodHeader
+}
+
+// CharWidthFromTypmod is the inverse of CharTypmod. The boolean is false if
+// the type modifier doesn't declare a width.
+func CharWidthFromTypmod(typmod int32) (int32, bool) {
+	if typmod <= typmodHeader {
+		return 0, false
+	}
+	return typmod - typmodHeader, true
+}
+
+func (t TSizedString) String() string {
+	switch t.Oid() {
+	case oid.T_bpchar:
+		return fmt.Sprintf("char(%d)", t.Width)
+	case oid.T_varchar:
+		return fmt.Sprintf("varchar(%d)", t.Width)
+	}
+	return fmt.Sprintf("%s(%d)", t.T, t.Width)
+}
@@ -0,0 +1,59 @@
+// Copyright 2018 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package types
+
+import "testing"
+
+func TestCharWidth(t *testing.T) {
+	testCases := []struct {
+		typ     T
+		base    T
+		width   int32
+		typmod  int32
+		str     string
+		display string
+	}{
+		{MakeVarChar(10), typeVarChar, 10, 14, "varchar(10)", "character varying(10)"},
+		{MakeChar(3), typeBpChar, 3, 7, "char(3)", "bpchar(3)"},
+		{TSizedString{T: String, Width: 5}, String, 5, 9, "string(5)", "text(5)"},
+		{MakeVarChar(0), typeVarChar, 0, -1, "string", "character varying"},
+		{MakeChar(0), typeBpChar, 0, -1, "string", "bpchar"},
+		{String, String, 0, -1, "string", "text"},
+	}
+	for _, tc := range testCases {
+		width, ok := CharWidth(tc.typ)
+		if ok != (tc.width != 0) || width != tc.width {
+			t.Errorf("%s: expected width %d, got %d (ok=%t)", tc.typ, tc.width, width, ok)
+		}
+		if typmod := AttTypmod(tc.typ); typmod != tc.typmod {
+			t.Errorf("%s: expected typmod %d, got %d", tc.typ, tc.typmod, typmod)
+		}
+		if width, ok := CharWidthFromTypmod(tc.typmod); ok != (tc.width != 0) || width != tc.width {
+			t.Errorf("%d: expected width %d, got %d (ok=%t)", tc.typmod, tc.width, width, ok)
+		}
+		if s := tc.typ.String(); s != tc.str {
+			t.Errorf("expected %s, got %s", tc.str, s)
+		}
+		if s := DisplayName(tc.typ); s != tc.display {
+			t.Errorf("%s: expected display name %s, got %s", tc.typ, tc.display, s)
+		}
+		if tc.typ.Oid() != tc.base.Oid() || !tc.typ.Equivalent(String) || !String.Equivalent(tc.typ) {
+			t.Errorf("expected %s to be equivalent to %s", tc.typ, tc.base)
+		}
+		if UnwrapType(tc.typ) != String {
+			t.Errorf("expected %s to unwrap to %s", tc.typ, String)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright 2018 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package types
+
+// ComparabilityFlags describe the ways in which the values of a type can be
+// compared, as returned by Comparability.
+type ComparabilityFlags int
+
+const (
+	// Equatable means that values can be compared for equality, e.g. in
+	// lookup or merge join conditions.
+	Equatable ComparabilityFlags = 1 << iota
+	// Orderable means that values are totally ordered, so they can be sorted
+	// by ORDER BY and merge joins.
+	Orderable
+	// Hashable means that values have a key encoding under which equal values
+	// are encoded identically, so that they can be grouped by hashing, e.g.
+	// by DISTINCT, GROUP BY and hash joins.
+	Hashable
+)
+
+// Has returns whether all of the provided flags are set.
+func (f ComparabilityFlags) Has(flags ComparabilityFlags) bool {
+	return f&flags == flags
+}
+
+const allComparable = Equatable | Orderable | Hashable
+
+// Comparability returns the ways in which the values of type t can be
+// compared. Most scalar types support all of them, except for:
+//
+// - JSON, which is only equatable;
+// - arrays, which are equatable if their elements are, but cannot be ordered
+//   (see #32707) and have no key encoding;
+// - tuples, which are equatable and orderable if all of their fields are, but
+//   have no key encoding;
+// - the Any pseudo-type, whose values cannot be compared.
+func Comparability(t T) ComparabilityFlags {
+	switch typ := UnwrapType(t).(type) {
+	case TArray:
+		return Comparability(typ.Typ) & Equatable
+	case TTuple:
+		flags := Equatable | Orderable
+		for _, field := range typ.Types {
+			flags &= Comparability(field)
+		}
+		return flags
+	case tJSON:
+		return Equatable
+	case tAny:
+		return 0
+	}
+	return allComparable
+}
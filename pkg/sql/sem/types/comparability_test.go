@@ -0,0 +1,47 @@
+// Copyright 2018 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package types
+
+import "testing"
+
+func TestComparability(t *testing.T) {
+	testCases := []struct {
+		typ      T
+		expected ComparabilityFlags
+	}{
+		{Int, Equatable | Orderable | Hashable},
+		{Name, Equatable | Orderable | Hashable},
+		{TCollatedString{Locale: "en"}, Equatable | Orderable | Hashable},
+		{MakeEnum(100080, []string{"a"}), Equatable | Orderable | Hashable},
+		{JSON, Equatable},
+		{Any, 0},
+		{TArray{Typ: Int}, Equatable},
+		{IntVector, Equatable},
+		{TArray{Typ: JSON}, Equatable},
+		{AnyArray, 0},
+		{TTuple{Types: []T{Int, String}}, Equatable | Orderable},
+		{TTuple{Types: []T{Int, JSON}}, Equatable},
+		{TTuple{Types: []T{Int, TArray{Typ: Int}}}, Equatable},
+		{TTuple{}, Equatable | Orderable},
+	}
+	for _, tc := range testCases {
+		if f := Comparability(tc.typ); f != tc.expected {
+			t.Errorf("%s: expected %b, got %b", tc.typ, tc.expected, f)
+		}
+	}
+	if f := Comparability(JSON); f.Has(Equatable|Orderable) || !f.Has(Equatable) {
+		t.Errorf("unexpected flags %b for %s", f, JSON)
+	}
+}
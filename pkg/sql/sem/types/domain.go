@@ -0,0 +1,73 @@
+// Copyright 2018 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package types
+
+import (
+	"fmt"
+
+	"github.com/lib/pq/oid"
+)
+
+// TDomain is the type of a domain, i.e. of a user-defined type whose values
+// are those of its base type which satisfy the domain's constraints. Each
+// domain is identified by the OID allocated to it on creation. It behaves like
+// its base type in all respects other than its OID and name; the constraints
+// are carried along for the benefit of the catalog and are not enforced by
+// the type system.
+type TDomain struct {
+	T
+	DomainOid oid.Oid
+	// NotNull is set if the domain was declared NOT NULL.
+	NotNull bool
+	// Check is the expression of the domain's CHECK constraint, or empty if it
+	// has none.
+	Check string
+}
+
+// MakeDomain returns the domain type with the given OID over the provided
+// base type. Domains over domains are flattened onto the underlying base
+// type, with the constraints of both.
+func MakeDomain(o oid.Oid, base T, notNull bool, check string) T {
+	if d, ok := base.(TDomain); ok {
+		base = d.T
+		notNull = notNull || d.NotNull
+		switch {
+		case check == "":
+			check = d.Check
+		case d.Check != "":
+			check = fmt.Sprintf("(%s) AND (%s)", d.Check, check)
+		}
+	}
+	return TDomain{T: base, DomainOid: o, NotNull: notNull, Check: check}
+}
+
+// DomainBaseType returns the base type of the provided domain type. The
+// boolean is false if t is not a domain type.
+func DomainBaseType(t T) (T, bool) {
+	if d, ok := t.(TDomain); ok {
+		return d.T, true
+	}
+	return nil, false
+}
+
+// String implements the fmt.Stringer interface.
+func (t TDomain) String() string { return fmt.Sprintf("domain{%d}", t.DomainOid) }
+
+// Oid implements the T interface.
+func (t TDomain) Oid() oid.Oid { return t.DomainOid }
+
+// SQLName implements the T interface. The name of a domain is stored with its
+// descriptor rather than in the type, so the type is named after its OID.
+func (t TDomain) SQLName() string { return t.String() }
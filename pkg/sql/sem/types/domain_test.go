@@ -0,0 +1,61 @@
+// Copyright 2018 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package types
+
+import "testing"
+
+func TestDomain(t *testing.T) {
+	positive := MakeDomain(100070, Int, true, "VALUE > 0")
+	if o := positive.Oid(); o != 100070 {
+		t.Errorf("expected oid 100070, got %d", o)
+	}
+	if base, ok := DomainBaseType(positive); !ok || base != Int {
+		t.Errorf("expected base type %s, got %v", Int, base)
+	}
+	if _, ok := DomainBaseType(Int); ok {
+		t.Errorf("expected %s not to be a domain", Int)
+	}
+	if u := UnwrapType(positive); u != Int {
+		t.Errorf("expected %s to unwrap to %s, got %s", positive, Int, u)
+	}
+	if !positive.Equivalent(Int) || !Int.Equivalent(positive) {
+		t.Errorf("expected %s to be equivalent to its base type", positive)
+	}
+	if positive.Equivalent(String) {
+		t.Errorf("expected %s not to be equivalent to %s", positive, String)
+	}
+	if Identical(positive, Int) || !Identical(positive, MakeDomain(100070, Int, true, "VALUE > 0")) {
+		t.Errorf("unexpected identity of %s", positive)
+	}
+
+	small := MakeDomain(100071, positive, false, "VALUE < 10")
+	expected := TDomain{T: Int, DomainOid: 100071, NotNull: true, Check: "(VALUE > 0) AND (VALUE < 10)"}
+	if !Identical(small, expected) {
+		t.Errorf("expected %+v, got %+v", expected, small)
+	}
+
+	tuple := MakeDomain(100072, TTuple{Types: []T{Int}}, false, "")
+	if !Identical(tuple, MakeDomain(100072, TTuple{Types: []T{Int}}, false, "")) {
+		t.Errorf("expected %s to be identical to itself", tuple)
+	}
+
+	if _, ok := LookupTypeByOid(100070); ok {
+		t.Fatal("expected the domain not to be registered yet")
+	}
+	RegisterTypeOid(100070, positive)
+	if typ, ok := LookupTypeByOid(100070); !ok || !Identical(typ, positive) {
+		t.Errorf("expected %s, got %v", positive, typ)
+	}
+}
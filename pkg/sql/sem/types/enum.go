@@ -0,0 +1,100 @@
+// Copyright 2018 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package types
+
+import (
+	"fmt"
+
+	"github.com/lib/pq/oid"
+)
+
+// TEnum is the type of a user-defined enum. Each enum type is identified by
+// the OID allocated to it on creation, and its values are the labels in the
+// order in which they sort. CANNOT be compared with ==.
+type TEnum struct {
+	EnumOid oid.Oid
+	Labels  []string
+}
+
+// MakeEnum returns the enum type with the given OID and ordered labels. The
+// labels are copied.
+func MakeEnum(o oid.Oid, labels []string) T {
+	return TEnum{EnumOid: o, Labels: append([]string(nil), labels...)}
+}
+
+// String implements the fmt.Stringer interface.
+func (t TEnum) String() string { return fmt.Sprintf("enum{%d}", t.EnumOid) }
+
+// Equivalent implements the T interface. Enums are equivalent if they have
+// the same OID.
+func (t TEnum) Equivalent(other T) bool {
+	if other == Any {
+		return true
+	}
+	u, ok := UnwrapType(other).(TEnum)
+	return ok && t.EnumOid == u.EnumOid
+}
+
+// FamilyEqual implements the T interface.
+func (TEnum) FamilyEqual(other T) bool {
+	_, ok := UnwrapType(other).(TEnum)
+	return ok
+}
+
+// Oid implements the T interface.
+func (t TEnum) Oid() oid.Oid { return t.EnumOid }
+
+// SQLName implements the T interface. The name of an enum is stored with its
+// descriptor rather than in the type, so the type is named after its OID.
+func (t TEnum) SQLName() string { return t.String() }
+
+// IsAmbiguous implements the T interface.
+func (TEnum) IsAmbiguous() bool { return false }
+
+// EnumLabels returns a copy of the labels of the provided enum type in the
+// order in which they sort, which is the order in which they were defined
+// unless labels were inserted before existing ones. The position of a label
+// in this list is its ordinal, see EnumOrdinal. The boolean is false if t is
+// not an enum type.
+func EnumLabels(t T) ([]string, bool) {
+	e, ok := UnwrapType(t).(TEnum)
+	if !ok {
+		return nil, false
+	}
+	return append([]string(nil), e.Labels...), true
+}
+
+// EnumOrdinal returns the ordinal of the provided label of an enum type, i.e.
+// its position in the list returned by EnumLabels. The boolean is false if t
+// is not an enum type or if label is not one of its labels.
+func EnumOrdinal(t T, label string) (int, bool) {
+	e, ok := UnwrapType(t).(TEnum)
+	if !ok {
+		return 0, false
+	}
+	for i, l := range e.Labels {
+		if l == label {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// RegisterEnum makes the provided enum type available to LookupTypeByOid.
+// Registering an enum again with the same OID replaces it, e.g. after labels
+// have been added to it.
+func RegisterEnum(t TEnum) {
+	RegisterTypeOid(t.EnumOid, t)
+}
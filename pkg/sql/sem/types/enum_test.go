@@ -0,0 +1,110 @@
+// Copyright 2018 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package types
+
+import (
+	"testing"
+
+	"github.com/lib/pq/oid"
+)
+
+func TestEnum(t *testing.T) {
+	labels := []string{"sad", "ok", "happy"}
+	mood := MakeEnum(100050, labels)
+	labels[0] = "angry"
+	if l := mood.(TEnum).Labels; l[0] != "sad" {
+		t.Fatalf("expected the labels to be copied, got %v", l)
+	}
+	if o := mood.Oid(); o != 100050 {
+		t.Errorf("expected oid 100050, got %d", o)
+	}
+
+	other := MakeEnum(100051, []string{"sad", "ok", "happy"})
+	testCases := []struct {
+		a, b       T
+		equivalent bool
+	}{
+		{mood, mood, true},
+		{mood, MakeEnum(100050, []string{"sad", "ok", "happy", "ecstatic"}), true},
+		{mood, other, false},
+		{mood, Any, true},
+		{mood, String, false},
+		{String, mood, false},
+	}
+	for _, tc := range testCases {
+		if res := tc.a.Equivalent(tc.b); res != tc.equivalent {
+			t.Errorf("%s.Equivalent(%s): expected %t, got %t", tc.a, tc.b, tc.equivalent, res)
+		}
+	}
+	if !mood.FamilyEqual(other) {
+		t.Errorf("expected %s and %s to be in the same family", mood, other)
+	}
+	if !Identical(mood, MakeEnum(100050, []string{"sad", "ok", "happy"})) {
+		t.Errorf("expected %s to be identical to itself", mood)
+	}
+	if Identical(mood, other) {
+		t.Errorf("expected %s and %s not to be identical", mood, other)
+	}
+	if _, ok := CommonType(mood, other); ok {
+		t.Errorf("expected %s and %s to have no common type", mood, other)
+	}
+}
+
+func TestEnumLabels(t *testing.T) {
+	mood := MakeEnum(100052, []string{"sad", "ok", "happy"})
+	labels, ok := EnumLabels(mood)
+	if !ok {
+		t.Fatalf("expected %s to have labels", mood)
+	}
+	if len(labels) != 3 || labels[0] != "sad" || labels[1] != "ok" || labels[2] != "happy" {
+		t.Fatalf("unexpected labels %v", labels)
+	}
+	labels[0] = "angry"
+	if l := mood.(TEnum).Labels; l[0] != "sad" {
+		t.Errorf("expected the labels to be copied, got %v", l)
+	}
+	for i, label := range []string{"sad", "ok", "happy"} {
+		if ord, ok := EnumOrdinal(mood, label); !ok || ord != i {
+			t.Errorf("expected ordinal %d for %s, got %d (%t)", i, label, ord, ok)
+		}
+	}
+	if _, ok := EnumOrdinal(mood, "angry"); ok {
+		t.Error("expected no ordinal for an unknown label")
+	}
+	if _, ok := EnumLabels(String); ok {
+		t.Errorf("expected %s to have no labels", String)
+	}
+	if _, ok := EnumOrdinal(String, "sad"); ok {
+		t.Errorf("expected %s to have no ordinals", String)
+	}
+}
+
+func TestLookupTypeByOid(t *testing.T) {
+	if typ, ok := LookupTypeByOid(oid.T_int8); !ok || typ != Int {
+		t.Errorf("expected %s, got %v", Int, typ)
+	}
+	if typ, ok := LookupTypeByOid(100060); ok {
+		t.Fatalf("expected no type, got %s", typ)
+	}
+	RegisterEnum(MakeEnum(100060, []string{"a"}).(TEnum))
+	RegisterEnum(MakeEnum(100060, []string{"a", "b"}).(TEnum))
+	typ, ok := LookupTypeByOid(100060)
+	if !ok {
+		t.Fatal("expected the enum to be registered")
+	}
+	if expected := MakeEnum(100060, []string{"a", "b"}); !Identical(typ, expected) {
+		t.Errorf("expected %s with labels %v, got %v", expected, expected.(TEnum).Labels, typ)
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright 2018 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package types
+
+import (
+	"encoding/binary"
+	"hash"
+	"hash/fnv"
+)
+
+// Tags which start the encoding of each kind of type hashed by Fingerprint.
+// They are part of the persisted fingerprints, so existing values must not be
+// changed.
+const (
+	fingerprintNil byte = iota
+	fingerprintScalar
+	fingerprintOidWrapper
+	fingerprintSizedString
+	fingerprintCollatedString
+	fingerprintRestrictedInterval
+	fingerprintOid
+	fingerprintPlaceholder
+	fingerprintArray
+	fingerprintTuple
+	fingerprintEnum
+	fingerprintDomain
+	fingerprintMultiDimArray
+)
+
+// Fingerprint returns a hash of the provided type which can be used to key
+// caches by exact type, e.g. cached plans by the types of their columns. Types
+// which are Identical have the same fingerprint, whereas types which only are
+// Equivalent, such as VARCHAR(5) and VARCHAR(10), generally don't.
+//
+// The fingerprint is computed from the OIDs, widths, fields, locales, labels
+// and constraints which make up the type rather than from its name or its Go
+// representation, so it is stable across versions and may be persisted.
+func Fingerprint(t T) uint64 {
+	h := fnv.New64a()
+	fingerprintType(h, t)
+	return h.Sum64()
+}
+
+func fingerprintType(h hash.Hash64, t T) {
+	switch typ := t.(type) {
+	case nil:
+		writeFingerprintTag(h, fingerprintNil)
+	case TOidWrapper:
+		writeFingerprintTag(h, fingerprintOidWrapper)
+		writeFingerprintUint(h, uint64(typ.oid))
+		fingerprintType(h, typ.T)
+	case TSizedString:
+		writeFingerprintTag(h, fingerprintSizedString)
+		writeFingerprintUint(h, uint64(typ.Width))
+		fingerprintType(h, typ.T)
+	case TCollatedString:
+		writeFingerprintTag(h, fingerprintCollatedString)
+		writeFingerprintString(h, typ.Locale)
+	case TRestrictedInterval:
+		writeFingerprintTag(h, fingerprintRestrictedInterval)
+		writeFingerprintUint(h, uint64(typ.Fields))
+	case TOid:
+		writeFingerprintTag(h, fingerprintOid)
+		writeFingerprintUint(h, uint64(typ.oidType))
+	case TPlaceholder:
+		writeFingerprintTag(h, fingerprintPlaceholder)
+		writeFingerprintUint(h, uint64(typ.Idx))
+	case TArray:
+		writeFingerprintTag(h, fingerprintArray)
+		fingerprintType(h, typ.Typ)
+	case TMultiDimArray:
+		writeFingerprintTag(h, fingerprintMultiDimArray)
+		writeFingerprintUint(h, uint64(typ.Dims))
+		fingerprintType(h, typ.Typ)
+	case TTuple:
+		writeFingerprintTag(h, fingerprintTuple)
+		writeFingerprintUint(h, uint64(len(typ.Types)))
+		for _, field := range typ.Types {
+			fingerprintType(h, field)
+		}
+		writeFingerprintUint(h, uint64(len(typ.Labels)))
+		for _, label := range typ.Labels {
+			writeFingerprintString(h, label)
+		}
+	case TEnum:
+		writeFingerprintTag(h, fingerprintEnum)
+		writeFingerprintUint(h, uint64(typ.EnumOid))
+		writeFingerprintUint(h, uint64(len(typ.Labels)))
+		for _, label := range typ.Labels {
+			writeFingerprintString(h, label)
+		}
+	case TDomain:
+		writeFingerprintTag(h, fingerprintDomain)
+		writeFingerprintUint(h, uint64(typ.DomainOid))
+		notNull := uint64(0)
+		if typ.NotNull {
+			notNull = 1
+		}
+		writeFingerprintUint(h, notNull)
+		writeFingerprintString(h, typ.Check)
+		fingerprintType(h, typ.T)
+	default:
+		// The remaining types are the unparameterized scalar types, which are
+		// identified by their OID.
+		writeFingerprintTag(h, fingerprintScalar)
+		writeFingerprintUint(h, uint64(t.Oid()))
+	}
+}
+
+func writeFingerprintTag(h hash.Hash64, tag byte) {
+	_, _ = h.Write([]byte{tag})
+}
+
+func writeFingerprintUint(h hash.Hash64, v uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	_, _ = h.Write(buf[:binary.PutUvarint(buf[:], v)])
+}
+
+// writeFingerprintString writes s prefixed by its length, so that the
+// encodings of consecutive strings are unambiguous.
+func writeFingerprintString(h hash.Hash64, s string) {
+	writeFingerprintUint(h, uint64(len(s)))
+	_, _ = h.Write([]byte(s))
+}
@@ -0,0 +1,105 @@
+// Copyright 2018 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package types
+
+import "testing"
+
+func TestFingerprint(t *testing.T) {
+	// Each of these types must have a distinct fingerprint.
+	distinct := []T{
+		nil,
+		Int,
+		String,
+		Bytes,
+		Name,
+		Oid,
+		RegClass,
+		Interval,
+		MakeRestrictedInterval(IntervalFieldYear),
+		MakeRestrictedInterval(IntervalFieldDay),
+		MakeVarChar(0),
+		MakeVarChar(5),
+		MakeVarChar(10),
+		MakeChar(5),
+		MakeChar(10),
+		TCollatedString{Locale: "en"},
+		TCollatedString{Locale: "de"},
+		TArray{Typ: Int},
+		TArray{Typ: String},
+		TArray{Typ: MakeVarChar(5)},
+		TArray{Typ: MakeVarChar(10)},
+		MakeArrayWithDims(Int, 2),
+		MakeArrayWithDims(Int, 3),
+		MakeArrayWithDims(String, 2),
+		IntVector,
+		AnyArray,
+		TTuple{},
+		TTuple{Types: []T{Int}},
+		TTuple{Types: []T{Int, String}},
+		TTuple{Types: []T{String, Int}},
+		TTuple{Types: []T{Int, String}, Labels: []string{"a", "b"}},
+		TTuple{Types: []T{Int, String}, Labels: []string{"ab", ""}},
+		TTuple{Types: []T{TTuple{Types: []T{Int}}, String}},
+		TTuple{Types: []T{TTuple{Types: []T{Int, String}}}},
+		MakeEnum(100080, []string{"a", "b"}),
+		MakeEnum(100080, []string{"b", "a"}),
+		MakeEnum(100081, []string{"a", "b"}),
+		MakeDomain(100090, Int, false, ""),
+		MakeDomain(100090, Int, true, ""),
+		MakeDomain(100090, Int, false, "VALUE > 0"),
+		MakeDomain(100091, Int, false, ""),
+		MakeDomain(100090, MakeVarChar(5), false, ""),
+		MakeDomain(100090, MakeVarChar(10), false, ""),
+		TPlaceholder{Idx: 0},
+		TPlaceholder{Idx: 1},
+	}
+	for _, typ := range OidToType {
+		if _, ok := typ.(TArray); !ok {
+			distinct = append(distinct, typ)
+		}
+	}
+	seen := make(map[uint64]T)
+	for _, typ := range distinct {
+		fp := Fingerprint(typ)
+		if other, ok := seen[fp]; ok && !Identical(other, typ) {
+			t.Errorf("%v and %v have the same fingerprint %x", other, typ, fp)
+		}
+		seen[fp] = typ
+	}
+
+	// Identical types must have the same fingerprint, even when they don't
+	// share their representation.
+	identical := [][2]T{
+		{MakeVarChar(5), MakeVarChar(5)},
+		{TArray{Typ: MakeChar(3)}, TArray{Typ: MakeChar(3)}},
+		{
+			TTuple{Types: []T{Int, String}, Labels: []string{"a", "b"}},
+			TTuple{Types: []T{Int, String}, Labels: []string{"a", "b"}},
+		},
+		{MakeEnum(100080, []string{"a", "b"}), MakeEnum(100080, []string{"a", "b"})},
+		{
+			MakeDomain(100090, MakeDomain(100091, Int, true, "VALUE > 0"), false, ""),
+			MakeDomain(100090, Int, true, "VALUE > 0"),
+		},
+	}
+	for _, tc := range identical {
+		if !Identical(tc[0], tc[1]) {
+			t.Fatalf("expected %v and %v to be identical", tc[0], tc[1])
+		}
+		if a, b := Fingerprint(tc[0]), Fingerprint(tc[1]); a != b {
+			t.Errorf("%v and %v have different fingerprints %x and %x", tc[0], tc[1], a, b)
+		}
+	}
+}
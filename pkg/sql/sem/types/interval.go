@@ -0,0 +1,127 @@
+// Copyright 2018 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package types
+
+import (
+	"fmt"
+
+	"github.com/lib/pq/oid"
+)
+
+// IntervalFields is a bitmask of the fields to which an interval is
+// restricted, as in INTERVAL DAY TO SECOND. The bit values match those used
+// by Postgres in interval type modifiers.
+type IntervalFields int32
+
+// These constants designate the fields of an interval.
+const (
+	IntervalFieldMonth  IntervalFields = 1 << 1
+	IntervalFieldYear   IntervalFields = 1 << 2
+	IntervalFieldDay    IntervalFields = 1 << 3
+	IntervalFieldHour   IntervalFields = 1 << 10
+	IntervalFieldMinute IntervalFields = 1 << 11
+	IntervalFieldSecond IntervalFields = 1 << 12
+
+	// IntervalFieldsAll designates an unrestricted interval.
+	IntervalFieldsAll IntervalFields = 0x7FFF
+)
+
+// intervalFieldNames lists the field restrictions allowed by the SQL grammar.
+var intervalFieldNames = map[IntervalFields]string{
+	IntervalFieldYear:   "year",
+	IntervalFieldMonth:  "month",
+	IntervalFieldDay:    "day",
+	IntervalFieldHour:   "hour",
+	IntervalFieldMinute: "minute",
+	IntervalFieldSecond: "second",
+
+	IntervalFieldYear | IntervalFieldMonth:                                           "year to month",
+	IntervalFieldDay | IntervalFieldHour:                                             "day to hour",
+	IntervalFieldDay | IntervalFieldHour | IntervalFieldMinute:                       "day to minute",
+	IntervalFieldDay | IntervalFieldHour | IntervalFieldMinute | IntervalFieldSecond: "day to second",
+	IntervalFieldHour | IntervalFieldMinute:                                          "hour to minute",
+	IntervalFieldHour | IntervalFieldMinute | IntervalFieldSecond:                    "hour to second",
+	IntervalFieldMinute | IntervalFieldSecond:                                        "minute to second",
+}
+
+func (f IntervalFields) String() string {
+	if s, ok := intervalFieldNames[f]; ok {
+		return s
+	}
+	return fmt.Sprintf("IntervalFields(%d)", int32(f))
+}
+
+// intervalFullPrecision is the typmod precision of an interval whose
+// fractional seconds precision is unrestricted.
+const intervalFullPrecision = 0xFFFF
+
+// IntervalTypmod returns the Postgres type modifier of an interval restricted
+// to the provided fields. Unrestricted intervals have a type modifier of -1.
+func IntervalTypmod(fields IntervalFields) int32 {
+	if fields == 0 || fields == IntervalFieldsAll {
+		return -1
+	}
+	return int32(fields)<<16 | intervalFullPrecision
+}
+
+// IntervalFieldsFromTypmod is the inverse of IntervalTypmod.
+func IntervalFieldsFromTypmod(typmod int32) IntervalFields {
+	if typmod < 0 {
+		return IntervalFieldsAll
+	}
+	return IntervalFields(typmod>>16) & IntervalFieldsAll
+}
+
+// TRestrictedInterval is the type of an interval restricted to a subset of
+// its fields. It behaves like Interval in all other respects.
+type TRestrictedInterval struct {
+	Fields IntervalFields
+}
+
+// MakeRestrictedInterval returns the type of an interval restricted to the
+// provided fields, or Interval if the fields don't restrict it.
+func MakeRestrictedInterval(fields IntervalFields) T {
+	if fields == 0 || fields == IntervalFieldsAll {
+		return Interval
+	}
+	return TRestrictedInterval{Fields: fields}
+}
+
+// IntervalFieldsFromType returns the fields to which the provided interval
+// type is restricted. The boolean is false if the type is not a restricted
+// interval.
+func IntervalFieldsFromType(t T) (IntervalFields, bool) {
+	if r, ok := t.(TRestrictedInterval); ok {
+		return r.Fields, true
+	}
+	return IntervalFieldsAll, false
+}
+
+func (t TRestrictedInterval) String() string { return "interval " + t.Fields.String() }
+
+// Equivalent implements the T interface.
+func (TRestrictedInterval) Equivalent(other T) bool { return Interval.Equivalent(other) }
+
+// FamilyEqual implements the T interface.
+func (TRestrictedInterval) FamilyEqual(other T) bool { return Interval.FamilyEqual(other) }
+
+// Oid implements the T interface.
+func (TRestrictedInterval) Oid() oid.Oid { return oid.T_interval }
+
+// SQLName implements the T interface.
+func (TRestrictedInterval) SQLName() string { return Interval.SQLName() }
+
+// IsAmbiguous implements the T interface.
+func (TRestrictedInterval) IsAmbiguous() bool { return false }
@@ -0,0 +1,60 @@
+// Copyright 2018 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package types
+
+import "testing"
+
+func TestIntervalTypmod(t *testing.T) {
+	testCases := []struct {
+		fields IntervalFields
+		typmod int32
+		str    string
+	}{
+		{IntervalFieldYear, 327679, "year"},
+		{IntervalFieldYear | IntervalFieldMonth, 458751, "year to month"},
+		{IntervalFieldDay | IntervalFieldHour | IntervalFieldMinute | IntervalFieldSecond, 470351871, "day to second"},
+		{IntervalFieldMinute | IntervalFieldSecond, 402718719, "minute to second"},
+		{IntervalFieldsAll, -1, ""},
+	}
+	for _, tc := range testCases {
+		if typmod := IntervalTypmod(tc.fields); typmod != tc.typmod {
+			t.Errorf("%s: expected typmod %d, got %d", tc.fields, tc.typmod, typmod)
+		}
+		if fields := IntervalFieldsFromTypmod(tc.typmod); fields != tc.fields {
+			t.Errorf("%d: expected fields %s, got %s", tc.typmod, tc.fields, fields)
+		}
+
+		typ := MakeRestrictedInterval(tc.fields)
+		fields, ok := IntervalFieldsFromType(typ)
+		if tc.str == "" {
+			if ok || typ != Interval {
+				t.Errorf("expected unrestricted interval, got %s", typ)
+			}
+			continue
+		}
+		if !ok || fields != tc.fields {
+			t.Errorf("expected fields %s, got %s (ok=%t)", tc.fields, fields, ok)
+		}
+		if s := typ.String(); s != "interval "+tc.str {
+			t.Errorf("expected interval %s, got %s", tc.str, s)
+		}
+		if typ.Oid() != Interval.Oid() || !typ.Equivalent(Interval) || !Interval.Equivalent(typ) {
+			t.Errorf("expected %s to be equivalent to %s", typ, Interval)
+		}
+		if UnwrapType(typ) != Interval {
+			t.Errorf("expected %s to unwrap to %s", typ, Interval)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright 2018 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package types
+
+import (
+	"strings"
+
+	"github.com/lib/pq/oid"
+)
+
+// TypeIOFuncs holds the names of the functions which convert the values of a
+// type from and to their text and binary representations, as referenced by
+// the typinput, typoutput, typreceive and typsend columns of pg_type.
+type TypeIOFuncs struct {
+	Input   string
+	Output  string
+	Receive string
+	Send    string
+}
+
+// ioFuncsHaveUnderscore is a map to keep track of which types have i/o
+// functions with underscores in between their type name and the i/o function
+// name, like date_in vs int8in. There seems to be no other way to
+// programmatically determine whether or not this underscore is present, hence
+// the existence of this map.
+var ioFuncsHaveUnderscore = map[oid.Oid]struct{}{
+	Any.Oid():         {},
+	AnyArray.Oid():    {},
+	CString.Oid():     {},
+	Internal.Oid():    {},
+	Date.Oid():        {},
+	Time.Oid():        {},
+	Decimal.Oid():     {},
+	Interval.Oid():    {},
+	JSON.Oid():        {},
+	Jsonpath.Oid():    {},
+	PgLSN.Oid():       {},
+	UUID.Oid():        {},
+	oid.T_varbit:      {},
+	oid.T_bit:         {},
+	Timestamp.Oid():   {},
+	TimestampTZ.Oid(): {},
+	FamTuple.Oid():    {},
+}
+
+// PGIOFuncPrefix returns the string prefix to a type's IO functions. This is
+// either the type's postgres display name or the type's postgres display name
+// plus an underscore, depending on the type. Array types other than int2vector
+// and oidvector share the IO functions of anyarray, whose prefix is returned
+// by TypeIOFunctions rather than by PGIOFuncPrefix.
+func PGIOFuncPrefix(typ T) string {
+	prefix := strings.ToLower(PGTypeName(typ.Oid()))
+	if _, ok := ioFuncsHaveUnderscore[typ.Oid()]; ok {
+		return prefix + "_"
+	}
+	return prefix
+}
+
+// TypeIOFunctions returns the names of the IO functions of the provided type,
+// e.g. textin and textout for text or array_in and array_out for arrays. The
+// names match those of the IO builtins.
+func TypeIOFunctions(typ T) TypeIOFuncs {
+	prefix := PGIOFuncPrefix(typ)
+	if typ.FamilyEqual(FamArray) && typ != AnyArray && typ != IntVector && typ != OidVector {
+		prefix = "array_"
+	}
+	return TypeIOFuncs{
+		Input:   prefix + "in",
+		Output:  prefix + "out",
+		Receive: prefix + "recv",
+		Send:    prefix + "send",
+	}
+}
+
+// binaryWireLengths holds the lengths of the binary encodings of the values of
+// the fixed-width types, as sent over pgwire.
+var binaryWireLengths = map[oid.Oid]int{
+	oid.T_bool:        1,
+	oid.T_int2:        2,
+	oid.T_int4:        4,
+	oid.T_int8:        8,
+	oid.T_xid:         4,
+	oid.T_cid:         4,
+	oid.T_float4:      4,
+	oid.T_float8:      8,
+	oid.T_uuid:        16,
+	oid.T_date:        4,
+	oid.T_time:        8,
+	oid.T_timestamp:   8,
+	oid.T_timestamptz: 8,
+	oid.T_interval:    16,
+}
+
+// BinaryWireLength returns the length in bytes of the binary encoding of the
+// values of the provided type, e.g. 4 for int4 and 16 for uuid, or -1 if the
+// values are of variable length. For the fixed-width types, this is also the
+// typlen of the type in pg_type.
+func BinaryWireLength(t T) int {
+	switch typ := t.(type) {
+	case TOid:
+		// All of the OID variants are encoded as an int4.
+		return 4
+	case TDomain:
+		return BinaryWireLength(typ.T)
+	}
+	if l, ok := binaryWireLengths[t.Oid()]; ok {
+		return l
+	}
+	return -1
+}
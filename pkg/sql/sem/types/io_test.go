@@ -0,0 +1,80 @@
+// Copyright 2018 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package types
+
+import "testing"
+
+func TestTypeIOFunctions(t *testing.T) {
+	testCases := []struct {
+		typ      T
+		expected TypeIOFuncs
+	}{
+		{String, TypeIOFuncs{"textin", "textout", "textrecv", "textsend"}},
+		{Int, TypeIOFuncs{"int8in", "int8out", "int8recv", "int8send"}},
+		{Date, TypeIOFuncs{"date_in", "date_out", "date_recv", "date_send"}},
+		{TArray{Int}, TypeIOFuncs{"array_in", "array_out", "array_recv", "array_send"}},
+		{AnyArray, TypeIOFuncs{"anyarray_in", "anyarray_out", "anyarray_recv", "anyarray_send"}},
+		{IntVector, TypeIOFuncs{"int2vectorin", "int2vectorout", "int2vectorrecv", "int2vectorsend"}},
+		{FamTuple, TypeIOFuncs{"record_in", "record_out", "record_recv", "record_send"}},
+	}
+	for _, tc := range testCases {
+		if f := TypeIOFunctions(tc.typ); f != tc.expected {
+			t.Errorf("%s: expected %+v, got %+v", tc.typ, tc.expected, f)
+		}
+	}
+}
+
+func TestBinaryWireLength(t *testing.T) {
+	testCases := []struct {
+		typ      T
+		expected int
+	}{
+		{Bool, 1},
+		{typeInt2, 2},
+		{typeInt4, 4},
+		{Int, 8},
+		{typeFloat4, 4},
+		{Float, 8},
+		{UUID, 16},
+		{Date, 4},
+		{Time, 8},
+		{Timestamp, 8},
+		{TimestampTZ, 8},
+		{Interval, 16},
+		{MakeRestrictedInterval(IntervalFieldYear), 16},
+		{Oid, 4},
+		{RegClass, 4},
+		{Xid, 4},
+		{Money, -1},
+		{MakeDomain(100090, typeInt4, false, ""), 4},
+		{Decimal, -1},
+		{String, -1},
+		{MakeChar(3), -1},
+		{Name, -1},
+		{Bytes, -1},
+		{JSON, -1},
+		{INet, -1},
+		{BitArray, -1},
+		{TArray{Typ: Int}, -1},
+		{IntVector, -1},
+		{TTuple{Types: []T{Int}}, -1},
+		{MakeEnum(100080, []string{"a"}), -1},
+	}
+	for _, tc := range testCases {
+		if l := BinaryWireLength(tc.typ); l != tc.expected {
+			t.Errorf("%s: expected %d, got %d", tc.typ, tc.expected, l)
+		}
+	}
+}
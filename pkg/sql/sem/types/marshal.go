@@ -0,0 +1,456 @@
+// Copyright 2015 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package types
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+
+	"github.com/lib/pq/oid"
+	"github.com/pkg/errors"
+)
+
+// marshalVersion is the version of the format produced by Marshal. It must be
+// bumped whenever the format changes incompatibly, in which case Unmarshal
+// should keep accepting the previous versions.
+const marshalVersion = 1
+
+// Tags which start the encoding of each kind of type in the format produced by
+// Marshal.
+const (
+	marshalNil                = 'n'
+	marshalScalar             = 'b'
+	marshalOidWrapper         = 'w'
+	marshalSizedString        = 's'
+	marshalCollatedString     = 'c'
+	marshalRestrictedInterval = 'i'
+	marshalOid                = 'o'
+	marshalPlaceholder        = 'p'
+	marshalArray              = 'a'
+	marshalTuple              = 't'
+	marshalEnum               = 'e'
+	marshalDomain             = 'd'
+	marshalMultiDimArray      = 'm'
+)
+
+// scalarTypes maps the OIDs of the unparameterized scalar types to the types.
+var scalarTypes = func() map[oid.Oid]T {
+	m := make(map[oid.Oid]T)
+	for _, t := range []T{
+		Unknown, Bool, BitArray, Int, Float, Decimal, String, Bytes, Date, Time,
+		Timestamp, TimestampTZ, Interval, JSON, UUID, INet, Any, Internal,
+	} {
+		m[t.Oid()] = t
+	}
+	return m
+}()
+
+// Marshal returns a representation of the provided type which can be stored
+// outside of descriptors, e.g. by migration scripts and catalog dumps, and
+// parsed back by Unmarshal. Unlike String, which is meant for display, the
+// representation is canonical: types which are Identical are represented
+// identically. It starts with the version of the format, e.g. "1:a(b20)" for
+// INT[] or "1:s10(w1043(b25))" for VARCHAR(10).
+func Marshal(t T) string {
+	var buf bytes.Buffer
+	buf.WriteString(strconv.Itoa(marshalVersion))
+	buf.WriteByte(':')
+	marshalType(&buf, t)
+	return buf.String()
+}
+
+func marshalType(buf *bytes.Buffer, t T) {
+	switch typ := t.(type) {
+	case nil:
+		buf.WriteByte(marshalNil)
+	case TOidWrapper:
+		marshalTag(buf, marshalOidWrapper, uint64(typ.oid))
+		marshalNested(buf, typ.T)
+	case TSizedString:
+		marshalTag(buf, marshalSizedString, uint64(typ.Width))
+		marshalNested(buf, typ.T)
+	case TCollatedString:
+		buf.WriteByte(marshalCollatedString)
+		marshalString(buf, typ.Locale)
+	case TRestrictedInterval:
+		marshalTag(buf, marshalRestrictedInterval, uint64(typ.Fields))
+	case TOid:
+		marshalTag(buf, marshalOid, uint64(typ.oidType))
+	case TPlaceholder:
+		marshalTag(buf, marshalPlaceholder, uint64(typ.Idx))
+	case TArray:
+		buf.WriteByte(marshalArray)
+		marshalNested(buf, typ.Typ)
+	case TMultiDimArray:
+		marshalTag(buf, marshalMultiDimArray, uint64(typ.Dims))
+		marshalNested(buf, typ.Typ)
+	case TTuple:
+		buf.WriteByte(marshalTuple)
+		buf.WriteByte('(')
+		for i, field := range typ.Types {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			marshalType(buf, field)
+		}
+		// The labels are omitted when nil, so as to tell them apart from
+		// an empty list of labels.
+		if typ.Labels != nil {
+			buf.WriteByte(';')
+			marshalStrings(buf, typ.Labels)
+		}
+		buf.WriteByte(')')
+	case TEnum:
+		marshalTag(buf, marshalEnum, uint64(typ.EnumOid))
+		buf.WriteByte('(')
+		marshalStrings(buf, typ.Labels)
+		buf.WriteByte(')')
+	case TDomain:
+		marshalTag(buf, marshalDomain, uint64(typ.DomainOid))
+		buf.WriteByte('(')
+		marshalType(buf, typ.T)
+		buf.WriteByte(';')
+		if typ.NotNull {
+			buf.WriteByte('1')
+		} else {
+			buf.WriteByte('0')
+		}
+		buf.WriteByte(';')
+		marshalString(buf, typ.Check)
+		buf.WriteByte(')')
+	default:
+		// The remaining types are the unparameterized scalar types, which are
+		// identified by their OID.
+		marshalTag(buf, marshalScalar, uint64(t.Oid()))
+	}
+}
+
+func marshalTag(buf *bytes.Buffer, tag byte, v uint64) {
+	buf.WriteByte(tag)
+	buf.WriteString(strconv.FormatUint(v, 10))
+}
+
+func marshalNested(buf *bytes.Buffer, t T) {
+	buf.WriteByte('(')
+	marshalType(buf, t)
+	buf.WriteByte(')')
+}
+
+// marshalString writes s prefixed by its length, e.g. 5:hello, so that it
+// doesn't need to be quoted.
+func marshalString(buf *bytes.Buffer, s string) {
+	buf.WriteString(strconv.Itoa(len(s)))
+	buf.WriteByte(':')
+	buf.WriteString(s)
+}
+
+func marshalStrings(buf *bytes.Buffer, strs []string) {
+	for i, s := range strs {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		marshalString(buf, s)
+	}
+}
+
+// Unmarshal parses a type from the representation returned by Marshal.
+func Unmarshal(s string) (T, error) {
+	u := unmarshaler{s: s}
+	version, err := u.uint(32)
+	if err != nil {
+		return nil, err
+	}
+	if version != marshalVersion {
+		return nil, errors.Errorf("unsupported type encoding version %d in %q", version, s)
+	}
+	if err := u.expect(':'); err != nil {
+		return nil, err
+	}
+	t, err := u.typ()
+	if err != nil {
+		return nil, err
+	}
+	if u.pos != len(s) {
+		return nil, u.errorf("unexpected trailing characters")
+	}
+	return t, nil
+}
+
+// unmarshaler parses the representation of a type returned by Marshal.
+type unmarshaler struct {
+	s   string
+	pos int
+}
+
+func (u *unmarshaler) errorf(format string, args ...interface{}) error {
+	return errors.Errorf("invalid type encoding %q at position %d: %s",
+		u.s, u.pos, fmt.Sprintf(format, args...))
+}
+
+func (u *unmarshaler) peek() byte {
+	if u.pos < len(u.s) {
+		return u.s[u.pos]
+	}
+	return 0
+}
+
+func (u *unmarshaler) expect(c byte) error {
+	if u.peek() != c {
+		return u.errorf("expected %q", c)
+	}
+	u.pos++
+	return nil
+}
+
+func (u *unmarshaler) uint(bitSize int) (uint64, error) {
+	start := u.pos
+	for u.pos < len(u.s) && u.s[u.pos] >= '0' && u.s[u.pos] <= '9' {
+		u.pos++
+	}
+	v, err := strconv.ParseUint(u.s[start:u.pos], 10, bitSize)
+	if err != nil {
+		u.pos = start
+		return 0, u.errorf("expected a number")
+	}
+	return v, nil
+}
+
+func (u *unmarshaler) oid() (oid.Oid, error) {
+	v, err := u.uint(32)
+	return oid.Oid(v), err
+}
+
+func (u *unmarshaler) string() (string, error) {
+	n, err := u.uint(32)
+	if err != nil {
+		return "", err
+	}
+	if err := u.expect(':'); err != nil {
+		return "", err
+	}
+	if uint64(len(u.s)-u.pos) < n {
+		return "", u.errorf("string of length %d exceeds the input", n)
+	}
+	s := u.s[u.pos : u.pos+int(n)]
+	u.pos += int(n)
+	return s, nil
+}
+
+// strings parses a list of strings up to the closing parenthesis, which it
+// leaves in place.
+func (u *unmarshaler) strings() ([]string, error) {
+	var strs []string
+	for u.peek() != ')' {
+		if len(strs) > 0 {
+			if err := u.expect(','); err != nil {
+				return nil, err
+			}
+		}
+		s, err := u.string()
+		if err != nil {
+			return nil, err
+		}
+		strs = append(strs, s)
+	}
+	return strs, nil
+}
+
+func (u *unmarshaler) nested() (T, error) {
+	if err := u.expect('('); err != nil {
+		return nil, err
+	}
+	t, err := u.typ()
+	if err != nil {
+		return nil, err
+	}
+	if err := u.expect(')'); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (u *unmarshaler) typ() (T, error) {
+	tag := u.peek()
+	u.pos++
+	switch tag {
+	case marshalNil:
+		return nil, nil
+	case marshalScalar:
+		o, err := u.oid()
+		if err != nil {
+			return nil, err
+		}
+		t, ok := scalarTypes[o]
+		if !ok {
+			return nil, u.errorf("unknown scalar type OID %d", o)
+		}
+		return t, nil
+	case marshalOidWrapper:
+		o, err := u.oid()
+		if err != nil {
+			return nil, err
+		}
+		t, err := u.nested()
+		if err != nil {
+			return nil, err
+		}
+		// These are the types which WrapTypeWithOid refuses to wrap.
+		switch t.(type) {
+		case nil, tUnknown, tAny, tInternal, TOidWrapper:
+			return nil, u.errorf("type %s cannot be wrapped with OID %d", t, o)
+		}
+		return TOidWrapper{T: t, oid: o}, nil
+	case marshalSizedString:
+		width, err := u.uint(31)
+		if err != nil {
+			return nil, err
+		}
+		t, err := u.nested()
+		if err != nil {
+			return nil, err
+		}
+		return TSizedString{T: t, Width: int32(width)}, nil
+	case marshalCollatedString:
+		locale, err := u.string()
+		if err != nil {
+			return nil, err
+		}
+		return TCollatedString{Locale: locale}, nil
+	case marshalRestrictedInterval:
+		fields, err := u.uint(31)
+		if err != nil {
+			return nil, err
+		}
+		return TRestrictedInterval{Fields: IntervalFields(fields)}, nil
+	case marshalOid:
+		o, err := u.oid()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := OidToType[o].(TOid); !ok {
+			return nil, u.errorf("unknown OID type %d", o)
+		}
+		return TOid{oidType: o}, nil
+	case marshalPlaceholder:
+		idx, err := u.uint(16)
+		if err != nil {
+			return nil, err
+		}
+		return TPlaceholder{Idx: PlaceholderIdx(idx)}, nil
+	case marshalArray:
+		t, err := u.nested()
+		if err != nil {
+			return nil, err
+		}
+		return TArray{Typ: t}, nil
+	case marshalMultiDimArray:
+		dims, err := u.uint(31)
+		if err != nil {
+			return nil, err
+		}
+		t, err := u.nested()
+		if err != nil {
+			return nil, err
+		}
+		return TMultiDimArray{TArray: TArray{Typ: t}, Dims: int(dims)}, nil
+	case marshalTuple:
+		if err := u.expect('('); err != nil {
+			return nil, err
+		}
+		var tuple TTuple
+		for u.peek() != ')' && u.peek() != ';' {
+			if len(tuple.Types) > 0 {
+				if err := u.expect(','); err != nil {
+					return nil, err
+				}
+			}
+			t, err := u.typ()
+			if err != nil {
+				return nil, err
+			}
+			tuple.Types = append(tuple.Types, t)
+		}
+		if u.peek() == ';' {
+			u.pos++
+			labels, err := u.strings()
+			if err != nil {
+				return nil, err
+			}
+			if len(labels) != len(tuple.Types) {
+				return nil, u.errorf(
+					"expected %d tuple labels, got %d", len(tuple.Types), len(labels))
+			}
+			tuple.Labels = append([]string{}, labels...)
+		}
+		if err := u.expect(')'); err != nil {
+			return nil, err
+		}
+		return tuple, nil
+	case marshalEnum:
+		o, err := u.oid()
+		if err != nil {
+			return nil, err
+		}
+		if err := u.expect('('); err != nil {
+			return nil, err
+		}
+		labels, err := u.strings()
+		if err != nil {
+			return nil, err
+		}
+		if err := u.expect(')'); err != nil {
+			return nil, err
+		}
+		return TEnum{EnumOid: o, Labels: labels}, nil
+	case marshalDomain:
+		o, err := u.oid()
+		if err != nil {
+			return nil, err
+		}
+		if err := u.expect('('); err != nil {
+			return nil, err
+		}
+		base, err := u.typ()
+		if err != nil {
+			return nil, err
+		}
+		if err := u.expect(';'); err != nil {
+			return nil, err
+		}
+		var notNull bool
+		switch u.peek() {
+		case '0':
+		case '1':
+			notNull = true
+		default:
+			return nil, u.errorf("expected 0 or 1")
+		}
+		u.pos++
+		if err := u.expect(';'); err != nil {
+			return nil, err
+		}
+		check, err := u.string()
+		if err != nil {
+			return nil, err
+		}
+		if err := u.expect(')'); err != nil {
+			return nil, err
+		}
+		return TDomain{T: base, DomainOid: o, NotNull: notNull, Check: check}, nil
+	}
+	u.pos--
+	return nil, u.errorf("unknown type tag")
+}
@@ -0,0 +1,122 @@
+// Copyright 2015 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package types
+
+import (
+	"testing"
+
+	"github.com/cockroachdb/cockroach/pkg/testutils"
+	"github.com/lib/pq/oid"
+)
+
+func TestMarshal(t *testing.T) {
+	testCases := []struct {
+		typ      T
+		expected string
+	}{
+		{Int, "1:b20"},
+		{typeInt4, "1:w23(b20)"},
+		{TArray{Typ: Int}, "1:a(b20)"},
+		{MakeVarChar(10), "1:s10(w1043(b25))"},
+		{TCollatedString{Locale: "en"}, "1:c2:en"},
+		{MakeRestrictedInterval(IntervalFieldYear), "1:i4"},
+		{RegClass, "1:o2205"},
+		{TPlaceholder{Idx: 3}, "1:p3"},
+		{FamArray, "1:a(n)"},
+		{TTuple{Types: []T{Int, String}}, "1:t(b20,b25)"},
+		{TTuple{Types: []T{Int}, Labels: []string{"a,b"}}, "1:t(b20;3:a,b)"},
+		{TTuple{Types: []T{}, Labels: []string{}}, "1:t(;)"},
+		{MakeEnum(100080, []string{"a", ""}), "1:e100080(1:a,0:)"},
+		{MakeDomain(100090, Int, true, "VALUE > 0"), "1:d100090(b20;1;9:VALUE > 0)"},
+		{MakeArrayWithDims(Int, 2), "1:m2(b20)"},
+	}
+	for _, tc := range testCases {
+		if s := Marshal(tc.typ); s != tc.expected {
+			t.Errorf("%s: expected %s, got %s", tc.typ, tc.expected, s)
+		}
+	}
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	typs := []T{
+		nil,
+		Unknown,
+		Any,
+		Internal,
+		Interval,
+		MakeRestrictedInterval(IntervalFieldDay),
+		MakeVarChar(5),
+		MakeChar(3),
+		TCollatedString{Locale: "en_us"},
+		TCollatedString{},
+		TPlaceholder{Idx: 7},
+		FamArray,
+		FamTuple,
+		TArray{Typ: MakeVarChar(5)},
+		TArray{Typ: TCollatedString{Locale: "de"}},
+		TTuple{Types: []T{Int, String}},
+		TTuple{Types: []T{Int, String}, Labels: []string{"a", ""}},
+		TTuple{Types: []T{TTuple{Types: []T{Int}}, TArray{Typ: Name}}},
+		MakeEnum(100080, nil),
+		MakeEnum(100080, []string{"a", "b)c"}),
+		MakeDomain(100090, MakeVarChar(5), false, ""),
+		MakeArrayWithDims(MakeVarChar(5), 3),
+		MakeDomain(100090, Int, true, "(VALUE > 0) AND (VALUE < 10)"),
+	}
+	for _, typ := range OidToType {
+		typs = append(typs, typ)
+	}
+	for _, typ := range typs {
+		s := Marshal(typ)
+		res, err := Unmarshal(s)
+		if err != nil {
+			t.Errorf("%s: %v", s, err)
+			continue
+		}
+		if !Identical(res, typ) || Marshal(res) != s {
+			t.Errorf("%s: expected %v, got %v", s, typ, res)
+		}
+	}
+}
+
+func TestUnmarshalError(t *testing.T) {
+	testCases := []struct {
+		s   string
+		err string
+	}{
+		{"", "expected a number"},
+		{"2:b20", "unsupported type encoding version 2"},
+		{"1b20", `expected ':'`},
+		{"1:", "unknown type tag"},
+		{"1:x", "unknown type tag"},
+		{"1:b", "expected a number"},
+		{"1:b1", "unknown scalar type OID 1"},
+		{"1:b20)", "unexpected trailing characters"},
+		{"1:a(b20", `expected '\)'`},
+		{"1:c5:en", "exceeds the input"},
+		{"1:t(b20;1:a", `expected ','`},
+		{"1:d100090(b20;2;0:)", "expected 0 or 1"},
+		{Marshal(TOid{oidType: oid.T_int4}), "unknown OID type 23"},
+		{Marshal(TOidWrapper{T: Any, oid: oid.T_varchar}), "cannot be wrapped with OID 1043"},
+		{Marshal(TOidWrapper{T: typeVarChar, oid: oid.T_bpchar}), "cannot be wrapped"},
+		{Marshal(TTuple{Types: []T{Int}, Labels: []string{"a", "b"}}), "expected 1 tuple labels"},
+		{Marshal(TTuple{Labels: []string{"a"}}), "expected 0 tuple labels, got 1"},
+	}
+	for _, tc := range testCases {
+		if _, err := Unmarshal(tc.s); !testutils.IsError(err, tc.err) {
+			t.Errorf("%q: expected %q, got %v", tc.s, tc.err, err)
+		}
+	}
+}
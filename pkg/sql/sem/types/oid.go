@@ -15,8 +15,12 @@
 package types
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/cockroachdb/cockroach/pkg/sql/pgwire/pgerror"
 	"github.com/cockroachdb/cockroach/pkg/util/log"
+	"github.com/cockroachdb/cockroach/pkg/util/syncutil"
 	"github.com/lib/pq/oid"
 )
 
@@ -33,6 +37,8 @@ var (
 	RegProcedure = TOid{oid.T_regprocedure}
 	// RegType is the type of an regtype OID variant. Can be compared with ==.
 	RegType = TOid{oid.T_regtype}
+	// RegCollation is the type of an regcollation OID variant. Can be compared with ==.
+	RegCollation = TOid{oidRegCollation}
 
 	// Name is a type-alias for String with a different OID. Can be
 	// compared with ==.
@@ -46,8 +52,60 @@ var (
 	// NameArray is the type family of a DArray containing the Name alias type.
 	// Can be compared with ==.
 	NameArray T = TArray{Name}
+	// Money is a type-alias for Decimal with a different OID. Arithmetic on
+	// money values is performed with decimal semantics. Can be compared with ==.
+	Money = WrapTypeWithOid(Decimal, oid.T_money)
+	// Xid is a type-alias for Int with a different OID, used for transaction
+	// ID system columns such as xmin and xmax. Can be compared with ==.
+	Xid = WrapTypeWithOid(Int, oid.T_xid)
+	// Cid is a type-alias for Int with a different OID, used for command ID
+	// system columns such as cmin and cmax. Can be compared with ==.
+	Cid = WrapTypeWithOid(Int, oid.T_cid)
+	// Tid is a type-alias for String with a different OID, used for the tuple
+	// ID system column ctid. Its values are formatted as "(block,offset)", see
+	// FormatTid. Can be compared with ==.
+	Tid = WrapTypeWithOid(String, oid.T_tid)
+	// CString is a type-alias for String with a different OID, used for the
+	// arguments and results of type I/O functions. Can be compared with ==.
+	CString = WrapTypeWithOid(String, oid.T_cstring)
+	// Jsonpath is a type-alias for String with a different OID, used for
+	// JSONPath expressions. Its values are represented and encoded as their
+	// text. Can be compared with ==.
+	Jsonpath = WrapTypeWithOid(String, oidJsonpath)
+	// PgLSN is a type-alias for String with a different OID, used for
+	// write-ahead log locations as reported to replication monitoring tools.
+	// Its values are formatted as two hexadecimal numbers separated by a
+	// slash, see FormatPgLSN. Can be compared with ==.
+	PgLSN = WrapTypeWithOid(String, oid.T_pg_lsn)
+)
+
+// Oids of the Postgres types which are not known to the lib/pq oid package.
+const (
+	oidJsonpath          oid.Oid = 4072
+	oidJsonpathArray     oid.Oid = 4073
+	oidRegCollation      oid.Oid = 4191
+	oidRegCollationArray oid.Oid = 4192
 )
 
+// extTypeNames holds the names of the types whose Oids are not known to the
+// lib/pq oid package, in the format of oid.TypeName.
+var extTypeNames = map[oid.Oid]string{
+	oidJsonpath:          "JSONPATH",
+	oidJsonpathArray:     "_JSONPATH",
+	oidRegCollation:      "REGCOLLATION",
+	oidRegCollationArray: "_REGCOLLATION",
+}
+
+// PGTypeName returns the Postgres name of the type with Oid o, in the upper
+// case format of oid.TypeName. Unlike oid.TypeName, it knows about all of the
+// Oids in OidToType.
+func PGTypeName(o oid.Oid) string {
+	if n, ok := extTypeNames[o]; ok {
+		return n
+	}
+	return oid.TypeName[o]
+}
+
 var (
 	// Unexported wrapper types. These exist for Postgres type compatibility.
 	typeInt2    = WrapTypeWithOid(Int, oid.T_int2)
@@ -112,6 +170,21 @@ var OidToType = map[oid.Oid]T{
 	oid.T_bit:          typeBit,
 	oid.T__bit:         TArray{typeBit},
 	oid.T_jsonb:        JSON,
+	oid.T_money:        Money,
+	oid.T__money:       TArray{Money},
+	oid.T_xid:          Xid,
+	oid.T__xid:         TArray{Xid},
+	oid.T_cid:          Cid,
+	oid.T__cid:         TArray{Cid},
+	oid.T_tid:          Tid,
+	oid.T__tid:         TArray{Tid},
+	oid.T_cstring:      CString,
+	oid.T__cstring:     TArray{CString},
+	oid.T_internal:     Internal,
+	oidJsonpath:        Jsonpath,
+	oidJsonpathArray:   TArray{Jsonpath},
+	oid.T_pg_lsn:       PgLSN,
+	oid.T__pg_lsn:      TArray{PgLSN},
 	oid.T_int2vector:   IntVector,
 	oid.T_oidvector:    OidVector,
 	oid.T_regclass:     RegClass,
@@ -121,6 +194,9 @@ var OidToType = map[oid.Oid]T{
 	oid.T_regtype:      RegType,
 	// TODO(jordan): I think this entry for T_record is out of place.
 	oid.T_record: FamTuple,
+
+	oidRegCollation:      RegCollation,
+	oidRegCollationArray: TArray{RegCollation},
 }
 
 // oidToArrayOid maps scalar type Oids to their corresponding array type Oid.
@@ -131,6 +207,8 @@ var oidToArrayOid = map[oid.Oid]oid.Oid{
 	oid.T_bpchar:      oid.T__bpchar,
 	oid.T_bytea:       oid.T__bytea,
 	oid.T_char:        oid.T__char,
+	oid.T_cid:         oid.T__cid,
+	oid.T_cstring:     oid.T__cstring,
 	oid.T_date:        oid.T__date,
 	oid.T_float4:      oid.T__float4,
 	oid.T_float8:      oid.T__float8,
@@ -139,16 +217,271 @@ var oidToArrayOid = map[oid.Oid]oid.Oid{
 	oid.T_int4:        oid.T__int4,
 	oid.T_int8:        oid.T__int8,
 	oid.T_interval:    oid.T__interval,
+	oid.T_money:       oid.T__money,
 	oid.T_name:        oid.T__name,
 	oid.T_numeric:     oid.T__numeric,
 	oid.T_oid:         oid.T__oid,
 	oid.T_text:        oid.T__text,
 	oid.T_time:        oid.T__time,
 	oid.T_timestamp:   oid.T__timestamp,
+	oid.T_tid:         oid.T__tid,
 	oid.T_timestamptz: oid.T__timestamptz,
 	oid.T_varbit:      oid.T__varbit,
 	oid.T_varchar:     oid.T__varchar,
 	oid.T_uuid:        oid.T__uuid,
+	oid.T_xid:         oid.T__xid,
+	oidJsonpath:       oidJsonpathArray,
+	oid.T_pg_lsn:      oid.T__pg_lsn,
+	oidRegCollation:   oidRegCollationArray,
+}
+
+// preferredOids is the set of the type Oids which are preferred within their
+// type category, as per pg_type.typispreferred in Postgres.
+var preferredOids = map[oid.Oid]struct{}{
+	oid.T_bool:        {},
+	oid.T_float8:      {},
+	oid.T_inet:        {},
+	oid.T_interval:    {},
+	oid.T_oid:         {},
+	oid.T_text:        {},
+	oid.T_timestamptz: {},
+	oid.T_varbit:      {},
+}
+
+// IsPreferred returns whether t is the preferred type of its category, which
+// Postgres favors when resolving ambiguous overloads and implicit casts. For
+// example, float8 is preferred among the numeric types and text among the
+// string types.
+func IsPreferred(t T) bool {
+	_, ok := preferredOids[t.Oid()]
+	return ok
+}
+
+// binaryFormatOids is the set of scalar type Oids whose values can be both
+// sent and received using the pgwire binary format. Types with a text-only
+// representation (e.g. the reg* OID variants) are deliberately absent.
+var binaryFormatOids = map[oid.Oid]struct{}{
+	oid.T_bit:         {},
+	oid.T_bool:        {},
+	oid.T_bpchar:      {},
+	oid.T_bytea:       {},
+	oid.T_date:        {},
+	oid.T_float4:      {},
+	oid.T_float8:      {},
+	oid.T_inet:        {},
+	oid.T_int2:        {},
+	oid.T_int4:        {},
+	oid.T_int8:        {},
+	oid.T_interval:    {},
+	oid.T_jsonb:       {},
+	oid.T_name:        {},
+	oid.T_numeric:     {},
+	oid.T_oid:         {},
+	oid.T_text:        {},
+	oid.T_time:        {},
+	oid.T_timestamp:   {},
+	oid.T_timestamptz: {},
+	oid.T_uuid:        {},
+	oid.T_varbit:      {},
+	oid.T_varchar:     {},
+}
+
+// SupportsBinaryFormat returns whether values of the provided type can be
+// both sent and received using the pgwire binary format. Arrays support the
+// binary format if their element type does.
+func SupportsBinaryFormat(t T) bool {
+	switch a := t.(type) {
+	case TArray:
+		return SupportsBinaryFormat(a.Typ)
+	case TMultiDimArray:
+		return SupportsBinaryFormat(a.Typ)
+	}
+	_, ok := binaryFormatOids[t.Oid()]
+	return ok
+}
+
+// FormatTid returns the text representation of the tuple ID of the row at
+// the given offset in the given block, as used for values of type Tid.
+func FormatTid(block uint32, offset uint16) string {
+	return fmt.Sprintf("(%d,%d)", block, offset)
+}
+
+// FormatPgLSN returns the text representation of the write-ahead log location
+// lsn, as used for values of type PgLSN: the high and low 32 bits of the
+// location in hexadecimal, separated by a slash, e.g. "16/B374D848".
+func FormatPgLSN(lsn uint64) string {
+	return fmt.Sprintf("%X/%X", uint32(lsn>>32), uint32(lsn))
+}
+
+// OidForType returns the Postgres oid reported for values of type t. It is
+// the inverse of OidToType: OidToType[OidForType(t)] is t for all of the types
+// registered there. The oid of the remaining types is that of the registered
+// type they are reported as, e.g. text for collated strings and interval for
+// restricted intervals. Arrays of unsupported element types and placeholders,
+// whose type is not yet known, have the invalid oid 0.
+//
+// For all types but placeholders this is t.Oid(); the wrapper types carry
+// their own oid, so it is always authoritative.
+func OidForType(t T) oid.Oid {
+	if _, ok := t.(TPlaceholder); ok {
+		return 0
+	}
+	return t.Oid()
+}
+
+// ArrayOidForElement returns the oid of the array type whose elements are of
+// the type with oid o, as reported by pg_type.typarray. The boolean is false
+// if there is no such array type. In particular, record has no array type
+// since arrays of records are not supported.
+func ArrayOidForElement(o oid.Oid) (oid.Oid, bool) {
+	a, ok := oidToArrayOid[o]
+	return a, ok
+}
+
+// ElementOidForArray returns the oid of the type of the elements of the array
+// type with oid o, as reported by pg_type.typelem. The element type of
+// anyarray is anyelement, and that of the vector types is the type of their
+// elements in Postgres, i.e. int2 and oid. The boolean is false for types
+// which are not arrays, including record.
+func ElementOidForArray(o oid.Oid) (oid.Oid, bool) {
+	typ, ok := OidToType[o]
+	if !ok {
+		return 0, false
+	}
+	return ArrayElementOid(typ)
+}
+
+// ArrayElementOid returns the oid of the type of the elements of the array
+// type t, as reported by pg_type.typelem for t. Unlike calling Oid on the
+// result of ElementType, it reports the Postgres element types of the vector
+// types, i.e. int2 and oid. The boolean is false if t is not an array type.
+func ArrayElementOid(t T) (oid.Oid, bool) {
+	switch t.Oid() {
+	case oid.T_int2vector:
+		// IntVector aliases an int array, but its elements are int2s.
+		return oid.T_int2, true
+	case oid.T_oidvector:
+		return oid.T_oid, true
+	}
+	elem, ok := ElementType(t)
+	if !ok {
+		return 0, false
+	}
+	return elem.Oid(), true
+}
+
+// TextFormatKind identifies a rule for rendering values of a type in the
+// pgwire text format.
+type TextFormatKind int
+
+const (
+	// TextFormatDefault renders the value using its datum's default format.
+	TextFormatDefault TextFormatKind = iota
+	// TextFormatBool renders booleans as "t" or "f".
+	TextFormatBool
+	// TextFormatBytes renders byte arrays as hex with a `\x` prefix.
+	TextFormatBytes
+	// TextFormatBlankPadded renders strings blank-padded to the type's width,
+	// as for bpchar.
+	TextFormatBlankPadded
+	// TextFormatUnpadded renders strings as-is, without any padding, as for
+	// name, varchar and "char".
+	TextFormatUnpadded
+	// TextFormatVector renders arrays as space-separated values without
+	// enclosing braces, as for int2vector and oidvector.
+	TextFormatVector
+)
+
+// AttTypmod returns the Postgres type modifier of t, as reported in
+// pg_attribute.atttypmod and in the RowDescription pgwire message. It encodes
+// the declared width of character types and the field restrictions of
+// intervals, and is -1 for the types which are unconstrained. Decimal and
+// timestamp types don't carry a precision, see DecimalTypmod for the former.
+func AttTypmod(t T) int32 {
+	if width, ok := CharWidth(t); ok {
+		return CharTypmod(width)
+	}
+	if fields, ok := IntervalFieldsFromType(t); ok {
+		return IntervalTypmod(fields)
+	}
+	return -1
+}
+
+// DecimalTypmod returns the Postgres type modifier of a NUMERIC(precision,
+// scale). A precision of zero designates an unconstrained decimal, whose type
+// modifier is -1.
+func DecimalTypmod(precision, scale int32) int32 {
+	if precision <= 0 {
+		return -1
+	}
+	return (precision<<16 | scale) + typmodHeader
+}
+
+// TypeFromOidAndTypmod returns the type with the provided OID, constrained by
+// the provided Postgres type modifier, as received with the parameter types of
+// a prepared statement. It is the inverse of OidForType and AttTypmod: the type
+// modifier declares the width of the character types and the fields of
+// intervals, and applies to the elements of arrays of those. The precision and
+// scale of decimals are not part of their type, so they are ignored, as is the
+// type modifier of the other types. A type modifier of -1 declares no
+// constraints.
+func TypeFromOidAndTypmod(o oid.Oid, typmod int32) (T, error) {
+	t, ok := OidToType[o]
+	if !ok {
+		return nil, pgerror.NewErrorf(pgerror.CodeUndefinedObjectError, "unknown type OID %d", o)
+	}
+	return applyTypmod(t, typmod)
+}
+
+func applyTypmod(t T, typmod int32) (T, error) {
+	if typmod < 0 {
+		return t, nil
+	}
+	if a, ok := t.(TArray); ok {
+		elem, err := applyTypmod(a.Typ, typmod)
+		if err != nil {
+			return nil, err
+		}
+		return TArray{Typ: elem}, nil
+	}
+	switch t.Oid() {
+	case oid.T_varchar, oid.T_bpchar:
+		width, ok := CharWidthFromTypmod(typmod)
+		if !ok {
+			return nil, pgerror.NewErrorf(pgerror.CodeInvalidParameterValueError,
+				"invalid type modifier %d for type %s", typmod, StandardSQLName(t))
+		}
+		if t.Oid() == oid.T_varchar {
+			return MakeVarChar(width), nil
+		}
+		return MakeChar(width), nil
+	case oid.T_interval:
+		fields := IntervalFieldsFromTypmod(typmod)
+		if _, ok := intervalFieldNames[fields]; !ok && fields != IntervalFieldsAll {
+			return nil, pgerror.NewErrorf(pgerror.CodeInvalidParameterValueError,
+				"invalid type modifier %d for type %s", typmod, StandardSQLName(t))
+		}
+		return MakeRestrictedInterval(fields), nil
+	}
+	return t, nil
+}
+
+// TextFormatHint returns the rule that the pgwire text encoder should use
+// to render values of type t.
+func TextFormatHint(t T) TextFormatKind {
+	switch t.Oid() {
+	case oid.T_bool:
+		return TextFormatBool
+	case oid.T_bytea:
+		return TextFormatBytes
+	case oid.T_bpchar:
+		return TextFormatBlankPadded
+	case oid.T_text, oid.T_varchar, oid.T_char, oid.T_name:
+		return TextFormatUnpadded
+	case oid.T_int2vector, oid.T_oidvector:
+		return TextFormatVector
+	}
+	return TextFormatDefault
 }
 
 // TOid represents an alias to the Int type with a different Postgres OID.
@@ -182,6 +515,8 @@ func (t TOid) SQLName() string {
 		return "regprocedure"
 	case oid.T_regtype:
 		return "regtype"
+	case oidRegCollation:
+		return "regcollation"
 	default:
 		panic(pgerror.NewAssertionErrorf("unexpected oidType: %v", log.Safe(t.oidType)))
 	}
@@ -200,7 +535,103 @@ type TOidWrapper struct {
 }
 
 var customOidNames = map[oid.Oid]string{
-	oid.T_name: "name",
+	oid.T_cid:     "cid",
+	oid.T_cstring: "cstring",
+	oidJsonpath:   "jsonpath",
+	oid.T_money:   "money",
+	oid.T_name:    "name",
+	oid.T_pg_lsn:  "pg_lsn",
+	oid.T_tid:     "tid",
+	oid.T_xid:     "xid",
+}
+
+// customOidSQLNames holds the SQL standard names of wrapped types whose name
+// differs from that of the type they wrap.
+var customOidSQLNames = map[oid.Oid]string{
+	oid.T_cid:     "cid",
+	oid.T_cstring: "cstring",
+	oidJsonpath:   "jsonpath",
+	oid.T_money:   "money",
+	oid.T_pg_lsn:  "pg_lsn",
+	oid.T_tid:     "tid",
+	oid.T_xid:     "xid",
+}
+
+// displayNames holds the names used by DisplayName for wrapped types whose
+// SQLName is that of the type they wrap. They match Postgres' format_type.
+var displayNames = map[oid.Oid]string{
+	oid.T_bpchar:     "bpchar",
+	oid.T_char:       `"char"`,
+	oid.T_float4:     "real",
+	oid.T_int2:       "smallint",
+	oid.T_int4:       "integer",
+	oid.T_int2vector: "int2vector",
+	oid.T_name:       "name",
+	oid.T_oidvector:  "oidvector",
+	oid.T_varchar:    "character varying",
+}
+
+// DisplayName returns the name of t to be used in user-facing messages, such
+// as type errors. It is the SQL name of the type, except that the aliases of a
+// type are named as in Postgres rather than after the type they alias, arrays
+// are named after their element type as "type[]", and tuples as "record".
+func DisplayName(t T) string {
+	switch c := t.(type) {
+	case TArray:
+		return DisplayName(c.Typ) + "[]"
+	case TMultiDimArray:
+		return DisplayName(c.TArray) + strings.Repeat("[]", c.Dims-1)
+	case TTuple:
+		return "record"
+	case TSizedString:
+		return fmt.Sprintf("%s(%d)", DisplayName(c.T), c.Width)
+	}
+	if s, ok := displayNames[t.Oid()]; ok {
+		return s
+	}
+	return t.SQLName()
+}
+
+// standardNames holds the SQL standard names of the types whose SQL name is
+// not the standard one.
+var standardNames = map[oid.Oid]string{
+	oid.T_bit:     "bit",
+	oid.T_bpchar:  "character",
+	oid.T_char:    `"char"`,
+	oid.T_float4:  "real",
+	oid.T_int2:    "smallint",
+	oid.T_int4:    "integer",
+	oid.T_jsonb:   "jsonb",
+	oid.T_name:    "name",
+	oid.T_time:    "time without time zone",
+	oid.T_varchar: "character varying",
+}
+
+// StandardSQLName returns the SQL standard name of t, as reported by the
+// data_type column of information_schema.columns, e.g. "character varying"
+// for varchar, "double precision" for float8 and "bigint" for int8. Unlike
+// DisplayName, it doesn't include the width of the character types, since it
+// is reported separately, and arrays are named "ARRAY". Domains are named
+// after their base type and enums are "USER-DEFINED", as in Postgres.
+func StandardSQLName(t T) string {
+	switch c := t.(type) {
+	case TSizedString:
+		return StandardSQLName(c.T)
+	case TDomain:
+		return StandardSQLName(c.T)
+	case TEnum:
+		return "USER-DEFINED"
+	}
+	switch UnwrapType(t).(type) {
+	case TArray:
+		return "ARRAY"
+	case TTuple:
+		return "record"
+	}
+	if s, ok := standardNames[t.Oid()]; ok {
+		return s
+	}
+	return t.SQLName()
 }
 
 func (t TOidWrapper) String() string {
@@ -214,10 +645,18 @@ func (t TOidWrapper) String() string {
 // Oid implements the T interface.
 func (t TOidWrapper) Oid() oid.Oid { return t.oid }
 
+// SQLName implements the T interface.
+func (t TOidWrapper) SQLName() string {
+	if s, ok := customOidSQLNames[t.oid]; ok {
+		return s
+	}
+	return t.T.SQLName()
+}
+
 // WrapTypeWithOid wraps a T with a custom Oid.
 func WrapTypeWithOid(t T, oid oid.Oid) T {
 	switch v := t.(type) {
-	case tUnknown, tAny, TOidWrapper:
+	case tUnknown, tAny, tInternal, TOidWrapper:
 		panic(pgerror.NewAssertionErrorf("cannot wrap %T with an Oid", v))
 	}
 	return TOidWrapper{
@@ -227,11 +666,75 @@ func WrapTypeWithOid(t T, oid oid.Oid) T {
 }
 
 // UnwrapType returns the base T type for a provided type, stripping
-// a *TOidWrapper if present. This is useful for cases like type switches,
-// where type aliases should be ignored.
+// a *TOidWrapper, interval field restrictions, a character width, a domain or
+// the dimensions of an array if present. This is useful for cases like type
+// switches, where type aliases should be ignored.
 func UnwrapType(t T) T {
-	if w, ok := t.(TOidWrapper); ok {
+	switch w := t.(type) {
+	case TOidWrapper:
 		return w.T
+	case TRestrictedInterval:
+		return Interval
+	case TSizedString:
+		return UnwrapType(w.T)
+	case TDomain:
+		return UnwrapType(w.T)
+	case TMultiDimArray:
+		return w.TArray
+	}
+	return t
+}
+
+// UnwrapAll is like UnwrapType, but it strips any number of wrappers and
+// also unwraps the element types of arrays and the field types of tuples, so
+// that e.g. name[] and int2vector become string[] and int[].
+func UnwrapAll(t T) T {
+	switch c := t.(type) {
+	case TOidWrapper, TRestrictedInterval, TSizedString, TDomain, TMultiDimArray:
+		return UnwrapAll(UnwrapType(c))
+	case TArray:
+		return TArray{Typ: UnwrapAll(c.Typ)}
+	case TTuple:
+		if c.Types == nil {
+			return c
+		}
+		res := TTuple{Types: make([]T, len(c.Types)), Labels: c.Labels}
+		for i := range c.Types {
+			res.Types[i] = UnwrapAll(c.Types[i])
+		}
+		return res
 	}
 	return t
 }
+
+// registeredTypes holds the user-defined types registered with
+// RegisterTypeOid, keyed by OID.
+var registeredTypes struct {
+	syncutil.RWMutex
+	m map[oid.Oid]T
+}
+
+// RegisterTypeOid makes the provided user-defined type, such as an enum or a
+// domain, available to LookupTypeByOid under the OID allocated to it.
+// Registering a type again with the same OID replaces it. The types in
+// OidToType take precedence over registered types.
+func RegisterTypeOid(o oid.Oid, t T) {
+	registeredTypes.Lock()
+	defer registeredTypes.Unlock()
+	if registeredTypes.m == nil {
+		registeredTypes.m = make(map[oid.Oid]T)
+	}
+	registeredTypes.m[o] = t
+}
+
+// LookupTypeByOid returns the type with the provided OID, which is either one
+// of the types in OidToType or a type registered with RegisterTypeOid.
+func LookupTypeByOid(o oid.Oid) (T, bool) {
+	if t, ok := OidToType[o]; ok {
+		return t, true
+	}
+	registeredTypes.RLock()
+	defer registeredTypes.RUnlock()
+	t, ok := registeredTypes.m[o]
+	return t, ok
+}
@@ -0,0 +1,543 @@
+// Copyright 2018 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package types
+
+import (
+	"testing"
+
+	"github.com/cockroachdb/cockroach/pkg/testutils"
+	"github.com/lib/pq/oid"
+)
+
+func TestMoneyOidRoundTrip(t *testing.T) {
+	typ, ok := OidToType[oid.T_money]
+	if !ok {
+		t.Fatal("money is not registered in OidToType")
+	}
+	if typ != Money {
+		t.Fatalf("expected %s, got %s", Money, typ)
+	}
+	if o := typ.Oid(); o != oid.T_money {
+		t.Fatalf("expected oid %d, got %d", oid.T_money, o)
+	}
+	if n := typ.SQLName(); n != "money" {
+		t.Fatalf("expected SQL name money, got %s", n)
+	}
+	if !typ.Equivalent(Decimal) {
+		t.Fatalf("expected %s to be equivalent to %s", typ, Decimal)
+	}
+
+	arr := TArray{Typ: Money}
+	if o := arr.Oid(); o != oid.T__money {
+		t.Fatalf("expected array oid %d, got %d", oid.T__money, o)
+	}
+	if _, ok := ArrayOids[oid.T__money]; !ok {
+		t.Fatal("money[] is not registered in ArrayOids")
+	}
+	if typ := OidToType[arr.Oid()]; typ != (TArray{Typ: Money}) {
+		t.Fatalf("expected %s, got %s", arr, typ)
+	}
+}
+
+func TestRegCollationOidRoundTrip(t *testing.T) {
+	typ, ok := OidToType[oidRegCollation]
+	if !ok {
+		t.Fatal("regcollation is not registered in OidToType")
+	}
+	if typ != RegCollation {
+		t.Fatalf("expected %s, got %s", RegCollation, typ)
+	}
+	if o := typ.Oid(); o != oidRegCollation {
+		t.Fatalf("expected oid %d, got %d", oidRegCollation, o)
+	}
+	if n := typ.SQLName(); n != "regcollation" {
+		t.Fatalf("expected SQL name regcollation, got %s", n)
+	}
+	if !typ.Equivalent(Oid) {
+		t.Fatalf("expected %s to be equivalent to %s", typ, Oid)
+	}
+
+	arr := TArray{Typ: RegCollation}
+	if o := arr.Oid(); o != oidRegCollationArray {
+		t.Fatalf("expected array oid %d, got %d", oidRegCollationArray, o)
+	}
+	if _, ok := ArrayOids[oidRegCollationArray]; !ok {
+		t.Fatal("regcollation[] is not registered in ArrayOids")
+	}
+	if typ := OidToType[arr.Oid()]; typ != (TArray{Typ: RegCollation}) {
+		t.Fatalf("expected %s, got %s", arr, typ)
+	}
+}
+
+func TestSystemColumnTypes(t *testing.T) {
+	testCases := []struct {
+		typ      T
+		oid      oid.Oid
+		arrayOid oid.Oid
+		name     string
+	}{
+		{Xid, oid.T_xid, oid.T__xid, "xid"},
+		{Cid, oid.T_cid, oid.T__cid, "cid"},
+		{Tid, oid.T_tid, oid.T__tid, "tid"},
+		{CString, oid.T_cstring, oid.T__cstring, "cstring"},
+		{Jsonpath, oidJsonpath, oidJsonpathArray, "jsonpath"},
+		{PgLSN, oid.T_pg_lsn, oid.T__pg_lsn, "pg_lsn"},
+	}
+	for _, tc := range testCases {
+		if typ := OidToType[tc.oid]; typ != tc.typ {
+			t.Errorf("%s: expected %s, got %s", tc.name, tc.typ, typ)
+		}
+		if n := tc.typ.SQLName(); n != tc.name {
+			t.Errorf("expected SQL name %s, got %s", tc.name, n)
+		}
+		if s := tc.typ.String(); s != tc.name {
+			t.Errorf("expected name %s, got %s", tc.name, s)
+		}
+		if base := UnwrapType(tc.typ); !tc.typ.Equivalent(base) {
+			t.Errorf("expected %s to be equivalent to %s", tc.typ, base)
+		}
+		arr := TArray{Typ: tc.typ}
+		if o := arr.Oid(); o != tc.arrayOid {
+			t.Errorf("%s: expected array oid %d, got %d", tc.name, tc.arrayOid, o)
+		}
+		if typ := OidToType[tc.arrayOid]; typ != arr {
+			t.Errorf("%s: expected %s, got %s", tc.name, arr, typ)
+		}
+	}
+}
+
+func TestPGTypeName(t *testing.T) {
+	testCases := []struct {
+		oid  oid.Oid
+		name string
+	}{
+		{oid.T_int8, "INT8"},
+		{oid.T__text, "_TEXT"},
+		{oidJsonpath, "JSONPATH"},
+		{oidJsonpathArray, "_JSONPATH"},
+		{oidRegCollation, "REGCOLLATION"},
+		{oidRegCollationArray, "_REGCOLLATION"},
+	}
+	for _, tc := range testCases {
+		if name := PGTypeName(tc.oid); name != tc.name {
+			t.Errorf("%d: expected name %s, got %s", tc.oid, tc.name, name)
+		}
+	}
+	for o := range OidToType {
+		if PGTypeName(o) == "" {
+			t.Errorf("no name for type %s with oid %d", OidToType[o], o)
+		}
+	}
+}
+
+func TestSupportsBinaryFormat(t *testing.T) {
+	testCases := []struct {
+		typ      T
+		expected bool
+	}{
+		{Int, true},
+		{Decimal, true},
+		{Bytes, true},
+		{Timestamp, true},
+		{TimestampTZ, true},
+		{String, true},
+		{Name, true},
+		{Oid, true},
+		{TArray{Typ: Int}, true},
+		{RegClass, false},
+		{RegType, false},
+		{TArray{Typ: RegClass}, false},
+		{typeQChar, false},
+		{TTuple{Types: []T{Int}}, false},
+	}
+	for _, tc := range testCases {
+		if res := SupportsBinaryFormat(tc.typ); res != tc.expected {
+			t.Errorf("%s: expected %t, got %t", tc.typ, tc.expected, res)
+		}
+	}
+}
+
+func TestTextFormatHint(t *testing.T) {
+	testCases := []struct {
+		typ      T
+		expected TextFormatKind
+	}{
+		{Bool, TextFormatBool},
+		{Bytes, TextFormatBytes},
+		{typeBpChar, TextFormatBlankPadded},
+		{String, TextFormatUnpadded},
+		{typeVarChar, TextFormatUnpadded},
+		{typeQChar, TextFormatUnpadded},
+		{Name, TextFormatUnpadded},
+		{IntVector, TextFormatVector},
+		{OidVector, TextFormatVector},
+		{TArray{Typ: Int}, TextFormatDefault},
+		{Int, TextFormatDefault},
+		{Decimal, TextFormatDefault},
+	}
+	for _, tc := range testCases {
+		if res := TextFormatHint(tc.typ); res != tc.expected {
+			t.Errorf("%s: expected %d, got %d", tc.typ, tc.expected, res)
+		}
+	}
+}
+
+func TestArrayElementOid(t *testing.T) {
+	testCases := []struct {
+		typ      T
+		expected oid.Oid
+	}{
+		{TArray{Typ: Int}, oid.T_int8},
+		{TArray{Typ: typeInt2}, oid.T_int2},
+		{TArray{Typ: Name}, oid.T_name},
+		{TArray{Typ: TArray{Typ: String}}, oid.T__text},
+		{IntVector, oid.T_int2},
+		{OidVector, oid.T_oid},
+		{Int, 0},
+		{TTuple{Types: []T{Int}}, 0},
+	}
+	for _, tc := range testCases {
+		elem, ok := ArrayElementOid(tc.typ)
+		if ok != (tc.expected != 0) {
+			t.Errorf("%s: expected ok=%t, got %t", tc.typ, tc.expected != 0, ok)
+		} else if elem != tc.expected {
+			t.Errorf("%s: expected %d, got %d", tc.typ, tc.expected, elem)
+		}
+	}
+}
+
+func TestUnwrapAll(t *testing.T) {
+	testCases := []struct {
+		typ      T
+		expected T
+	}{
+		{Int, Int},
+		{Name, String},
+		{typeInt4, Int},
+		{TOidWrapper{T: Name, oid: oid.T_varchar}, String},
+		{MakeRestrictedInterval(IntervalFieldDay), Interval},
+		{TArray{Typ: Name}, TArray{Typ: String}},
+		{TArray{Typ: TArray{Typ: typeInt2}}, TArray{Typ: TArray{Typ: Int}}},
+		{IntVector, TArray{Typ: Int}},
+		{OidVector, TArray{Typ: Oid}},
+		{
+			TTuple{Types: []T{Name, TArray{Typ: typeInt4}}, Labels: []string{"a", "b"}},
+			TTuple{Types: []T{String, TArray{Typ: Int}}, Labels: []string{"a", "b"}},
+		},
+		{FamTuple, FamTuple},
+		{RegClass, RegClass},
+	}
+	for _, tc := range testCases {
+		if res := UnwrapAll(tc.typ); !Identical(res, tc.expected) {
+			t.Errorf("%s: expected %s, got %s", tc.typ, tc.expected, res)
+		}
+	}
+	if typ := Name; UnwrapType(TArray{Typ: typ}) != (TArray{Typ: typ}) {
+		t.Error("UnwrapType must not descend into arrays")
+	}
+}
+
+func TestDisplayName(t *testing.T) {
+	testCases := []struct {
+		typ      T
+		expected string
+	}{
+		{Int, "bigint"},
+		{typeInt2, "smallint"},
+		{typeInt4, "integer"},
+		{typeFloat4, "real"},
+		{String, "text"},
+		{typeVarChar, "character varying"},
+		{typeBpChar, "bpchar"},
+		{typeQChar, `"char"`},
+		{Name, "name"},
+		{Money, "money"},
+		{TCollatedString{Locale: "en"}, "text"},
+		{TArray{Typ: typeInt4}, "integer[]"},
+		{TArray{Typ: Name}, "name[]"},
+		{TArray{Typ: TArray{Typ: String}}, "text[][]"},
+		{IntVector, "int2vector"},
+		{OidVector, "oidvector"},
+		{TTuple{Types: []T{Int}}, "record"},
+		{FamTuple, "record"},
+		{RegClass, "regclass"},
+	}
+	for _, tc := range testCases {
+		if name := DisplayName(tc.typ); name != tc.expected {
+			t.Errorf("%s: expected %s, got %s", tc.typ, tc.expected, name)
+		}
+	}
+}
+
+func TestStandardSQLName(t *testing.T) {
+	testCases := []struct {
+		typ      T
+		expected string
+	}{
+		{Bool, "boolean"},
+		{typeInt2, "smallint"},
+		{typeInt4, "integer"},
+		{Int, "bigint"},
+		{typeFloat4, "real"},
+		{Float, "double precision"},
+		{Decimal, "numeric"},
+		{String, "text"},
+		{typeVarChar, "character varying"},
+		{MakeVarChar(10), "character varying"},
+		{typeBpChar, "character"},
+		{MakeChar(3), "character"},
+		{typeQChar, `"char"`},
+		{Name, "name"},
+		{TCollatedString{Locale: "en"}, "text"},
+		{Time, "time without time zone"},
+		{Timestamp, "timestamp without time zone"},
+		{TimestampTZ, "timestamp with time zone"},
+		{typeBit, "bit"},
+		{BitArray, "bit varying"},
+		{JSON, "jsonb"},
+		{TArray{Typ: Int}, "ARRAY"},
+		{IntVector, "ARRAY"},
+		{TTuple{Types: []T{Int}}, "record"},
+		{MakeDomain(100090, MakeVarChar(5), false, ""), "character varying"},
+		{MakeEnum(100080, []string{"a"}), "USER-DEFINED"},
+		{Oid, "oid"},
+	}
+	for _, tc := range testCases {
+		if name := StandardSQLName(tc.typ); name != tc.expected {
+			t.Errorf("%s: expected %s, got %s", tc.typ, tc.expected, name)
+		}
+	}
+}
+
+func TestArrayElementOids(t *testing.T) {
+	testCases := []struct {
+		array, elem oid.Oid
+		// roundTrip is set if elem's array type is array.
+		roundTrip bool
+	}{
+		{oid.T__int8, oid.T_int8, true},
+		{oid.T__int2, oid.T_int2, true},
+		{oid.T__name, oid.T_name, true},
+		{oid.T__money, oid.T_money, true},
+		{oid.T_anyarray, oid.T_anyelement, true},
+		{oid.T_int2vector, oid.T_int2, false},
+		{oid.T_oidvector, oid.T_oid, false},
+	}
+	for _, tc := range testCases {
+		if elem, ok := ElementOidForArray(tc.array); !ok || elem != tc.elem {
+			t.Errorf("ElementOidForArray(%d): expected %d, got %d (%t)", tc.array, tc.elem, elem, ok)
+		}
+		if !tc.roundTrip {
+			continue
+		}
+		if array, ok := ArrayOidForElement(tc.elem); !ok || array != tc.array {
+			t.Errorf("ArrayOidForElement(%d): expected %d, got %d (%t)", tc.elem, tc.array, array, ok)
+		}
+	}
+
+	for _, o := range []oid.Oid{oid.T_record, oid.T_int8, oid.T_anyelement, oid.T_unknown} {
+		if elem, ok := ElementOidForArray(o); ok {
+			t.Errorf("ElementOidForArray(%d): expected no element type, got %d", o, elem)
+		}
+	}
+	for _, o := range []oid.Oid{oid.T_record, oid.T_anyarray, oid.T__int8} {
+		if array, ok := ArrayOidForElement(o); ok {
+			t.Errorf("ArrayOidForElement(%d): expected no array type, got %d", o, array)
+		}
+	}
+}
+
+func TestOidForType(t *testing.T) {
+	for o, typ := range OidToType {
+		if res := OidForType(typ); res != o {
+			t.Errorf("%s: expected oid %d, got %d", typ, o, res)
+		}
+	}
+
+	testCases := []struct {
+		typ      T
+		expected oid.Oid
+	}{
+		{TCollatedString{Locale: "en"}, oid.T_text},
+		{TArray{Typ: TCollatedString{Locale: "en"}}, oid.T__text},
+		{MakeRestrictedInterval(IntervalFieldDay), oid.T_interval},
+		{TTuple{Types: []T{Int}}, oid.T_record},
+		{TArray{Typ: TTuple{Types: []T{Int}}}, 0},
+		{TPlaceholder{Idx: 0}, 0},
+	}
+	for _, tc := range testCases {
+		if res := OidForType(tc.typ); res != tc.expected {
+			t.Errorf("%s: expected oid %d, got %d", tc.typ, tc.expected, res)
+		}
+	}
+}
+
+func TestTypeFromOidAndTypmod(t *testing.T) {
+	// Every type round-trips through its OID and type modifier.
+	for _, typ := range []T{
+		Int,
+		typeInt4,
+		String,
+		MakeVarChar(0),
+		MakeVarChar(10),
+		MakeChar(3),
+		Interval,
+		MakeRestrictedInterval(IntervalFieldDay | IntervalFieldHour),
+		Decimal,
+		Oid,
+		IntVector,
+	} {
+		res, err := TypeFromOidAndTypmod(OidForType(typ), AttTypmod(typ))
+		if err != nil {
+			t.Errorf("%s: %v", typ, err)
+			continue
+		}
+		if !Identical(res, typ) || AttTypmod(res) != AttTypmod(typ) {
+			t.Errorf("expected %s, got %s", typ, res)
+		}
+	}
+
+	testCases := []struct {
+		o        oid.Oid
+		typmod   int32
+		expected T
+		err      string
+	}{
+		{o: oid.T__varchar, typmod: 9, expected: TArray{Typ: MakeVarChar(5)}},
+		{
+			o:        oid.T_interval,
+			typmod:   IntervalTypmod(IntervalFieldYear),
+			expected: MakeRestrictedInterval(IntervalFieldYear),
+		},
+		{o: oid.T_numeric, typmod: DecimalTypmod(10, 2), expected: Decimal},
+		{o: oid.T_int8, typmod: 12, expected: Int},
+		{o: oid.T_varchar, typmod: 2, err: "invalid type modifier 2 for type character varying"},
+		{o: oid.T_interval, typmod: 1<<16 | 0xFFFF, err: "invalid type modifier .* for type interval"},
+		{o: 123456, typmod: -1, err: "unknown type OID 123456"},
+	}
+	for _, tc := range testCases {
+		res, err := TypeFromOidAndTypmod(tc.o, tc.typmod)
+		if tc.err != "" {
+			if !testutils.IsError(err, tc.err) {
+				t.Errorf("%d(%d): expected %q, got %v", tc.o, tc.typmod, tc.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d(%d): %v", tc.o, tc.typmod, err)
+		} else if !Identical(res, tc.expected) || AttTypmod(res) != AttTypmod(tc.expected) {
+			t.Errorf("%d(%d): expected %s, got %s", tc.o, tc.typmod, tc.expected, res)
+		}
+	}
+}
+
+func TestPgLSN(t *testing.T) {
+	if s := FormatPgLSN(0); s != "0/0" {
+		t.Errorf("expected 0/0, got %s", s)
+	}
+	if s := FormatPgLSN(0x16B374D848); s != "16/B374D848" {
+		t.Errorf("expected 16/B374D848, got %s", s)
+	}
+	if s := FormatPgLSN(1<<64 - 1); s != "FFFFFFFF/FFFFFFFF" {
+		t.Errorf("expected FFFFFFFF/FFFFFFFF, got %s", s)
+	}
+	if !IsScalar(PgLSN) || PgLSN.IsAmbiguous() {
+		t.Errorf("expected %s to be a non-ambiguous scalar type", PgLSN)
+	}
+	if SupportsBinaryFormat(PgLSN) {
+		t.Errorf("expected %s to only support the text format", PgLSN)
+	}
+}
+
+func TestFormatTid(t *testing.T) {
+	if s := FormatTid(0, 1); s != "(0,1)" {
+		t.Errorf("expected (0,1), got %s", s)
+	}
+	if s := FormatTid(4294967295, 65535); s != "(4294967295,65535)" {
+		t.Errorf("expected (4294967295,65535), got %s", s)
+	}
+	if SupportsBinaryFormat(Tid) {
+		t.Errorf("expected %s to only support the text format", Tid)
+	}
+}
+
+func TestAttTypmod(t *testing.T) {
+	// The expected values are those reported by Postgres in
+	// pg_attribute.atttypmod for columns of the given types.
+	testCases := []struct {
+		typ    T
+		typmod int32
+	}{
+		{MakeVarChar(10), 14},
+		{MakeVarChar(0), -1},
+		{MakeChar(1), 5},
+		{MakeRestrictedInterval(IntervalFieldYear), 327679},
+		{MakeRestrictedInterval(IntervalFieldDay | IntervalFieldHour | IntervalFieldMinute | IntervalFieldSecond), 470351871},
+		{Interval, -1},
+		{String, -1},
+		{Int, -1},
+		{Decimal, -1},
+	}
+	for _, tc := range testCases {
+		if typmod := AttTypmod(tc.typ); typmod != tc.typmod {
+			t.Errorf("%s: expected typmod %d, got %d", tc.typ, tc.typmod, typmod)
+		}
+	}
+
+	decimalCases := []struct {
+		precision, scale int32
+		typmod           int32
+	}{
+		{10, 2, 655366},
+		{5, 0, 327684},
+		{1000, 500, 65536504},
+		{0, 0, -1},
+	}
+	for _, tc := range decimalCases {
+		if typmod := DecimalTypmod(tc.precision, tc.scale); typmod != tc.typmod {
+			t.Errorf("numeric(%d,%d): expected typmod %d, got %d", tc.precision, tc.scale, tc.typmod, typmod)
+		}
+	}
+}
+
+func TestIsPreferred(t *testing.T) {
+	testCases := []struct {
+		typ      T
+		expected bool
+	}{
+		{Bool, true},
+		{Float, true},
+		{String, true},
+		{Oid, true},
+		{TimestampTZ, true},
+		{Interval, true},
+		{MakeRestrictedInterval(IntervalFieldDay), true},
+		{INet, true},
+		{BitArray, true},
+		{Int, false},
+		{typeInt4, false},
+		{typeFloat4, false},
+		{Decimal, false},
+		{Name, false},
+		{typeVarChar, false},
+		{Timestamp, false},
+		{TArray{Typ: String}, false},
+	}
+	for _, tc := range testCases {
+		if res := IsPreferred(tc.typ); res != tc.expected {
+			t.Errorf("%s: expected %t, got %t", tc.typ, tc.expected, res)
+		}
+	}
+}
@@ -18,7 +18,11 @@ import (
 	"bytes"
 	"fmt"
 	"math"
+	"reflect"
+	"sort"
+	"time"
 
+	"github.com/cockroachdb/apd"
 	"github.com/lib/pq/oid"
 )
 
@@ -85,6 +89,10 @@ var (
 	AnyArray T = TArray{Any}
 	// Any can be any type. Can be compared with ==.
 	Any T = tAny{}
+	// Internal is the type of the arguments and results of functions which
+	// cannot be called from SQL. It is equivalent to no type other than
+	// itself. Can be compared with ==.
+	Internal T = tInternal{}
 
 	// AnyNonArray contains all non-array types.
 	AnyNonArray = []T{
@@ -397,6 +405,29 @@ func (t TTuple) IsAmbiguous() bool {
 	return len(t.Types) == 0
 }
 
+// MakeTuple returns the record type whose fields have the given types and,
+// if labels is non-nil, the given labels. The slices are copied.
+func MakeTuple(contents []T, labels []string) T {
+	if labels != nil && len(labels) != len(contents) {
+		panic(fmt.Sprintf("tuple has %d types but %d labels", len(contents), len(labels)))
+	}
+	t := TTuple{Types: append([]T{}, contents...)}
+	if labels != nil {
+		t.Labels = append([]string{}, labels...)
+	}
+	return t
+}
+
+// MakeAnonymousRecord returns the record type whose fields have the given
+// types but no labels, such as the type of the rows produced by a
+// set-returning function whose columns are unnamed. Unlike FamTuple, whose
+// nil field list matches any tuple, the returned type fully specifies its
+// fields, so that each of them can be described individually, e.g. in the
+// RowDescription of the results. The slice is copied.
+func MakeAnonymousRecord(contents []T) T {
+	return TTuple{Types: append([]T{}, contents...)}
+}
+
 // PlaceholderIdx is the 0-based index of a placeholder. Placeholder "$1"
 // has PlaceholderIdx=0.
 type PlaceholderIdx uint16
@@ -479,10 +510,27 @@ const noArrayType = 0
 // ArrayOids is a set of all oids which correspond to an array type.
 var ArrayOids = map[oid.Oid]struct{}{}
 
+// sortedArrayOids contains the elements of ArrayOids in ascending order.
+var sortedArrayOids []oid.Oid
+
 func init() {
 	for _, v := range oidToArrayOid {
 		ArrayOids[v] = struct{}{}
 	}
+	sortedArrayOids = make([]oid.Oid, 0, len(ArrayOids))
+	for o := range ArrayOids {
+		sortedArrayOids = append(sortedArrayOids, o)
+	}
+	sort.Slice(sortedArrayOids, func(i, j int) bool {
+		return sortedArrayOids[i] < sortedArrayOids[j]
+	})
+}
+
+// SortedArrayOids returns the oids which correspond to an array type in
+// ascending order, for callers which need to iterate over ArrayOids
+// deterministically.
+func SortedArrayOids() []oid.Oid {
+	return append([]oid.Oid(nil), sortedArrayOids...)
 }
 
 // Oid implements the T interface.
@@ -503,6 +551,303 @@ func (a TArray) IsAmbiguous() bool {
 	return a.Typ == nil || a.Typ.IsAmbiguous()
 }
 
+// MakeArray returns the type of an array whose elements are of type elem. The
+// array reports the Oid registered for arrays of elem's Oid, so that e.g. an
+// array of Name is a name[] rather than a text[]. If no array type is
+// registered for the Oid of a wrapped element type, the array is made of the
+// wrapped type instead, which is how values of the element are represented.
+// Element types with no array type at all, such as tuples, yield an array
+// with the invalid Oid 0.
+func MakeArray(elem T) T {
+	if w, ok := elem.(TOidWrapper); ok {
+		if _, ok := oidToArrayOid[w.oid]; !ok {
+			if _, ok := oidToArrayOid[w.T.Oid()]; ok {
+				return TArray{Typ: w.T}
+			}
+		}
+	}
+	return TArray{Typ: elem}
+}
+
+// ElementType returns the type of the elements of a container type, i.e. of
+// a TArray or of one of the vector types aliasing it. For nested arrays, the
+// immediate element type is returned. The boolean is false for scalar types.
+func ElementType(t T) (T, bool) {
+	if a, ok := UnwrapType(t).(TArray); ok {
+		return a.Typ, true
+	}
+	return nil, false
+}
+
+// Identical returns whether the two types are exactly the same, including
+// their Oids, element types, tuple labels, character widths and interval
+// fields. Unlike Equivalent, it distinguishes e.g. int4 from int8 and
+// VARCHAR(5) from VARCHAR(10).
+func Identical(a, b T) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	switch ta := a.(type) {
+	case TOidWrapper:
+		tb, ok := b.(TOidWrapper)
+		return ok && ta.oid == tb.oid && Identical(ta.T, tb.T)
+	case TSizedString:
+		tb, ok := b.(TSizedString)
+		return ok && ta.Width == tb.Width && Identical(ta.T, tb.T)
+	case TArray:
+		tb, ok := b.(TArray)
+		return ok && Identical(ta.Typ, tb.Typ)
+	case TMultiDimArray:
+		tb, ok := b.(TMultiDimArray)
+		return ok && ta.Dims == tb.Dims && Identical(ta.Typ, tb.Typ)
+	case TTuple:
+		tb, ok := b.(TTuple)
+		if !ok || len(ta.Types) != len(tb.Types) || len(ta.Labels) != len(tb.Labels) {
+			return false
+		}
+		for i := range ta.Types {
+			if !Identical(ta.Types[i], tb.Types[i]) {
+				return false
+			}
+		}
+		for i := range ta.Labels {
+			if ta.Labels[i] != tb.Labels[i] {
+				return false
+			}
+		}
+		return true
+	case TDomain:
+		tb, ok := b.(TDomain)
+		return ok && ta.DomainOid == tb.DomainOid && ta.NotNull == tb.NotNull &&
+			ta.Check == tb.Check && Identical(ta.T, tb.T)
+	case TEnum:
+		tb, ok := b.(TEnum)
+		if !ok || ta.EnumOid != tb.EnumOid || len(ta.Labels) != len(tb.Labels) {
+			return false
+		}
+		for i := range ta.Labels {
+			if ta.Labels[i] != tb.Labels[i] {
+				return false
+			}
+		}
+		return true
+	default:
+		// The remaining types are comparable.
+		return a == b
+	}
+}
+
+// IsScalar returns false if the type is a container type, i.e. an array
+// (including the vector types aliasing arrays) or a tuple, and true
+// otherwise.
+func IsScalar(t T) bool {
+	switch UnwrapType(t).(type) {
+	case TArray, TTuple:
+		return false
+	default:
+		return true
+	}
+}
+
+// CommonType returns the least common supertype of a and b, i.e. the type to
+// which values of both types can be promoted, as needed to type the branches
+// of a CASE or the columns of a UNION. It returns false if no such type
+// exists.
+//
+// Identical types are returned as-is and Unknown (the type of NULL) promotes
+// to the other type. Otherwise the aliases of a type family collapse to the
+// family (e.g. name and varchar to String), integers are widened to the larger
+// width, and numeric and temporal types are promoted following Postgres:
+// int < decimal < float and date < timestamp < timestamptz.
+func CommonType(a, b T) (T, bool) {
+	if Identical(a, b) {
+		return a, true
+	}
+	if a == Unknown {
+		return b, true
+	}
+	if b == Unknown {
+		return a, true
+	}
+	if wa, wb := intWidth(a), intWidth(b); wa != 0 && wb != 0 {
+		if wa >= wb {
+			return a, true
+		}
+		return b, true
+	}
+	ua, ub := UnwrapType(a), UnwrapType(b)
+	switch ta := ua.(type) {
+	case TArray:
+		tb, ok := ub.(TArray)
+		if !ok {
+			return nil, false
+		}
+		elem, ok := CommonType(ta.Typ, tb.Typ)
+		if !ok {
+			return nil, false
+		}
+		return TArray{Typ: elem}, true
+	case TTuple:
+		tb, ok := ub.(TTuple)
+		if !ok || len(ta.Types) != len(tb.Types) {
+			return nil, false
+		}
+		res := TTuple{Types: make([]T, len(ta.Types))}
+		for i := range ta.Types {
+			if res.Types[i], ok = CommonType(ta.Types[i], tb.Types[i]); !ok {
+				return nil, false
+			}
+		}
+		if Identical(TTuple{Labels: ta.Labels}, TTuple{Labels: tb.Labels}) {
+			res.Labels = ta.Labels
+		}
+		return res, true
+	case TCollatedString:
+		if tb, ok := ub.(TCollatedString); ok && ta.Locale == tb.Locale {
+			return ta, true
+		}
+		return nil, false
+	case TOid:
+		if _, ok := ub.(TOid); ok {
+			return Oid, true
+		}
+		return nil, false
+	case TEnum:
+		// Values of distinct enum types cannot be mixed, and identical enums
+		// were handled above.
+		return nil, false
+	}
+	if ua == ub {
+		return ua, true
+	}
+	if ca, ra := promotionRank(ua); ca != 0 {
+		if cb, rb := promotionRank(ub); ca == cb {
+			if ra > rb {
+				return ua, true
+			}
+			return ub, true
+		}
+	}
+	return nil, false
+}
+
+// WidenNumeric returns the type of the result of a binary arithmetic operation
+// on values of the numeric types a and b. Following Postgres, the operands are
+// promoted to the wider of the two types in the order int2 < int4 < int8 <
+// decimal < float4 < float8, except that float4 is only preserved if both
+// operands are float4; mixing it with another type yields a float8. It returns
+// false if either operand is not numeric.
+func WidenNumeric(a, b T) (T, bool) {
+	ra, ok := numericRanks[a.Oid()]
+	if !ok {
+		return nil, false
+	}
+	rb, ok := numericRanks[b.Oid()]
+	if !ok {
+		return nil, false
+	}
+	res := a
+	if rb > ra {
+		res = b
+	}
+	if res.Oid() == oid.T_float4 && ra != rb {
+		return Float, true
+	}
+	return res, true
+}
+
+// intWidth returns the width in bits of an integer type, or 0 if t is not an
+// integer type. Aliases of Int with other semantics, such as xid, are not
+// considered integer types.
+func intWidth(t T) int {
+	switch t.Oid() {
+	case oid.T_int2:
+		return 16
+	case oid.T_int4:
+		return 32
+	case oid.T_int8:
+		return 64
+	}
+	return 0
+}
+
+const (
+	numericCategory = iota + 1
+	temporalCategory
+)
+
+// promotionRank returns the category of the numeric and temporal types along
+// with their rank within it; types are promoted to the type of higher rank.
+// It returns a zero category for the other types.
+func promotionRank(t T) (category, rank int) {
+	switch t {
+	case Int:
+		return numericCategory, 1
+	case Decimal:
+		return numericCategory, 2
+	case Float:
+		return numericCategory, 3
+	case Date:
+		return temporalCategory, 1
+	case Timestamp:
+		return temporalCategory, 2
+	case TimestampTZ:
+		return temporalCategory, 3
+	}
+	return 0, 0
+}
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	decimalType = reflect.TypeOf(apd.Decimal{})
+	bytesType   = reflect.TypeOf([]byte(nil))
+)
+
+// TypeForGoValue returns the type of the datums which would be used to
+// represent the provided Go value: Int for signed integers, Float for
+// floating point numbers, String for strings, Bytes for []byte, Bool for
+// booleans, Timestamp for time.Time and Decimal for apd.Decimal. Slices of
+// these map to the corresponding TArray. The boolean is false if the value's
+// type is not supported.
+func TypeForGoValue(v interface{}) (T, bool) {
+	if v == nil {
+		return nil, false
+	}
+	return typeForGoType(reflect.TypeOf(v))
+}
+
+func typeForGoType(t reflect.Type) (T, bool) {
+	switch t {
+	case timeType:
+		return Timestamp, true
+	case decimalType:
+		return Decimal, true
+	case bytesType:
+		return Bytes, true
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return Bool, true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return Int, true
+	case reflect.Float32, reflect.Float64:
+		return Float, true
+	case reflect.String:
+		return String, true
+	case reflect.Slice:
+		elem, ok := typeForGoType(t.Elem())
+		if !ok {
+			return nil, false
+		}
+		if valid, _ := IsValidArrayElementType(elem); !valid || !IsScalar(elem) {
+			return nil, false
+		}
+		return TArray{Typ: elem}, true
+	default:
+		return nil, false
+	}
+}
+
 type tAny struct{}
 
 func (tAny) String() string           { return "anyelement" }
@@ -512,6 +857,15 @@ func (tAny) Oid() oid.Oid             { return oid.T_anyelement }
 func (tAny) SQLName() string          { return "anyelement" }
 func (tAny) IsAmbiguous() bool        { return true }
 
+type tInternal struct{}
+
+func (tInternal) String() string           { return "internal" }
+func (tInternal) Equivalent(other T) bool  { return other == Internal }
+func (tInternal) FamilyEqual(other T) bool { return other == Internal }
+func (tInternal) Oid() oid.Oid             { return oid.T_internal }
+func (tInternal) SQLName() string          { return "internal" }
+func (tInternal) IsAmbiguous() bool        { return false }
+
 // IsStringType returns true iff t is String
 // or a collated string type.
 func IsStringType(t T) bool {
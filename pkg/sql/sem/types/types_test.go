@@ -0,0 +1,357 @@
+// Copyright 2018 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package types
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/apd"
+	"github.com/lib/pq/oid"
+)
+
+func TestInternal(t *testing.T) {
+	if typ := OidToType[oid.T_internal]; typ != Internal {
+		t.Errorf("expected %s, got %s", Internal, typ)
+	}
+	if !Internal.Equivalent(Internal) {
+		t.Errorf("expected %s to be equivalent to itself", Internal)
+	}
+	for _, typ := range []T{String, CString, Int, Unknown} {
+		if Internal.Equivalent(typ) || typ.Equivalent(Internal) {
+			t.Errorf("expected %s not to be equivalent to %s", Internal, typ)
+		}
+	}
+	if Internal.IsAmbiguous() {
+		t.Errorf("expected %s not to be ambiguous", Internal)
+	}
+}
+
+func TestElementType(t *testing.T) {
+	testCases := []struct {
+		typ      T
+		expected T
+	}{
+		{TArray{Typ: Int}, Int},
+		{TArray{Typ: TArray{Typ: String}}, TArray{Typ: String}},
+		{IntVector, Int},
+		{OidVector, Oid},
+		{TArray{Typ: Money}, Money},
+		{Int, nil},
+		{Name, nil},
+		{TTuple{Types: []T{Int}}, nil},
+	}
+	for _, tc := range testCases {
+		elem, ok := ElementType(tc.typ)
+		if ok != (tc.expected != nil) {
+			t.Errorf("%s: expected ok=%t, got %t", tc.typ, tc.expected != nil, ok)
+		} else if ok && !reflect.DeepEqual(elem, tc.expected) {
+			t.Errorf("%s: expected %s, got %s", tc.typ, tc.expected, elem)
+		}
+	}
+}
+
+func TestIsScalar(t *testing.T) {
+	testCases := []struct {
+		typ      T
+		expected bool
+	}{
+		{Int, true},
+		{String, true},
+		{Name, true},
+		{Money, true},
+		{Oid, true},
+		{RegClass, true},
+		{TCollatedString{Locale: "en"}, true},
+		{TArray{Typ: Int}, false},
+		{AnyArray, false},
+		{IntVector, false},
+		{OidVector, false},
+		{TTuple{Types: []T{Int}}, false},
+		{FamTuple, false},
+	}
+	for _, tc := range testCases {
+		if res := IsScalar(tc.typ); res != tc.expected {
+			t.Errorf("%s: expected %t, got %t", tc.typ, tc.expected, res)
+		}
+	}
+}
+
+func TestIdentical(t *testing.T) {
+	testCases := []struct {
+		a, b     T
+		expected bool
+	}{
+		{Int, Int, true},
+		{Int, typeInt4, false},
+		{typeInt4, typeInt4, true},
+		{typeInt2, typeInt4, false},
+		{String, Name, false},
+		{MakeVarChar(5), MakeVarChar(5), true},
+		{MakeVarChar(5), MakeVarChar(10), false},
+		{MakeVarChar(5), MakeChar(5), false},
+		{MakeVarChar(5), typeVarChar, false},
+		{MakeRestrictedInterval(IntervalFieldDay), MakeRestrictedInterval(IntervalFieldDay), true},
+		{MakeRestrictedInterval(IntervalFieldDay), MakeRestrictedInterval(IntervalFieldYear), false},
+		{MakeRestrictedInterval(IntervalFieldDay), Interval, false},
+		{TArray{Typ: Int}, TArray{Typ: Int}, true},
+		{TArray{Typ: Int}, TArray{Typ: typeInt4}, false},
+		{TArray{Typ: TArray{Typ: Int}}, TArray{Typ: TArray{Typ: Int}}, true},
+		{TArray{Typ: Int}, IntVector, false},
+		{IntVector, IntVector, true},
+		{FamArray, FamArray, true},
+		{TCollatedString{Locale: "en"}, TCollatedString{Locale: "en"}, true},
+		{TCollatedString{Locale: "en"}, TCollatedString{Locale: "de"}, false},
+		{TTuple{Types: []T{Int, String}}, TTuple{Types: []T{Int, String}}, true},
+		{TTuple{Types: []T{Int, String}}, TTuple{Types: []T{Int, Name}}, false},
+		{
+			TTuple{Types: []T{Int}, Labels: []string{"a"}},
+			TTuple{Types: []T{Int}, Labels: []string{"b"}},
+			false,
+		},
+		{TTuple{Types: []T{Int}}, Int, false},
+		{Int, TTuple{Types: []T{Int}}, false},
+	}
+	for _, tc := range testCases {
+		if res := Identical(tc.a, tc.b); res != tc.expected {
+			t.Errorf("Identical(%s, %s): expected %t, got %t", tc.a, tc.b, tc.expected, res)
+		}
+	}
+}
+
+func TestTypeForGoValue(t *testing.T) {
+	type myString string
+	testCases := []struct {
+		val      interface{}
+		expected T
+	}{
+		{1, Int},
+		{int64(1), Int},
+		{int32(1), Int},
+		{1.5, Float},
+		{"a", String},
+		{myString("a"), String},
+		{[]byte("a"), Bytes},
+		{true, Bool},
+		{time.Time{}, Timestamp},
+		{apd.Decimal{}, Decimal},
+		{[]int64{1}, TArray{Typ: Int}},
+		{[]string{"a"}, TArray{Typ: String}},
+		{[][]byte{[]byte("a")}, TArray{Typ: Bytes}},
+		{[]time.Time{}, TArray{Typ: Timestamp}},
+		{nil, nil},
+		{uint64(1), nil},
+		{struct{}{}, nil},
+		{[][]int{{1}}, nil},
+		{map[string]int{}, nil},
+	}
+	for _, tc := range testCases {
+		typ, ok := TypeForGoValue(tc.val)
+		if ok != (tc.expected != nil) {
+			t.Errorf("%T: expected ok=%t, got %t", tc.val, tc.expected != nil, ok)
+		} else if ok && !Identical(typ, tc.expected) {
+			t.Errorf("%T: expected %s, got %s", tc.val, tc.expected, typ)
+		}
+	}
+}
+
+func TestCommonType(t *testing.T) {
+	testCases := []struct {
+		a, b     T
+		expected T
+	}{
+		{Int, Int, Int},
+		{typeInt4, typeInt4, typeInt4},
+		{typeInt2, typeInt4, typeInt4},
+		{typeInt4, Int, Int},
+		{Unknown, typeInt2, typeInt2},
+		{Decimal, Unknown, Decimal},
+		{Int, Float, Float},
+		{typeInt4, Decimal, Decimal},
+		{Decimal, Float, Float},
+		{Name, typeVarChar, String},
+		{String, typeBpChar, String},
+		{Date, Timestamp, Timestamp},
+		{TimestampTZ, Date, TimestampTZ},
+		{Oid, RegClass, Oid},
+		{Money, Decimal, Decimal},
+		{TArray{Typ: typeInt2}, TArray{Typ: Float}, TArray{Typ: Float}},
+		{
+			TTuple{Types: []T{Int, Name}, Labels: []string{"a", "b"}},
+			TTuple{Types: []T{Float, String}, Labels: []string{"a", "b"}},
+			TTuple{Types: []T{Float, String}, Labels: []string{"a", "b"}},
+		},
+		{
+			TTuple{Types: []T{Int}, Labels: []string{"a"}},
+			TTuple{Types: []T{Int}, Labels: []string{"b"}},
+			TTuple{Types: []T{Int}},
+		},
+		{TCollatedString{Locale: "en"}, TCollatedString{Locale: "en"}, TCollatedString{Locale: "en"}},
+		{TCollatedString{Locale: "en"}, TCollatedString{Locale: "de"}, nil},
+		{Int, String, nil},
+		{Xid, typeInt4, Int},
+		{Date, Int, nil},
+		{TArray{Typ: Int}, Int, nil},
+		{TTuple{Types: []T{Int}}, TTuple{Types: []T{Int, Int}}, nil},
+	}
+	for _, tc := range testCases {
+		typ, ok := CommonType(tc.a, tc.b)
+		if ok != (tc.expected != nil) {
+			t.Errorf("CommonType(%s, %s): expected ok=%t, got %t", tc.a, tc.b, tc.expected != nil, ok)
+		} else if ok && !Identical(typ, tc.expected) {
+			t.Errorf("CommonType(%s, %s): expected %s, got %s", tc.a, tc.b, tc.expected, typ)
+		}
+	}
+}
+
+func TestWidenNumeric(t *testing.T) {
+	testCases := []struct {
+		a, b     T
+		expected T
+	}{
+		{typeInt2, typeInt2, typeInt2},
+		{typeInt2, typeInt4, typeInt4},
+		{Int, typeInt4, Int},
+		{typeInt4, Decimal, Decimal},
+		{Decimal, Float, Float},
+		{typeFloat4, typeFloat4, typeFloat4},
+		{typeInt4, typeFloat4, Float},
+		{typeFloat4, Decimal, Float},
+		{Float, typeFloat4, Float},
+		{Int, String, nil},
+		{Money, Decimal, nil},
+		{Xid, Int, nil},
+		{Unknown, Int, nil},
+	}
+	for _, tc := range testCases {
+		typ, ok := WidenNumeric(tc.a, tc.b)
+		if ok != (tc.expected != nil) {
+			t.Errorf("WidenNumeric(%s, %s): expected ok=%t, got %t", tc.a, tc.b, tc.expected != nil, ok)
+		} else if ok && !Identical(typ, tc.expected) {
+			t.Errorf("WidenNumeric(%s, %s): expected %s, got %s", tc.a, tc.b, tc.expected, typ)
+		}
+	}
+}
+
+func TestMakeTuple(t *testing.T) {
+	contents := []T{Int, String}
+	labels := []string{"a", "b"}
+	typ := MakeTuple(contents, labels)
+	contents[0], labels[0] = Float, "c"
+
+	if !Identical(typ, TTuple{Types: []T{Int, String}, Labels: []string{"a", "b"}}) {
+		t.Fatalf("unexpected tuple type %s", typ)
+	}
+	if o := typ.Oid(); o != oid.T_record {
+		t.Errorf("expected oid %d, got %d", oid.T_record, o)
+	}
+	if s := typ.String(); s != "tuple{int AS a, string AS b}" {
+		t.Errorf("unexpected string %s", s)
+	}
+	if !typ.Equivalent(MakeTuple([]T{Int, Name}, nil)) {
+		t.Errorf("expected %s to be equivalent to an unlabeled tuple of equivalent types", typ)
+	}
+	if typ.Equivalent(MakeTuple([]T{Int, Int}, nil)) {
+		t.Errorf("expected %s not to be equivalent to a tuple of other types", typ)
+	}
+	if typ.Equivalent(MakeTuple([]T{Int}, nil)) {
+		t.Errorf("expected %s not to be equivalent to a shorter tuple", typ)
+	}
+	if typ := MakeTuple([]T{Int}, nil); typ.(TTuple).Labels != nil {
+		t.Errorf("expected no labels, got %s", typ)
+	}
+}
+
+func TestMakeAnonymousRecord(t *testing.T) {
+	contents := []T{Int, MakeVarChar(5)}
+	typ := MakeAnonymousRecord(contents)
+	contents[0] = Float
+
+	tup, ok := typ.(TTuple)
+	if !ok {
+		t.Fatalf("expected a tuple type, got %s", typ)
+	}
+	if !Identical(typ, TTuple{Types: []T{Int, MakeVarChar(5)}}) {
+		t.Fatalf("unexpected record type %s", typ)
+	}
+	if tup.Labels != nil {
+		t.Errorf("expected no labels, got %v", tup.Labels)
+	}
+	if o := typ.Oid(); o != oid.T_record {
+		t.Errorf("expected oid %d, got %d", oid.T_record, o)
+	}
+	if Identical(typ, MakeTuple([]T{Int, MakeVarChar(5)}, []string{"a", "b"})) {
+		t.Errorf("expected %s not to be identical to a labeled tuple", typ)
+	}
+	if Identical(typ, FamTuple) {
+		t.Errorf("expected %s not to be identical to %s", typ, FamTuple)
+	}
+	if typ.IsAmbiguous() {
+		t.Errorf("expected %s not to be ambiguous", typ)
+	}
+	if empty := MakeAnonymousRecord(nil).(TTuple); empty.Types == nil {
+		t.Errorf("expected the fields of an empty record to be specified")
+	}
+}
+
+func TestMakeArray(t *testing.T) {
+	testCases := []struct {
+		elem     T
+		oid      oid.Oid
+		elemType T
+	}{
+		{Name, oid.T__name, Name},
+		{Int, oid.T__int8, Int},
+		{CString, oid.T__cstring, CString},
+		{MakeVarChar(10), oid.T__varchar, MakeVarChar(10)},
+		// An element type without a registered array type falls back to an
+		// array of the type it wraps.
+		{WrapTypeWithOid(String, oid.T_xml), oid.T__text, String},
+	}
+	for _, tc := range testCases {
+		typ := MakeArray(tc.elem)
+		if o := typ.Oid(); o != tc.oid {
+			t.Errorf("%s: expected oid %d, got %d", tc.elem, tc.oid, o)
+		}
+		if elem, ok := ElementType(typ); !ok || !Identical(elem, tc.elemType) {
+			t.Errorf("%s: expected element type %s, got %s", tc.elem, tc.elemType, elem)
+		}
+	}
+
+	if o := MakeArray(MakeTuple([]T{Int}, nil)).Oid(); o != 0 {
+		t.Errorf("expected an array of tuples to have oid 0, got %d", o)
+	}
+}
+
+func TestSortedArrayOids(t *testing.T) {
+	oids := SortedArrayOids()
+	if len(oids) != len(ArrayOids) {
+		t.Fatalf("expected %d oids, got %d", len(ArrayOids), len(oids))
+	}
+	for i, o := range oids {
+		if _, ok := ArrayOids[o]; !ok {
+			t.Errorf("%d is not an array oid", o)
+		}
+		if i > 0 && oids[i-1] >= o {
+			t.Errorf("oids are not in ascending order: %d, %d", oids[i-1], o)
+		}
+	}
+	// Modifying the result must not affect later calls.
+	oids[0] = 0
+	if SortedArrayOids()[0] == 0 {
+		t.Error("SortedArrayOids returned a shared slice")
+	}
+}
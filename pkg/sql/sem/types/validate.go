@@ -0,0 +1,68 @@
+// Copyright 2018 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package types
+
+import (
+	"reflect"
+	"unicode/utf8"
+
+	"github.com/cockroachdb/cockroach/pkg/sql/pgwire/pgerror"
+)
+
+// ValidateValue checks that the Go value v, of one of the types accepted by
+// TypeForGoValue, fits within the domain of t. Integers must fit the width of
+// int2 and int4 types, and strings must not have more characters than the
+// declared width of VARCHAR(n) and CHAR(n) types. The elements of slices are
+// checked against the element type of array types. These are the rules that
+// sqlbase.LimitValueWidth enforces on INSERT and UPDATE; since Decimal doesn't
+// carry a precision, decimals are not checked, and neither are the values
+// which don't belong to t.
+//
+// ValidateValue takes a Go value rather than a tree.Datum because types cannot
+// depend on tree, which imports it; callers holding a datum can pass the Go
+// value it wraps, e.g. int64(*tree.DInt) or string(*tree.DString).
+func ValidateValue(t T, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if m, ok := t.(TMultiDimArray); ok {
+		// The values of multi-dimensional arrays are flattened.
+		t = m.TArray
+	}
+	if a, ok := t.(TArray); ok {
+		if rv.Kind() != reflect.Slice || rv.Type() == bytesType {
+			return nil
+		}
+		for i := 0; i < rv.Len(); i++ {
+			if err := ValidateValue(a.Typ, rv.Index(i).Interface()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if w := uint(intWidth(t)); w != 0 && w < 64 {
+			if i := rv.Int(); i < -1<<(w-1) || i >= 1<<(w-1) {
+				return pgerror.NewErrorf(pgerror.CodeNumericValueOutOfRangeError,
+					"integer %d out of range for type %s", i, DisplayName(t))
+			}
+		}
+	case reflect.String:
+		if width, ok := CharWidth(t); ok && utf8.RuneCountInString(rv.String()) > int(width) {
+			return pgerror.NewErrorf(pgerror.CodeStringDataRightTruncationError,
+				"value too long for type %s", DisplayName(t))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+// Copyright 2018 The Cockroach Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package types
+
+import (
+	"math"
+	"testing"
+
+	"github.com/cockroachdb/cockroach/pkg/testutils"
+)
+
+func TestValidateValue(t *testing.T) {
+	testCases := []struct {
+		typ T
+		val interface{}
+		err string
+	}{
+		{typeInt2, int64(math.MaxInt16), ""},
+		{typeInt2, int64(math.MinInt16), ""},
+		{typeInt2, int64(math.MaxInt16 + 1), "integer 32768 out of range for type smallint"},
+		{typeInt2, int64(math.MinInt16 - 1), "integer -32769 out of range for type smallint"},
+		{typeInt4, int32(math.MaxInt32), ""},
+		{typeInt4, int64(math.MaxInt32 + 1), "integer 2147483648 out of range for type integer"},
+		{Int, int64(math.MaxInt64), ""},
+		{MakeVarChar(3), "abc", ""},
+		{MakeVarChar(3), "ééé", ""},
+		{MakeVarChar(3), "abcd", "value too long for type character varying\\(3\\)"},
+		{MakeChar(1), "ab", "value too long for type bpchar\\(1\\)"},
+		{String, "abcd", ""},
+		{TArray{Typ: typeInt2}, []int64{1, 2}, ""},
+		{TArray{Typ: typeInt2}, []int64{1, 1 << 20}, "out of range for type smallint"},
+		{TArray{Typ: MakeVarChar(1)}, []string{"a", "bc"}, "value too long"},
+		{Decimal, 1.5, ""},
+		{typeInt2, nil, ""},
+	}
+	for i, tc := range testCases {
+		if err := ValidateValue(tc.typ, tc.val); !testutils.IsError(err, tc.err) {
+			t.Errorf("%d: %s %v: expected error %q, got %v", i, tc.typ, tc.val, tc.err, err)
+		}
+	}
+}
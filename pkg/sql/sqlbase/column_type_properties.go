@@ -25,6 +25,7 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/sql/pgwire/pgerror"
 	"github.com/cockroachdb/cockroach/pkg/sql/sem/tree"
 	"github.com/cockroachdb/cockroach/pkg/sql/sem/types"
+	"github.com/lib/pq/oid"
 	"github.com/pkg/errors"
 )
 
@@ -539,7 +540,8 @@ func datumTypeToColumnSemanticType(ptyp types.T) (ColumnType_SemanticType, error
 		return ColumnType_UUID, nil
 	case types.INet:
 		return ColumnType_INET, nil
-	case types.Oid, types.RegClass, types.RegNamespace, types.RegProc, types.RegType, types.RegProcedure:
+	case types.Oid, types.RegClass, types.RegNamespace, types.RegProc, types.RegType, types.RegProcedure,
+		types.RegCollation:
 		return ColumnType_OID, nil
 	case types.Unknown:
 		return ColumnType_NULL, nil
@@ -651,6 +653,155 @@ func ColumnTypesToDatumTypes(colTypes []ColumnType) []types.T {
 	return res
 }
 
+// FromColumnType converts a ColumnType to the types.T describing it. Unlike
+// ToDatumType, it preserves the attributes of the column type which types.T
+// can represent: the Oid of sized integer and float types and of the
+// character types, the width of VARCHAR(n) and CHAR(n) types, and the type
+// of array elements. The precision and scale of decimals and the width of bit
+// strings are not part of types.T and are dropped, as are the fields of
+// restricted intervals when converting the other way.
+//
+// FromColumnType and ToColumnType are meant to be used whenever types stored
+// in descriptors are loaded into the type system and vice versa. They live in
+// sqlbase rather than in types because types cannot depend on sqlbase.
+func FromColumnType(ct *ColumnType) (types.T, error) {
+	switch ct.SemanticType {
+	case ColumnType_ARRAY:
+		if ct.ArrayContents == nil {
+			return nil, errors.Errorf("array column type %s has no element type", ct)
+		}
+		elem, err := FromColumnType(ct.elementColumnType())
+		if err != nil {
+			return nil, err
+		}
+		return types.MakeArray(elem), nil
+	case ColumnType_TUPLE:
+		contents := make([]types.T, len(ct.TupleContents))
+		for i := range ct.TupleContents {
+			var err error
+			contents[i], err = FromColumnType(&ct.TupleContents[i])
+			if err != nil {
+				return nil, err
+			}
+		}
+		return types.MakeTuple(contents, ct.TupleLabels), nil
+	case ColumnType_INT:
+		switch ct.Width {
+		case 16:
+			return types.OidToType[oid.T_int2], nil
+		case 32:
+			return types.OidToType[oid.T_int4], nil
+		}
+		return types.Int, nil
+	case ColumnType_FLOAT:
+		if width, _ := ct.FloatProperties(); width == 32 {
+			return types.OidToType[oid.T_float4], nil
+		}
+		return types.Float, nil
+	case ColumnType_STRING:
+		switch ct.VisibleType {
+		case ColumnType_VARCHAR:
+			return types.MakeVarChar(ct.Width), nil
+		case ColumnType_CHAR:
+			return types.MakeChar(ct.Width), nil
+		case ColumnType_QCHAR:
+			return types.OidToType[oid.T_char], nil
+		}
+		if ct.Width > 0 {
+			return types.TSizedString{T: types.String, Width: ct.Width}, nil
+		}
+		return types.String, nil
+	case ColumnType_BIT:
+		if ct.VisibleType == ColumnType_VARBIT {
+			return types.BitArray, nil
+		}
+		return types.OidToType[oid.T_bit], nil
+	case ColumnType_COLLATEDSTRING:
+		if ct.Locale == nil {
+			return nil, errors.Errorf("collated string column type %s has no locale", ct)
+		}
+	}
+	if typ := columnSemanticTypeToDatumType(ct, ct.SemanticType); typ != nil {
+		return typ, nil
+	}
+	return nil, pgerror.NewErrorf(pgerror.CodeFeatureNotSupportedError,
+		"unsupported column type: %s", ct)
+}
+
+// ToColumnType converts a types.T to the ColumnType describing it. It is the
+// inverse of FromColumnType: FromColumnType(ToColumnType(t)) has the same Oid,
+// width and element type as t. An error is returned for the types which a
+// ColumnType cannot represent faithfully, e.g. regclass, which would be
+// reported as an oid, and nested arrays.
+func ToColumnType(t types.T) (ColumnType, error) {
+	var ct ColumnType
+	switch typ := t.(type) {
+	case types.TArray:
+		elem, err := ToColumnType(typ.Typ)
+		if err != nil {
+			return ColumnType{}, err
+		}
+		if elem.SemanticType == ColumnType_ARRAY || elem.SemanticType == ColumnType_TUPLE {
+			return ColumnType{}, pgerror.NewErrorf(pgerror.CodeFeatureNotSupportedError,
+				"unsupported column type: %s", t)
+		}
+		ct = elem
+		ct.SemanticType = ColumnType_ARRAY
+		ct.ArrayContents = &elem.SemanticType
+	case types.TTuple:
+		ct.SemanticType = ColumnType_TUPLE
+		ct.TupleContents = make([]ColumnType, len(typ.Types))
+		for i, tc := range typ.Types {
+			var err error
+			ct.TupleContents[i], err = ToColumnType(tc)
+			if err != nil {
+				return ColumnType{}, err
+			}
+		}
+		ct.TupleLabels = typ.Labels
+	case types.TSizedString:
+		var err error
+		ct, err = ToColumnType(typ.T)
+		if err != nil {
+			return ColumnType{}, err
+		}
+		ct.Width = typ.Width
+	case types.TRestrictedInterval:
+		ct.SemanticType = ColumnType_INTERVAL
+	default:
+		switch t.Oid() {
+		case oid.T_int2:
+			ct = ColumnType{SemanticType: ColumnType_INT, Width: 16, VisibleType: ColumnType_SMALLINT}
+		case oid.T_int4:
+			ct = ColumnType{SemanticType: ColumnType_INT, Width: 32, VisibleType: ColumnType_INTEGER}
+		case oid.T_int8:
+			ct = ColumnType{SemanticType: ColumnType_INT, Width: 64, VisibleType: ColumnType_BIGINT}
+		case oid.T_float4:
+			ct = ColumnType{SemanticType: ColumnType_FLOAT, VisibleType: ColumnType_REAL}
+		case oid.T_varchar:
+			ct = ColumnType{SemanticType: ColumnType_STRING, VisibleType: ColumnType_VARCHAR}
+		case oid.T_bpchar:
+			ct = ColumnType{SemanticType: ColumnType_STRING, VisibleType: ColumnType_CHAR}
+		case oid.T_char:
+			ct = ColumnType{SemanticType: ColumnType_STRING, VisibleType: ColumnType_QCHAR}
+		case oid.T_varbit:
+			ct = ColumnType{SemanticType: ColumnType_BIT, VisibleType: ColumnType_VARBIT}
+		default:
+			var err error
+			ct, err = DatumTypeToColumnType(t)
+			if err != nil {
+				return ColumnType{}, err
+			}
+		}
+	}
+	back, err := FromColumnType(&ct)
+	if err != nil || back.Oid() != t.Oid() {
+		return ColumnType{}, pgerror.NewErrorf(pgerror.CodeFeatureNotSupportedError,
+			"type %s cannot be represented by a column type", t)
+	}
+	return ct, nil
+}
+
 // LimitValueWidth checks that the width (for strings, byte arrays, and bit
 // strings) and scale (for decimals) of the value fits the specified column
 // type. In case of decimals, it can truncate fractional digits in the input
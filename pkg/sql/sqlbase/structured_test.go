@@ -32,6 +32,7 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/testutils/sqlutils"
 	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
 	"github.com/cockroachdb/cockroach/pkg/util/protoutil"
+	"github.com/lib/pq/oid"
 )
 
 // Makes an IndexDescriptor with all columns being ascending.
@@ -1327,3 +1328,83 @@ func TestDatumTypeToColumnSemanticType(t *testing.T) {
 		}
 	}
 }
+
+func TestColumnTypeRoundTrip(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	en := "en"
+	testCases := []types.T{
+		types.Bool,
+		types.Int,
+		types.OidToType[oid.T_int2],
+		types.OidToType[oid.T_int4],
+		types.Float,
+		types.OidToType[oid.T_float4],
+		types.Decimal,
+		types.String,
+		types.MakeVarChar(10),
+		types.MakeChar(3),
+		types.TSizedString{T: types.String, Width: 4},
+		types.OidToType[oid.T_char],
+		types.Name,
+		types.BitArray,
+		types.OidToType[oid.T_bit],
+		types.TCollatedString{Locale: en},
+		types.Oid,
+		types.IntVector,
+		types.MakeArray(types.OidToType[oid.T_int2]),
+		types.MakeArray(types.MakeVarChar(5)),
+		types.MakeArray(types.Name),
+		types.MakeTuple([]types.T{types.Int, types.MakeChar(2)}, []string{"a", "b"}),
+	}
+	for _, typ := range testCases {
+		t.Run(typ.String(), func(t *testing.T) {
+			ct, err := ToColumnType(typ)
+			if err != nil {
+				t.Fatal(err)
+			}
+			back, err := FromColumnType(&ct)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !types.Identical(typ, back) {
+				t.Errorf("expected %s, got %s", typ, back)
+			}
+			if w, ok := types.CharWidth(typ); ok {
+				if bw, _ := types.CharWidth(back); bw != w {
+					t.Errorf("expected width %d, got %d", w, bw)
+				}
+			}
+		})
+	}
+
+	for _, typ := range []types.T{
+		types.RegClass,
+		types.MakeArray(types.MakeArray(types.Int)),
+	} {
+		if _, err := ToColumnType(typ); !testutils.IsError(err, "cannot be represented|unsupported") {
+			t.Errorf("%s: unexpected error: %v", typ, err)
+		}
+	}
+
+	// The visible type of string column types survives the round trip.
+	for _, ct := range []ColumnType{
+		{SemanticType: ColumnType_STRING},
+		{SemanticType: ColumnType_STRING, Width: 4},
+		{SemanticType: ColumnType_STRING, VisibleType: ColumnType_VARCHAR, Width: 4},
+		{SemanticType: ColumnType_STRING, VisibleType: ColumnType_CHAR, Width: 4},
+		{SemanticType: ColumnType_STRING, VisibleType: ColumnType_QCHAR},
+	} {
+		typ, err := FromColumnType(&ct)
+		if err != nil {
+			t.Fatal(err)
+		}
+		back, err := ToColumnType(typ)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !back.Equal(ct) {
+			t.Errorf("expected %s, got %s", ct.SQLString(), back.SQLString())
+		}
+	}
+}
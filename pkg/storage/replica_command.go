@@ -540,22 +540,34 @@ func waitForApplication(
 		for _, repl := range desc.Replicas {
 			repl := repl // copy for goroutine
 			g.GoCtx(func(ctx context.Context) error {
-				conn, err := dialer.Dial(ctx, repl.NodeID)
-				if err != nil {
-					return errors.Wrapf(err, "could not dial n%d", repl.NodeID)
-				}
-				_, err = NewPerReplicaClient(conn).WaitForApplication(ctx, &WaitForApplicationRequest{
-					StoreRequestHeader: StoreRequestHeader{NodeID: repl.NodeID, StoreID: repl.StoreID},
-					RangeID:            desc.RangeID,
-					LeaseIndex:         leaseIndex,
-				})
-				return err
+				return WaitForReplicaApplication(ctx, dialer, repl, desc.RangeID, leaseIndex)
 			})
 		}
 		return g.Wait()
 	})
 }
 
+// WaitForReplicaApplication blocks until the provided replica of the range
+// has applied the command at leaseIndex, or the context is done.
+func WaitForReplicaApplication(
+	ctx context.Context,
+	dialer *nodedialer.Dialer,
+	repl roachpb.ReplicaDescriptor,
+	rangeID roachpb.RangeID,
+	leaseIndex uint64,
+) error {
+	conn, err := dialer.Dial(ctx, repl.NodeID)
+	if err != nil {
+		return errors.Wrapf(err, "could not dial n%d", repl.NodeID)
+	}
+	_, err = NewPerReplicaClient(conn).WaitForApplication(ctx, &WaitForApplicationRequest{
+		StoreRequestHeader: StoreRequestHeader{NodeID: repl.NodeID, StoreID: repl.StoreID},
+		RangeID:            rangeID,
+		LeaseIndex:         leaseIndex,
+	})
+	return err
+}
+
 // waitForReplicasInit blocks until it has proof that the replicas listed in
 // desc are initialized on their respective stores. It may return a false
 // negative, i.e., claim that a replica is uninitialized when it is, in fact,